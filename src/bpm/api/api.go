@@ -0,0 +1,117 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package api is the local control API of bpm. It is served by bpm supervise
+// over a unix socket which only root can connect to. The API is versioned by
+// the first element of its paths (e.g. /v1/processes) and a version is not
+// changed once it is released.
+//
+//	GET  /v1/ping
+//	GET  /v1/processes
+//	GET  /v1/jobs/JOB/processes/PROCESS
+//	POST /v1/jobs/JOB/processes/PROCESS/start
+//	POST /v1/jobs/JOB/processes/PROCESS/stop
+//	POST /v1/jobs/JOB/processes/PROCESS/restart {"if_changed": false, "wait_cgroup_empty": false}
+//	POST /v1/jobs/JOB/processes/PROCESS/pause
+//	POST /v1/jobs/JOB/processes/PROCESS/resume
+//	POST /v1/jobs/JOB/processes/PROCESS/signal  {"signal": "HUP"}
+//	GET  /v1/jobs/JOB/processes/PROCESS/stats
+//	GET  /v1/jobs/JOB/processes/PROCESS/logs?stream=stdout&lines=25
+//
+// Failures are returned with a JSON body of the form {"error": MESSAGE,
+// "exit_status": STATUS} where STATUS is the exit status the bpm command would
+// have exited with (see the errs package).
+package api
+
+import (
+	"bpm/errs"
+)
+
+// Version is the version of the API which this package serves and uses.
+const Version = "v1"
+
+// Process is the state of a process.
+type Process struct {
+	Job     string `json:"job"`
+	Process string `json:"process"`
+	Pid     int    `json:"pid"`
+	Status  string `json:"status"`
+}
+
+// Stats is the resource usage of a running process.
+type Stats struct {
+	MemoryBytes    uint64 `json:"memory_bytes"`
+	CPUNanoseconds uint64 `json:"cpu_nanoseconds"`
+	Pids           uint64 `json:"pids"`
+}
+
+// The streams which a process logs to.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// DefaultLogLines is how many lines of a log are returned if the request
+// does not say.
+const DefaultLogLines = 25
+
+//go:generate counterfeiter . Controller
+
+// Controller carries out the requests made to the API. The client implements
+// it as well so that it can be used in place of acting on processes directly.
+type Controller interface {
+	List() ([]Process, error)
+	Status(job, process string) (*Process, error)
+	Start(job, process string) error
+	Stop(job, process string) error
+	Restart(job, process string, opts RestartOptions) error
+	Pause(job, process string) error
+	Resume(job, process string) error
+	Signal(job, process, signal string) error
+	Stats(job, process string) (*Stats, error)
+	Logs(job, process, stream string, lines int) ([]string, error)
+}
+
+// RestartOptions are the options of bpm restart which apply to a single
+// process.
+type RestartOptions struct {
+	IfChanged       bool `json:"if_changed"`
+	WaitCgroupEmpty bool `json:"wait_cgroup_empty"`
+}
+
+type signalRequest struct {
+	Signal string `json:"signal"`
+}
+
+type logsResponse struct {
+	Lines []string `json:"lines"`
+}
+
+type errorResponse struct {
+	Error      string `json:"error"`
+	ExitStatus int    `json:"exit_status"`
+}
+
+// kindForExitStatus finds the kind of failure which has an exit status so
+// that clients can return the same kind of error as the server.
+func kindForExitStatus(status int) (errs.Kind, bool) {
+	for kind := errs.ConfigInvalid; kind.ExitStatus() != 1; kind++ {
+		if kind.ExitStatus() == status {
+			return kind, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,166 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"bpm/errs"
+)
+
+// Client makes requests to the API served on a unix socket.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client for the API served on socketPath.
+func NewClient(socketPath string) *Client {
+	dialer := &net.Dialer{Timeout: time.Second}
+
+	return &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Ping checks that the API is being served.
+func (c *Client) Ping() error {
+	return c.do(http.MethodGet, "/ping", nil, nil)
+}
+
+func (c *Client) List() ([]Process, error) {
+	var processes []Process
+	err := c.do(http.MethodGet, "/processes", nil, &processes)
+	return processes, err
+}
+
+func (c *Client) Status(job, process string) (*Process, error) {
+	var p Process
+	if err := c.do(http.MethodGet, processPath(job, process), nil, &p); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (c *Client) Start(job, process string) error {
+	return c.do(http.MethodPost, processPath(job, process, "start"), nil, nil)
+}
+
+func (c *Client) Stop(job, process string) error {
+	return c.do(http.MethodPost, processPath(job, process, "stop"), nil, nil)
+}
+
+func (c *Client) Restart(job, process string, opts RestartOptions) error {
+	return c.do(http.MethodPost, processPath(job, process, "restart"), opts, nil)
+}
+
+func (c *Client) Pause(job, process string) error {
+	return c.do(http.MethodPost, processPath(job, process, "pause"), nil, nil)
+}
+
+func (c *Client) Resume(job, process string) error {
+	return c.do(http.MethodPost, processPath(job, process, "resume"), nil, nil)
+}
+
+func (c *Client) Signal(job, process, signal string) error {
+	return c.do(http.MethodPost, processPath(job, process, "signal"), signalRequest{Signal: signal}, nil)
+}
+
+func (c *Client) Stats(job, process string) (*Stats, error) {
+	var stats Stats
+	if err := c.do(http.MethodGet, processPath(job, process, "stats"), nil, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+func (c *Client) Logs(job, process, stream string, lines int) ([]string, error) {
+	query := url.Values{}
+	query.Set("stream", stream)
+	query.Set("lines", strconv.Itoa(lines))
+
+	var resp logsResponse
+	err := c.do(http.MethodGet, processPath(job, process, "logs")+"?"+query.Encode(), nil, &resp)
+	return resp.Lines, err
+}
+
+func processPath(job, process string, action ...string) string {
+	return path.Join(append([]string{"/jobs", url.PathEscape(job), "processes", url.PathEscape(process)}, action...)...)
+}
+
+func (c *Client) do(method, path string, reqBody, respBody interface{}) error {
+	var body io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	// The host is ignored as every request is sent over the socket.
+	req, err := http.NewRequest(method, "http://bpm/"+Version+path, body)
+	if err != nil {
+		return err
+	}
+
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+			return fmt.Errorf("request failed: %s", resp.Status)
+		}
+
+		if kind, ok := kindForExitStatus(errResp.ExitStatus); ok {
+			return errs.New(kind, "%s", errResp.Error)
+		}
+
+		return errors.New(errResp.Error)
+	}
+
+	if respBody == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
@@ -0,0 +1,265 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/api"
+	"bpm/api/apifakes"
+	"bpm/errs"
+)
+
+var _ = Describe("API", func() {
+	var (
+		tempDir        string
+		socketPath     string
+		listener       net.Listener
+		fakeController *apifakes.FakeController
+		client         *api.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "bpm-api")
+		Expect(err).NotTo(HaveOccurred())
+
+		socketPath = filepath.Join(tempDir, "run", "bpm.sock")
+		listener, err = api.Listen(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeController = &apifakes.FakeController{}
+		go http.Serve(listener, api.NewHandler(lagertest.NewTestLogger("api"), fakeController))
+
+		client = api.NewClient(socketPath)
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("serves on a socket which only root can use", func() {
+		info, err := os.Stat(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		info, err = os.Stat(filepath.Dir(socketPath))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+	})
+
+	It("replaces a socket left behind by a previous server", func() {
+		listener.Close()
+		Expect(ioutil.WriteFile(socketPath, nil, 0600)).To(Succeed())
+
+		var err error
+		listener, err = api.Listen(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("responds to pings", func() {
+		Expect(client.Ping()).To(Succeed())
+	})
+
+	It("lists the processes", func() {
+		fakeController.ListReturns([]api.Process{
+			{Job: "server", Process: "server", Pid: 123, Status: "running"},
+			{Job: "server", Process: "worker", Status: "stopped"},
+		}, nil)
+
+		processes, err := client.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(processes).To(Equal([]api.Process{
+			{Job: "server", Process: "server", Pid: 123, Status: "running"},
+			{Job: "server", Process: "worker", Status: "stopped"},
+		}))
+	})
+
+	It("shows the status of a process", func() {
+		fakeController.StatusReturns(&api.Process{Job: "server", Process: "worker", Pid: 42, Status: "running"}, nil)
+
+		process, err := client.Status("server", "worker")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(process).To(Equal(&api.Process{Job: "server", Process: "worker", Pid: 42, Status: "running"}))
+
+		job, proc := fakeController.StatusArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+	})
+
+	It("starts and stops a process", func() {
+		Expect(client.Start("server", "worker")).To(Succeed())
+		Expect(fakeController.StartCallCount()).To(Equal(1))
+		job, proc := fakeController.StartArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+
+		Expect(client.Stop("server", "worker")).To(Succeed())
+		Expect(fakeController.StopCallCount()).To(Equal(1))
+	})
+
+	It("restarts a process with the options of bpm restart", func() {
+		opts := api.RestartOptions{IfChanged: true, WaitCgroupEmpty: true}
+		Expect(client.Restart("server", "worker", opts)).To(Succeed())
+
+		job, proc, restartOpts := fakeController.RestartArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+		Expect(restartOpts).To(Equal(opts))
+	})
+
+	It("pauses and resumes a process", func() {
+		Expect(client.Pause("server", "worker")).To(Succeed())
+		Expect(fakeController.PauseCallCount()).To(Equal(1))
+		job, proc := fakeController.PauseArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+
+		Expect(client.Resume("server", "worker")).To(Succeed())
+		Expect(fakeController.ResumeCallCount()).To(Equal(1))
+		job, proc = fakeController.ResumeArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+	})
+
+	It("signals a process", func() {
+		Expect(client.Signal("server", "worker", "HUP")).To(Succeed())
+
+		job, proc, signal := fakeController.SignalArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+		Expect(signal).To(Equal("HUP"))
+	})
+
+	It("shows the resource usage of a process", func() {
+		fakeController.StatsReturns(&api.Stats{MemoryBytes: 1024, CPUNanoseconds: 5000, Pids: 3}, nil)
+
+		stats, err := client.Stats("server", "worker")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(&api.Stats{MemoryBytes: 1024, CPUNanoseconds: 5000, Pids: 3}))
+	})
+
+	It("tails the logs of a process", func() {
+		fakeController.LogsReturns([]string{"one", "two"}, nil)
+
+		lines, err := client.Logs("server", "worker", api.Stderr, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lines).To(Equal([]string{"one", "two"}))
+
+		job, proc, stream, n := fakeController.LogsArgsForCall(0)
+		Expect(job).To(Equal("server"))
+		Expect(proc).To(Equal("worker"))
+		Expect(stream).To(Equal(api.Stderr))
+		Expect(n).To(Equal(2))
+	})
+
+	Context("when carrying out the request fails", func() {
+		It("returns an error of the same kind", func() {
+			fakeController.StartReturns(errs.New(errs.HookFailure, "pre-start hook failed"))
+
+			err := client.Start("server", "worker")
+			Expect(err).To(MatchError("pre-start hook failed"))
+			Expect(errors.Is(err, errs.HookFailure)).To(BeTrue())
+		})
+
+		It("returns the exit status of a process which is already running", func() {
+			fakeController.StartReturns(errs.New(errs.AlreadyRunning, "process is already running (running)"))
+
+			err := client.Start("server", "worker")
+			Expect(errors.Is(err, errs.AlreadyRunning)).To(BeTrue())
+
+			var classified *errs.Error
+			Expect(errors.As(err, &classified)).To(BeTrue())
+			Expect(classified.ExitStatus()).To(Equal(12))
+		})
+
+		It("returns unclassified errors", func() {
+			fakeController.StopReturns(errors.New("disaster"))
+
+			err := client.Stop("server", "worker")
+			Expect(err).To(MatchError("disaster"))
+
+			var classified *errs.Error
+			Expect(errors.As(err, &classified)).To(BeFalse())
+		})
+	})
+
+	Context("when the request is invalid", func() {
+		It("rejects unknown log streams", func() {
+			_, err := client.Logs("server", "worker", "stdin", 2)
+			Expect(err).To(MatchError("invalid stream: stdin"))
+			Expect(fakeController.LogsCallCount()).To(Equal(0))
+		})
+
+		It("rejects unsupported signals", func() {
+			err := client.Signal("server", "worker", "BOGUS")
+			Expect(err).To(MatchError("unsupported signal: BOGUS"))
+			Expect(fakeController.SignalCallCount()).To(Equal(0))
+		})
+
+		It("rejects unknown paths and methods", func() {
+			httpClient := &http.Client{Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.Dial("unix", socketPath)
+				},
+			}}
+
+			resp, err := httpClient.Get("http://bpm/v2/processes")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+			resp, err = httpClient.Post("http://bpm/v1/processes", "application/json", strings.NewReader("{}"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
+
+		It("responds with not found for a job or process which the controller cannot find", func() {
+			fakeController.StatusReturns(nil, errs.New(errs.ProcessNotFound, "job \"..\" is not on this machine"))
+
+			httpClient := &http.Client{Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.Dial("unix", socketPath)
+				},
+			}}
+
+			resp, err := httpClient.Get("http://bpm/v1/jobs/%2E%2E/processes/worker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+			job, proc := fakeController.StatusArgsForCall(0)
+			Expect(job).To(Equal(".."))
+			Expect(proc).To(Equal("worker"))
+		})
+	})
+
+	Context("when the server is not running", func() {
+		It("fails to ping", func() {
+			Expect(api.NewClient(filepath.Join(tempDir, "missing.sock")).Ping()).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,238 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+
+	"bpm/errs"
+	"bpm/runc/client"
+)
+
+// Listen listens on a unix socket which only root can connect to. A socket
+// left behind by a previous server is replaced.
+func Listen(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+type handler struct {
+	logger     lager.Logger
+	controller Controller
+}
+
+// NewHandler returns a handler which serves the API using controller.
+func NewHandler(logger lager.Logger, controller Controller) http.Handler {
+	return &handler{
+		logger:     logger.Session("api"),
+		controller: controller,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.Session("request", lager.Data{"method": r.Method, "path": r.URL.Path})
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != Version {
+		h.writeError(logger, w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "ping":
+		h.handle(logger, w, r, http.MethodGet, func() (interface{}, error) {
+			return struct{}{}, nil
+		})
+	case len(parts) == 2 && parts[1] == "processes":
+		h.handle(logger, w, r, http.MethodGet, func() (interface{}, error) {
+			return h.controller.List()
+		})
+	case len(parts) >= 5 && parts[1] == "jobs" && parts[3] == "processes":
+		h.handleProcess(logger, w, r, parts[2], parts[4], parts[5:])
+	default:
+		h.writeError(logger, w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+	}
+}
+
+func (h *handler) handleProcess(logger lager.Logger, w http.ResponseWriter, r *http.Request, job, process string, action []string) {
+	if len(action) > 1 {
+		h.writeError(logger, w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+		return
+	}
+
+	if len(action) == 0 {
+		h.handle(logger, w, r, http.MethodGet, func() (interface{}, error) {
+			return h.controller.Status(job, process)
+		})
+		return
+	}
+
+	switch action[0] {
+	case "start":
+		h.handle(logger, w, r, http.MethodPost, func() (interface{}, error) {
+			return struct{}{}, h.controller.Start(job, process)
+		})
+	case "stop":
+		h.handle(logger, w, r, http.MethodPost, func() (interface{}, error) {
+			return struct{}{}, h.controller.Stop(job, process)
+		})
+	case "restart":
+		h.handle(logger, w, r, http.MethodPost, func() (interface{}, error) {
+			var opts RestartOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+				return nil, badRequest{fmt.Errorf("invalid request: %s", err)}
+			}
+
+			return struct{}{}, h.controller.Restart(job, process, opts)
+		})
+	case "pause":
+		h.handle(logger, w, r, http.MethodPost, func() (interface{}, error) {
+			return struct{}{}, h.controller.Pause(job, process)
+		})
+	case "resume":
+		h.handle(logger, w, r, http.MethodPost, func() (interface{}, error) {
+			return struct{}{}, h.controller.Resume(job, process)
+		})
+	case "signal":
+		h.handle(logger, w, r, http.MethodPost, func() (interface{}, error) {
+			var req signalRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				return nil, badRequest{fmt.Errorf("invalid request: %s", err)}
+			}
+
+			if _, err := client.ParseSignal(req.Signal); err != nil {
+				return nil, badRequest{err}
+			}
+
+			return struct{}{}, h.controller.Signal(job, process, req.Signal)
+		})
+	case "stats":
+		h.handle(logger, w, r, http.MethodGet, func() (interface{}, error) {
+			return h.controller.Stats(job, process)
+		})
+	case "logs":
+		h.handle(logger, w, r, http.MethodGet, func() (interface{}, error) {
+			stream := r.URL.Query().Get("stream")
+			if stream == "" {
+				stream = Stdout
+			}
+			if stream != Stdout && stream != Stderr {
+				return nil, badRequest{fmt.Errorf("invalid stream: %s", stream)}
+			}
+
+			lines := DefaultLogLines
+			if n := r.URL.Query().Get("lines"); n != "" {
+				var err error
+				lines, err = strconv.Atoi(n)
+				if err != nil || lines < 0 {
+					return nil, badRequest{fmt.Errorf("invalid lines: %s", n)}
+				}
+			}
+
+			logLines, err := h.controller.Logs(job, process, stream, lines)
+			return logsResponse{Lines: logLines}, err
+		})
+	default:
+		h.writeError(logger, w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+	}
+}
+
+// badRequest is a problem with the request itself rather than with carrying
+// it out.
+type badRequest struct {
+	error
+}
+
+func (h *handler) handle(logger lager.Logger, w http.ResponseWriter, r *http.Request, method string, fn func() (interface{}, error)) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		h.writeError(logger, w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		return
+	}
+
+	body, err := fn()
+	if err != nil {
+		h.writeError(logger, w, statusCode(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error("failed-to-write-response", err)
+	}
+}
+
+func (h *handler) writeError(logger lager.Logger, w http.ResponseWriter, code int, err error) {
+	logger.Error("request-failed", err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	resp := errorResponse{Error: err.Error(), ExitStatus: 1}
+	var classified *errs.Error
+	if errors.As(err, &classified) {
+		resp.ExitStatus = classified.ExitStatus()
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("failed-to-write-response", err)
+	}
+}
+
+func statusCode(err error) int {
+	if _, ok := err.(badRequest); ok {
+		return http.StatusBadRequest
+	}
+
+	switch errs.KindOf(err, 0) {
+	case errs.ProcessNotFound:
+		return http.StatusNotFound
+	case errs.AlreadyRunning, errs.LockFailure:
+		return http.StatusConflict
+	case errs.ConfigInvalid:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package api
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+const tailBlockSize = 4096
+
+// TailFile returns the last n lines of a file without reading the whole
+// file. A file which does not exist has no lines.
+func TailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read blocks backwards from the end of the file until there are more
+	// newlines than the lines wanted (or the start of the file is reached).
+	var data []byte
+	offset := end
+	for offset > 0 && bytes.Count(data, []byte("\n")) <= n {
+		size := int64(tailBlockSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		block := make([]byte, size)
+		if _, err := f.ReadAt(block, offset); err != nil {
+			return nil, err
+		}
+		data = append(block, data...)
+	}
+
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if n == 0 || len(data) == 0 {
+		return []string{}, nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines, nil
+}
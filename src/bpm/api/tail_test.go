@@ -0,0 +1,84 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package api_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/api"
+)
+
+var _ = Describe("TailFile", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "bpm-tail")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	write := func(contents string) string {
+		path := filepath.Join(tempDir, "log")
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	It("returns the last lines of the file", func() {
+		lines, err := api.TailFile(write("one\ntwo\nthree\n"), 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lines).To(Equal([]string{"two", "three"}))
+	})
+
+	It("returns every line of a short file", func() {
+		lines, err := api.TailFile(write("one\ntwo"), 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lines).To(Equal([]string{"one", "two"}))
+	})
+
+	It("reads files which are larger than a block", func() {
+		var contents strings.Builder
+		for i := 0; i < 2000; i++ {
+			fmt.Fprintf(&contents, "line %d\n", i)
+		}
+
+		lines, err := api.TailFile(write(contents.String()), 1500)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lines).To(HaveLen(1500))
+		Expect(lines[0]).To(Equal("line 500"))
+		Expect(lines[1499]).To(Equal("line 1999"))
+	})
+
+	It("returns no lines for an empty or missing file", func() {
+		lines, err := api.TailFile(write(""), 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lines).To(BeEmpty())
+
+		lines, err = api.TailFile(filepath.Join(tempDir, "missing"), 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lines).To(BeEmpty())
+	})
+})
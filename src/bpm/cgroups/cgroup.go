@@ -17,11 +17,15 @@ package cgroups
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -153,6 +157,265 @@ func enabledSubsystems(f io.Reader) ([]string, error) {
 	return subs, nil
 }
 
+// ProcessCgroup returns the directory of the cgroup which a process is in for
+// a particular subsystem. An empty subsystem returns its cgroups v2 cgroup.
+func ProcessCgroup(pid int, subsystem string) (string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var unifiedRoot string
+	if subsystem == "" {
+		mnts, err := mount.Mounts()
+		if err != nil {
+			return "", err
+		}
+
+		unifiedRoot, err = unifiedMountPoint(mnts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return processCgroup(f, subsystem, unifiedRoot)
+}
+
+func processCgroup(f io.Reader, subsystem, unifiedRoot string) (string, error) {
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if !containsElement(strings.Split(fields[1], ","), subsystem) {
+			continue
+		}
+
+		if subsystem == "" {
+			return filepath.Join(unifiedRoot, fields[2]), nil
+		}
+
+		return filepath.Join(cgroupRoot, fields[1], fields[2]), nil
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("process is not in a %s cgroup", subsystem)
+}
+
+// unifiedMountPoint returns where the cgroups v2 hierarchy is mounted. It is
+// /sys/fs/cgroup unless the cgroups v1 hierarchies are mounted there as well
+// (a hybrid hierarchy), in which case it is usually /sys/fs/cgroup/unified.
+func unifiedMountPoint(mnts []mount.Mnt) (string, error) {
+	for _, mnt := range mnts {
+		if mnt.Filesystem == "cgroup2" {
+			return mnt.MountPoint, nil
+		}
+	}
+
+	return "", errors.New("cgroups v2 is not mounted")
+}
+
+// Procs returns the processes which are in a cgroup. A cgroup which has been
+// removed has no processes.
+func Procs(cgroup string) ([]int, error) {
+	f, err := os.Open(filepath.Join(cgroup, "cgroup.procs"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return procs(f)
+}
+
+func procs(f io.Reader) ([]int, error) {
+	var pids []int
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		pid, err := strconv.Atoi(strings.TrimSpace(s.Text()))
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return pids, nil
+}
+
+// OOMKills returns the number of processes in a memory cgroup which the
+// kernel has killed because the cgroup ran out of memory. It is counted in
+// memory.oom_control with cgroups v1 and in memory.events with cgroups v2. A
+// cgroup which has been removed has no kills.
+func OOMKills(cgroup string) (int, error) {
+	for _, file := range []string{"memory.oom_control", "memory.events"} {
+		f, err := os.Open(filepath.Join(cgroup, file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+
+		return oomKills(f)
+	}
+
+	return 0, nil
+}
+
+func oomKills(f io.Reader) (int, error) {
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+
+	// Kernels before 4.13 do not count the kills.
+	return 0, nil
+}
+
+// freezeTimeout is how long a cgroup is given to finish freezing. Freezing
+// waits for every process in the cgroup to reach a point where it can be
+// stopped.
+const freezeTimeout = 1 * time.Second
+
+// Freeze stops every process in a cgroup from running until it is thawed.
+// New processes cannot be forked into a frozen cgroup and so its processes
+// can be listed and killed without racing with them. Both the freezer cgroup
+// of cgroups v1 and any cgroup of cgroups v2 can be frozen.
+func Freeze(cgroup string) error {
+	v1, err := isFreezerV1(cgroup)
+	if err != nil {
+		return err
+	}
+
+	if v1 {
+		return setFreezerState(cgroup, "freezer.state", "FROZEN", func() (bool, error) {
+			state, err := ioutil.ReadFile(filepath.Join(cgroup, "freezer.state"))
+			return strings.TrimSpace(string(state)) == "FROZEN", err
+		})
+	}
+
+	return setFreezerState(cgroup, "cgroup.freeze", "1", func() (bool, error) {
+		events, err := ioutil.ReadFile(filepath.Join(cgroup, "cgroup.events"))
+		if err != nil {
+			return false, err
+		}
+
+		for _, line := range strings.Split(string(events), "\n") {
+			if strings.TrimSpace(line) == "frozen 1" {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+}
+
+// Thaw lets the processes in a frozen cgroup run again. A process which was
+// sent SIGKILL while it was frozen exits once it is thawed.
+func Thaw(cgroup string) error {
+	v1, err := isFreezerV1(cgroup)
+	if err != nil {
+		return err
+	}
+
+	if v1 {
+		return ioutil.WriteFile(filepath.Join(cgroup, "freezer.state"), []byte("THAWED"), 0)
+	}
+
+	return ioutil.WriteFile(filepath.Join(cgroup, "cgroup.freeze"), []byte("0"), 0)
+}
+
+func isFreezerV1(cgroup string) (bool, error) {
+	_, err := os.Stat(filepath.Join(cgroup, "freezer.state"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func setFreezerState(cgroup, file, state string, done func() (bool, error)) error {
+	if err := ioutil.WriteFile(filepath.Join(cgroup, file), []byte(state), 0); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		ok, err := done()
+		if err != nil || ok {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cgroup did not freeze within %s: %s", freezeTimeout, cgroup)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Stats is the resource usage of the cgroups which a process is in.
+type Stats struct {
+	MemoryBytes    uint64
+	CPUNanoseconds uint64
+	Pids           uint64
+}
+
+// ReadStats reads the resource usage of the cgroups which a process is in.
+func ReadStats(pid int) (*Stats, error) {
+	stats := &Stats{}
+
+	for _, stat := range []struct {
+		subsystem string
+		file      string
+		value     *uint64
+	}{
+		{"memory", "memory.usage_in_bytes", &stats.MemoryBytes},
+		{"cpuacct", "cpuacct.usage", &stats.CPUNanoseconds},
+		{"pids", "pids.current", &stats.Pids},
+	} {
+		cgroup, err := ProcessCgroup(pid, stat.subsystem)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readUint(filepath.Join(cgroup, stat.file))
+		if err != nil {
+			return nil, err
+		}
+		*stat.value = value
+	}
+
+	return stats, nil
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
 func mountCgroupTmpfsIfNotPresent(mnts []mount.Mnt) error {
 	for _, mnt := range mnts {
 		if mnt.MountPoint == cgroupRoot {
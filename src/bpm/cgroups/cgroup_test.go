@@ -17,10 +17,15 @@ package cgroups
 
 import (
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	"bpm/mount"
 )
 
 var _ = Describe("Cgroups", func() {
@@ -77,4 +82,173 @@ rdma	2	1	1`)
 			Expect(group).To(Equal("cpu,cpuacct"))
 		})
 	})
+
+	Describe("finding the cgroup of a process", func() {
+		var r io.Reader
+
+		BeforeEach(func() {
+			r = strings.NewReader(`11:cpu,cpuacct:/aa4575c9-58b0-4f62-540e-7bd137e5170f
+3:memory:/bpm-server
+2:pids:/bpm-server
+1:name=systemd:/system.slice/runit.service/aa4575c9-58b0-4f62-540e-7bd137e5170f`)
+		})
+
+		It("returns the directory of the cgroup in the subsystem", func() {
+			cgroup, err := processCgroup(r, "pids", "/sys/fs/cgroup/unified")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cgroup).To(Equal("/sys/fs/cgroup/pids/bpm-server"))
+		})
+
+		It("handles grouped subsystems", func() {
+			cgroup, err := processCgroup(r, "cpuacct", "/sys/fs/cgroup/unified")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cgroup).To(Equal("/sys/fs/cgroup/cpu,cpuacct/aa4575c9-58b0-4f62-540e-7bd137e5170f"))
+		})
+
+		It("returns an error if the process is not in the subsystem", func() {
+			_, err := processCgroup(r, "freezer", "/sys/fs/cgroup/unified")
+			Expect(err).To(MatchError("process is not in a freezer cgroup"))
+		})
+
+		It("returns the directory of the cgroups v2 cgroup under the mount point of the hierarchy", func() {
+			r = strings.NewReader(`3:memory:/bpm-server
+0::/system.slice/bpm-server`)
+
+			cgroup, err := processCgroup(r, "", "/sys/fs/cgroup/unified")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cgroup).To(Equal("/sys/fs/cgroup/unified/system.slice/bpm-server"))
+		})
+	})
+
+	Describe("finding the cgroups v2 hierarchy", func() {
+		It("returns the mount point of a hybrid hierarchy", func() {
+			root, err := unifiedMountPoint([]mount.Mnt{
+				{Device: "tmpfs", MountPoint: "/sys/fs/cgroup", Filesystem: "tmpfs"},
+				{Device: "cgroup2", MountPoint: "/sys/fs/cgroup/unified", Filesystem: "cgroup2"},
+				{Device: "cgroup", MountPoint: "/sys/fs/cgroup/memory", Filesystem: "cgroup"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(root).To(Equal("/sys/fs/cgroup/unified"))
+		})
+
+		It("returns the mount point of a unified hierarchy", func() {
+			root, err := unifiedMountPoint([]mount.Mnt{
+				{Device: "cgroup2", MountPoint: "/sys/fs/cgroup", Filesystem: "cgroup2"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(root).To(Equal("/sys/fs/cgroup"))
+		})
+
+		It("returns an error if cgroups v2 is not mounted", func() {
+			_, err := unifiedMountPoint([]mount.Mnt{
+				{Device: "cgroup", MountPoint: "/sys/fs/cgroup/memory", Filesystem: "cgroup"},
+			})
+			Expect(err).To(MatchError("cgroups v2 is not mounted"))
+		})
+	})
+
+	Describe("listing the processes in a cgroup", func() {
+		It("returns each pid", func() {
+			pids, err := procs(strings.NewReader("12\n345\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pids).To(Equal([]int{12, 345}))
+		})
+
+		It("returns no pids for a cgroup which has been removed", func() {
+			pids, err := Procs("/this/cgroup/does/not/exist")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pids).To(BeEmpty())
+		})
+	})
+
+	Describe("counting the processes killed for running out of memory", func() {
+		It("returns the number of kills", func() {
+			kills, err := oomKills(strings.NewReader("oom_kill_disable 0\nunder_oom 0\noom_kill 2\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kills).To(Equal(2))
+		})
+
+		It("reads the kills from the events of a cgroups v2 cgroup", func() {
+			cgroup, err := ioutil.TempDir("", "bpm-cgroup")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(cgroup)
+
+			Expect(ioutil.WriteFile(filepath.Join(cgroup, "memory.events"), []byte("low 0\nhigh 0\nmax 4\noom 1\noom_kill 1\n"), 0600)).To(Succeed())
+
+			kills, err := OOMKills(cgroup)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kills).To(Equal(1))
+		})
+
+		It("returns no kills when the cgroup has been removed", func() {
+			kills, err := OOMKills("/does/not/exist")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kills).To(BeZero())
+		})
+
+		It("returns no kills when the kernel does not count them", func() {
+			kills, err := oomKills(strings.NewReader("oom_kill_disable 0\nunder_oom 0\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kills).To(BeZero())
+		})
+	})
+
+	Describe("freezing a cgroup", func() {
+		var cgroup string
+
+		readFile := func(name string) string {
+			data, err := ioutil.ReadFile(filepath.Join(cgroup, name))
+			Expect(err).ToNot(HaveOccurred())
+			return string(data)
+		}
+
+		writeFile := func(name, contents string) {
+			Expect(ioutil.WriteFile(filepath.Join(cgroup, name), []byte(contents), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			cgroup, err = ioutil.TempDir("", "cgroup")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cgroup)).To(Succeed())
+		})
+
+		Context("with the freezer cgroup of cgroups v1", func() {
+			BeforeEach(func() {
+				writeFile("freezer.state", "THAWED\n")
+			})
+
+			It("freezes and thaws the cgroup", func() {
+				Expect(Freeze(cgroup)).To(Succeed())
+				Expect(readFile("freezer.state")).To(Equal("FROZEN"))
+
+				Expect(Thaw(cgroup)).To(Succeed())
+				Expect(readFile("freezer.state")).To(Equal("THAWED"))
+			})
+		})
+
+		Context("with cgroups v2", func() {
+			BeforeEach(func() {
+				writeFile("cgroup.freeze", "0\n")
+				writeFile("cgroup.events", "populated 1\nfrozen 1\n")
+			})
+
+			It("freezes and thaws the cgroup", func() {
+				Expect(Freeze(cgroup)).To(Succeed())
+				Expect(readFile("cgroup.freeze")).To(Equal("1"))
+
+				Expect(Thaw(cgroup)).To(Succeed())
+				Expect(readFile("cgroup.freeze")).To(Equal("0"))
+			})
+
+			It("returns an error if the cgroup does not finish freezing", func() {
+				writeFile("cgroup.events", "populated 1\nfrozen 0\n")
+
+				Expect(Freeze(cgroup)).To(MatchError(ContainSubstring("cgroup did not freeze")))
+			})
+		})
+	})
 })
@@ -0,0 +1,268 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/lager"
+
+	"bpm/api"
+	"bpm/cgroups"
+	"bpm/config"
+	"bpm/errs"
+	"bpm/models"
+	"bpm/runc/client"
+	"bpm/runc/lifecycle"
+)
+
+// controller carries out the requests made to the control API served by bpm
+// supervise. It acts on processes in the same way as the commands do and
+// takes the same lifecycle locks.
+type controller struct {
+	logger        lager.Logger
+	runcLifecycle *lifecycle.RuncLifecycle
+}
+
+func (c *controller) List() ([]api.Process, error) {
+	processes := []api.Process{}
+	index := map[string]int{}
+
+	for _, job := range bosh.JobNames() {
+		jobCfg, err := config.NewBPMConfig(bosh.Root(), job, "").ParseJobConfig()
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			c.logger.Error("invalid-config", err, lager.Data{"job": job})
+			continue
+		}
+
+		for _, procCfg := range jobCfg.Processes {
+			cfg := config.NewBPMConfig(bosh.Root(), job, procCfg.Name)
+			index[cfg.ContainerID()] = len(processes)
+			processes = append(processes, api.Process{
+				Job:     job,
+				Process: procCfg.Name,
+				Status:  models.ProcessStateStopped,
+			})
+		}
+	}
+
+	running, err := c.runcLifecycle.ListProcesses()
+	if err != nil {
+		return nil, errs.New(errs.RuntimeFailure, "failed to list jobs: %w", err)
+	}
+
+	for _, process := range running {
+		i, ok := index[process.Name]
+		if !ok {
+			continue
+		}
+
+		processes[i].Pid = process.Pid
+		processes[i].Status = process.Status
+	}
+
+	return processes, nil
+}
+
+func (c *controller) Status(job, process string) (*api.Process, error) {
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := c.runcLifecycle.StatProcess(cfg)
+	if lifecycle.IsNotExist(err) {
+		return &api.Process{Job: job, Process: process, Status: models.ProcessStateStopped}, nil
+	} else if err != nil {
+		return nil, errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
+	}
+
+	return &api.Process{Job: job, Process: process, Pid: state.Pid, Status: state.Status}, nil
+}
+
+func (c *controller) Start(job, process string) error {
+	cfg, procCfg, err := c.processConfig(job, process)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("start", lager.Data{"job": job, "process": process})
+	return withProcessLock(logger, cfg, func() error {
+		return startProcess(logger, c.runcLifecycle, cfg, procCfg)
+	})
+}
+
+func (c *controller) Stop(job, process string) error {
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("stop", lager.Data{"job": job, "process": process})
+	return withProcessLock(logger, cfg, func() error {
+		return stopProcess(logger, c.runcLifecycle, cfg, false)
+	})
+}
+
+func (c *controller) Restart(job, process string, opts api.RestartOptions) error {
+	cfg, procCfg, err := c.processConfig(job, process)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("restart", lager.Data{"job": job, "process": process})
+	return withProcessLock(logger, cfg, func() error {
+		stopped, err := stopForRestart(logger, c.runcLifecycle, cfg, procCfg, opts)
+		if err != nil || !stopped {
+			return err
+		}
+
+		return startProcess(logger, c.runcLifecycle, cfg, procCfg)
+	})
+}
+
+func (c *controller) Pause(job, process string) error {
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("pause", lager.Data{"job": job, "process": process})
+	return withProcessLock(logger, cfg, func() error {
+		return pauseProcess(logger, c.runcLifecycle, cfg)
+	})
+}
+
+func (c *controller) Resume(job, process string) error {
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("resume", lager.Data{"job": job, "process": process})
+	return withProcessLock(logger, cfg, func() error {
+		return resumeProcess(logger, c.runcLifecycle, cfg)
+	})
+}
+
+func (c *controller) Signal(job, process, signal string) error {
+	sig, err := client.ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.runningPid(cfg); err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("signal", lager.Data{"job": job, "process": process})
+	if err := c.runcLifecycle.SignalProcess(logger, cfg, sig); err != nil {
+		return errs.New(errs.RuntimeFailure, "failed to signal job-process: %w", err)
+	}
+
+	return nil
+}
+
+func (c *controller) Stats(job, process string) (*api.Stats, error) {
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return nil, err
+	}
+
+	pid, err := c.runningPid(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := cgroups.ReadStats(pid)
+	if err != nil {
+		return nil, errs.New(errs.RuntimeFailure, "failed to read resource usage: %w", err)
+	}
+
+	return &api.Stats{
+		MemoryBytes:    stats.MemoryBytes,
+		CPUNanoseconds: stats.CPUNanoseconds,
+		Pids:           stats.Pids,
+	}, nil
+}
+
+func (c *controller) Logs(job, process, stream string, lines int) ([]string, error) {
+	cfg, _, err := c.processConfig(job, process)
+	if err != nil {
+		return nil, err
+	}
+
+	path := cfg.Stdout()
+	if stream == api.Stderr {
+		path = cfg.Stderr()
+	}
+
+	return api.TailFile(path, lines)
+}
+
+// processConfig finds the configuration of a process which is defined in the
+// configuration of its job. The names come from the path of a request and so
+// nothing is read until the job is known to be on this machine.
+func (c *controller) processConfig(job, process string) (*config.BPMConfig, *config.ProcessConfig, error) {
+	if !isJobOnMachine(job) {
+		return nil, nil, errs.New(errs.ProcessNotFound, "job %q is not on this machine", job)
+	}
+
+	jobCfg, err := config.NewBPMConfig(bosh.Root(), job, "").ParseJobConfig()
+	if os.IsNotExist(err) {
+		return nil, nil, errs.New(errs.ProcessNotFound, "job %q is not configured", job)
+	} else if err != nil {
+		return nil, nil, errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	cfg := config.NewBPMConfig(bosh.Root(), job, process)
+	procCfg, err := processByNameFromJobConfig(jobCfg, process)
+	if err != nil {
+		return nil, nil, errs.New(errs.ProcessNotFound, "process %q not present in job configuration (%s)", process, cfg.JobConfig())
+	}
+
+	return cfg, procCfg, nil
+}
+
+func isJobOnMachine(job string) bool {
+	for _, name := range bosh.JobNames() {
+		if name == job {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *controller) runningPid(cfg *config.BPMConfig) (int, error) {
+	process, err := c.runcLifecycle.StatProcess(cfg)
+	if lifecycle.IsNotExist(err) || (err == nil && process.Status != models.ProcessStateRunning) {
+		return 0, errs.New(errs.ProcessNotFound, "process is not running or could not be found")
+	} else if err != nil {
+		return 0, errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
+	}
+
+	return process.Pid, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"bpm/errs"
+	"bpm/runc/lifecycle"
+)
+
+func init() {
+	diffCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	RootCmd.AddCommand(diffCommand)
+}
+
+var diffCommand = &cobra.Command{
+	Long:    "Shows the differences between the container spec a BOSH process was started with and the spec its configuration would produce now",
+	RunE:    diff,
+	Short:   "shows how the configuration of a BOSH process has changed",
+	Use:     "diff <job-name>",
+	PreRunE: diffPre,
+}
+
+func diffPre(cmd *cobra.Command, args []string) error {
+	if err := validateInput(args); err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+
+	return setupBpmLogs("diff")
+}
+
+func diff(cmd *cobra.Command, _ []string) error {
+	jobCfg, err := bpmCfg.ParseJobConfig()
+	if err != nil {
+		logger.Error("failed-to-parse-config", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	procCfg, err := processByNameFromJobConfig(jobCfg, procName)
+	if err != nil {
+		logger.Error("process-not-defined", err)
+		return errs.New(errs.ProcessNotFound, "process %q not present in job configuration (%s)", procName, bpmCfg.JobConfig())
+	}
+
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	running, err := runcLifecycle.RunningSpec(bpmCfg)
+	if lifecycle.IsNotExist(err) {
+		return errs.New(errs.ProcessNotFound, "process %q has not been started", procName)
+	} else if err != nil {
+		logger.Error("failed-to-read-spec", err)
+		return errs.New(errs.RuntimeFailure, "failed to read the spec of the job-process: %w", err)
+	}
+
+	desired, err := runcLifecycle.DesiredSpec(logger, bpmCfg, procCfg)
+	if err != nil {
+		logger.Error("failed-to-build-spec", err)
+		return errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to build the spec of the job-process: %w", err)
+	}
+
+	runningJSON, err := json.MarshalIndent(running, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	desiredJSON, err := json.MarshalIndent(desired, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	writeDiff(cmd.OutOrStdout(), "running", "configured", string(runningJSON), string(desiredJSON))
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"bpm/events"
+)
+
+var (
+	followEvents bool
+	eventsJob    string
+	eventsSince  time.Duration
+)
+
+func init() {
+	eventsCommand.Flags().BoolVarP(&followEvents, "follow", "f", false, "show new events as they are recorded")
+	eventsCommand.Flags().StringVar(&eventsJob, "job", "", "only show the events of a job")
+	eventsCommand.Flags().DurationVar(&eventsSince, "since", 0, "only show events newer than a relative duration (e.g. 12h)")
+	RootCmd.AddCommand(eventsCommand)
+}
+
+var eventsCommand = &cobra.Command{
+	Long:  "Prints the lifecycle events of the processes on this machine with one JSON object per line",
+	RunE:  showEvents,
+	Short: "prints the lifecycle events of the processes on this machine",
+	Use:   "events",
+}
+
+// eventsFilter selects the events of a job (or of every job) which are newer
+// than --since.
+func eventsFilter(job, process string) events.Filter {
+	filter := events.Filter{Job: job, Process: process}
+	if eventsSince > 0 {
+		filter.Since = time.Now().Add(-eventsSince)
+	}
+
+	return filter
+}
+
+func showEvents(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	filter := eventsFilter(eventsJob, "")
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+
+	if !followEvents {
+		recorded, err := journal.Read(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range recorded {
+			if err := encoder.Encode(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	var encodeErr error
+	err := journal.Follow(filter, stop, func(e events.Event) {
+		if encodeErr == nil {
+			encodeErr = encoder.Encode(e)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return encodeErr
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+	"bpm/errs"
+	"bpm/gc"
+	"bpm/presenters"
+)
+
+var gcDryRun bool
+
+func init() {
+	gcCommand.Flags().BoolVar(&gcDryRun, "dry-run", false, "only report what would be removed")
+	RootCmd.AddCommand(gcCommand)
+}
+
+var gcCommand = &cobra.Command{
+	Long:    "Removes the containers, bundles, pidfiles, and lock files on this machine which no longer belong to a process of a job",
+	RunE:    collectGarbage,
+	Short:   "removes what is left behind by removed jobs and interrupted commands",
+	Use:     "gc",
+	PreRunE: gcPre,
+}
+
+func gcPre(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	return setupMachineLogs(config.GCLog(bosh.Root()), "gc", lager.Data{"dry-run": gcDryRun})
+}
+
+func collectGarbage(cmd *cobra.Command, _ []string) error {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	results, err := gc.NewCollector(bosh, runcLifecycle).Collect(logger, gcDryRun)
+	if err != nil {
+		return errs.New(errs.RuntimeFailure, "failed to find strays: %w", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "nothing to remove")
+		return nil
+	}
+
+	if err := presenters.PrintStrays(results, cmd.OutOrStdout()); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errs.New(errs.RuntimeFailure, "failed to remove %d of %d strays", failed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"bpm/events"
+	"bpm/presenters"
+)
+
+func init() {
+	historyCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	historyCommand.Flags().DurationVar(&eventsSince, "since", 0, "only show events newer than a relative duration (e.g. 12h)")
+	RootCmd.AddCommand(historyCommand)
+}
+
+var historyCommand = &cobra.Command{
+	Long:  "Shows the lifecycle events of a job and a summary of how often each of its processes has started, stopped, and crashed",
+	RunE:  history,
+	Short: "shows the lifecycle history of a job",
+	Use:   "history <job-name>",
+}
+
+func history(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.New("must specify a job")
+	}
+
+	cmd.SilenceUsage = true
+
+	recorded, err := journal.Read(eventsFilter(args[0], procName))
+	if err != nil {
+		return err
+	}
+
+	if err := presenters.PrintEvents(recorded, cmd.OutOrStdout()); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout())
+	return presenters.PrintHistory(events.Summarize(recorded), cmd.OutOrStdout())
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"bpm/api"
 	"bpm/config"
 	"bpm/models"
 	"bpm/presenters"
@@ -39,6 +40,10 @@ var listCommandCommand = &cobra.Command{
 func listContainers(cmd *cobra.Command, _ []string) error {
 	cmd.SilenceUsage = true
 
+	if apiClient := connectToSupervisor(); apiClient != nil {
+		return listFromSupervisor(cmd, apiClient)
+	}
+
 	processes := []*models.Process{}
 	for _, job := range bosh.JobNames() {
 		bpmCfg := config.NewBPMConfig(bosh.Root(), job, "")
@@ -87,6 +92,32 @@ func listContainers(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// listFromSupervisor lists the processes which bpm supervise knows about.
+func listFromSupervisor(cmd *cobra.Command, apiClient *api.Client) error {
+	supervised, err := apiClient.List()
+	if err != nil {
+		fmt.Fprintf(cmd.OutOrStderr(), "failed to list jobs: %s\n", err.Error())
+		return err
+	}
+
+	processes := []*models.Process{}
+	for _, p := range supervised {
+		processes = append(processes, &models.Process{
+			Name:   config.NewBPMConfig(bosh.Root(), p.Job, p.Process).ContainerID(),
+			Pid:    p.Pid,
+			Status: p.Status,
+		})
+	}
+
+	err = presenters.PrintJobs(processes, cmd.OutOrStdout())
+	if err != nil {
+		fmt.Fprintf(cmd.OutOrStderr(), "failed to display jobs: %s\n", err.Error())
+		return err
+	}
+
+	return nil
+}
+
 func updateProcess(processes []*models.Process, process *models.Process) ([]*models.Process, error) {
 	for i := range processes {
 		if processes[i].Name == process.Name {
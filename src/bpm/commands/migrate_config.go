@@ -0,0 +1,80 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+	"bpm/errs"
+)
+
+var migrateWrite bool
+
+func init() {
+	migrateConfigCommand.Flags().BoolVar(&migrateWrite, "write", false, "replace the configuration file rather than printing the migrated configuration")
+	RootCmd.AddCommand(migrateConfigCommand)
+}
+
+var migrateConfigCommand = &cobra.Command{
+	Annotations: offlineAnnotations,
+	Long:        "Rewrites a job configuration file in the latest version of the configuration format. Comments and formatting are kept.",
+	RunE:        migrateConfig,
+	Short:       "migrates a job configuration file to the latest version",
+	Use:         "migrate-config <path-to-bpm.yml>",
+}
+
+func migrateConfig(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.New("must specify a configuration file")
+	}
+
+	cmd.SilenceUsage = true
+
+	path := args[0]
+	info, err := os.Stat(path)
+	if err != nil {
+		return errs.New(errs.ConfigInvalid, "failed to read job configuration: %w", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errs.New(errs.ConfigInvalid, "failed to read job configuration: %w", err)
+	}
+
+	migrated, err := config.MigrateJobConfig(data)
+	if err != nil {
+		return errs.New(errs.ConfigInvalid, "%w", err)
+	}
+
+	if !migrateWrite {
+		_, err := cmd.OutOrStdout().Write(migrated)
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, migrated, info.Mode()); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s: migrated to version %d\n", path, config.LatestVersion)
+
+	return nil
+}
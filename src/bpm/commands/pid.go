@@ -16,11 +16,11 @@
 package commands
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
 
+	"bpm/errs"
 	"bpm/models"
 	"bpm/runc/lifecycle"
 )
@@ -44,15 +44,29 @@ func pidPre(cmd *cobra.Command, args []string) error {
 func pidForJob(cmd *cobra.Command, _ []string) error {
 	cmd.SilenceUsage = true
 
+	if apiClient := connectToSupervisor(); apiClient != nil {
+		process, err := apiClient.Status(bpmCfg.JobName(), procName)
+		if err != nil {
+			return err
+		}
+
+		if process.Status != models.ProcessStateRunning && process.Status != models.ProcessStatePaused {
+			return errs.New(errs.ProcessNotFound, "process is not running or could not be found")
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d\n", process.Pid)
+		return nil
+	}
+
 	runcLifecycle, err := newRuncLifecycle()
 	if err != nil {
 		return err
 	}
 	process, err := runcLifecycle.StatProcess(bpmCfg)
 	if lifecycle.IsNotExist(err) || process.Status == models.ProcessStateFailed {
-		return errors.New("process is not running or could not be found")
+		return errs.New(errs.ProcessNotFound, "process is not running or could not be found")
 	} else if err != nil {
-		return fmt.Errorf("failed to get job: %s", err)
+		return errs.New(errs.RuntimeFailure, "failed to get job: %w", err)
 	}
 
 	fmt.Fprintf(cmd.OutOrStdout(), "%d\n", process.Pid)
@@ -0,0 +1,261 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+	"bpm/errs"
+	"bpm/runc/adapter"
+	"bpm/sysfeat"
+	"bpm/usertools"
+)
+
+// diffContext is the number of unchanged lines shown around each change when
+// comparing two rendered specs.
+const diffContext = 3
+
+var (
+	renderBoshRoot   string
+	renderConfigPath string
+	renderDiffPath   string
+)
+
+func init() {
+	renderCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	renderCommand.Flags().StringArrayVarP(&volumes, "volume", "v", []string{}, "Optional list of volumes (format: <path>[:<options>])")
+	renderCommand.Flags().StringArrayVarP(&env, "env", "e", []string{}, "Additional environment variables (format: KEY=VALUE")
+	renderCommand.Flags().StringVar(&renderBoshRoot, "bosh-root", bosh.Root(), "the BOSH root the configuration will be deployed to")
+	renderCommand.Flags().StringVar(&renderConfigPath, "config", "", "the job configuration to render (defaults to the configuration of the job in the BOSH root)")
+	renderCommand.Flags().StringVar(&renderDiffPath, "diff", "", "a second job configuration to compare the rendered spec with")
+	RootCmd.AddCommand(renderCommand)
+}
+
+var renderCommand = &cobra.Command{
+	Annotations: offlineAnnotations,
+	Long:        "Prints the OCI runtime spec which a process would be started with. This does not need root privileges and does not inspect or change the running system.",
+	RunE:        render,
+	Short:       "prints the container spec for a BOSH process",
+	Use:         "render <job-name>",
+}
+
+func render(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.New("must specify a job")
+	}
+
+	cmd.SilenceUsage = true
+
+	jobName := args[0]
+	if procName == "" {
+		procName = jobName
+	}
+	renderCfg := config.NewBPMConfig(renderBoshRoot, jobName, procName)
+
+	configPath := renderConfigPath
+	if configPath == "" {
+		configPath = renderCfg.JobConfig()
+	}
+
+	features, err := sysfeat.Fetch()
+	if err != nil {
+		fmt.Fprintf(cmd.OutOrStderr(), "warning: failed to fetch system features, assuming defaults: %s\n", err)
+		features = &sysfeat.Features{}
+	}
+
+	user, err := userFinder.Lookup(usertools.VcapUser)
+	if err != nil {
+		fmt.Fprintf(cmd.OutOrStderr(), "warning: failed to look up the %s user, its uid and gid are not rendered: %s\n", usertools.VcapUser, err)
+		user = specs.User{Username: usertools.VcapUser}
+	}
+
+	runcAdapter := adapter.NewRuncAdapter(*features)
+	renderSpec := func(path string) ([]byte, error) {
+		spec, err := renderProcessSpec(runcAdapter, renderCfg, path, user)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.MarshalIndent(spec, "", "  ")
+	}
+
+	rendered, err := renderSpec(configPath)
+	if err != nil {
+		return err
+	}
+
+	if renderDiffPath == "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n", rendered)
+		return nil
+	}
+
+	other, err := renderSpec(renderDiffPath)
+	if err != nil {
+		return err
+	}
+
+	writeDiff(cmd.OutOrStdout(), configPath, renderDiffPath, string(rendered), string(other))
+
+	return nil
+}
+
+// renderProcessSpec builds the spec for the process in the job configuration
+// at path in the same way as it is built when the process is started.
+func renderProcessSpec(
+	runcAdapter *adapter.RuncAdapter,
+	renderCfg *config.BPMConfig,
+	path string,
+	user specs.User,
+) (specs.Spec, error) {
+	jobCfg, err := config.ParseJobConfig(path)
+	if err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "failed to parse job configuration (%s): %w", path, err)
+	}
+
+	if err := jobCfg.Interpolate(renderCfg.Variables()); err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "%s: %w", path, err)
+	}
+
+	if err := jobCfg.Validate(renderBoshRoot, renderCfg.DefaultVolumes()); err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "%s: %w", path, err)
+	}
+
+	procCfg, err := processByNameFromJobConfig(jobCfg, renderCfg.ProcName())
+	if err != nil {
+		return specs.Spec{}, errs.New(errs.ProcessNotFound, "process %q not present in job configuration (%s)", renderCfg.ProcName(), path)
+	}
+
+	if err := procCfg.AddVolumes(volumes, renderBoshRoot, renderCfg.DefaultVolumes()); err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "invalid volume definition: %w", err)
+	}
+
+	if err := procCfg.AddEnvVars(env, renderBoshRoot, renderCfg.DefaultVolumes()); err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "invalid environment definition: %w", err)
+	}
+
+	spec, err := runcAdapter.BuildSpec(lager.NewLogger("bpm"), renderCfg, procCfg, user)
+	if err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "failed to build spec (%s): %w", path, err)
+	}
+
+	return spec, nil
+}
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// writeDiff writes the differences between two documents in the unified diff
+// format. Changes which are close together are shown in the same hunk with a
+// few lines of context around them.
+func writeDiff(w io.Writer, fromName, toName, from, to string) {
+	ops := diffLines(strings.Split(from, "\n"), strings.Split(to, "\n"))
+
+	var changed []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", fromName, toName)
+
+	shown := -1
+	for _, i := range changed {
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		if shown >= 0 && start <= shown {
+			start = shown + 1
+		} else {
+			fmt.Fprintln(w, "@@")
+		}
+
+		end := i + diffContext
+		if end >= len(ops) {
+			end = len(ops) - 1
+		}
+
+		for j := start; j <= end; j++ {
+			fmt.Fprintf(w, "%c%s\n", ops[j].kind, ops[j].line)
+		}
+		shown = end
+	}
+}
+
+// diffLines finds the shortest edit which turns a into b using their longest
+// common subsequence.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+	"bpm/errs"
+	"bpm/runc/lifecycle"
+)
+
+func init() {
+	RootCmd.AddCommand(repairCommand)
+}
+
+var repairCommand = &cobra.Command{
+	Long:    "Removes the container state of every process on this machine which no longer describes a live process (e.g. after an unclean reboot)",
+	RunE:    repair,
+	Short:   "repairs stale container state after an unclean reboot",
+	Use:     "repair",
+	PreRunE: repairPre,
+}
+
+func repairPre(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	return setupMachineLogs(config.RepairLog(bosh.Root()), "repair")
+}
+
+func repair(cmd *cobra.Command, _ []string) error {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	var repaired, failed int
+	processes := machineProcesses()
+
+	for _, p := range processes {
+		name := fmt.Sprintf("%s/%s", p.Config.JobName(), p.Config.ProcName())
+
+		reason, err := repairProcess(logger, runcLifecycle, p.Config)
+		if err != nil {
+			logger.Error("failed-to-repair", err, lager.Data{"job": p.Config.JobName(), "process": p.Config.ProcName()})
+			fmt.Fprintf(cmd.OutOrStderr(), "failed to repair %s: %s\n", name, err)
+			failed++
+			continue
+		}
+
+		if reason != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "repaired %s: %s\n", name, reason)
+			repaired++
+		}
+	}
+
+	if failed > 0 {
+		return errs.New(errs.RuntimeFailure, "failed to repair %d of %d processes", failed, len(processes))
+	}
+
+	if repaired == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "nothing to repair")
+	}
+
+	return nil
+}
+
+// repairProcess repairs the container of a process while holding its
+// lifecycle lock.
+func repairProcess(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, cfg *config.BPMConfig) (string, error) {
+	var reason string
+
+	l := logger.WithData(lager.Data{"job": cfg.JobName(), "process": cfg.ProcName()})
+	err := withProcessLock(l, cfg, func() error {
+		var err error
+		reason, err = runcLifecycle.RepairProcess(l, cfg)
+		return err
+	})
+
+	return reason, err
+}
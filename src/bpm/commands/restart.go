@@ -0,0 +1,225 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/cobra"
+
+	"bpm/api"
+	"bpm/config"
+	"bpm/errs"
+	"bpm/models"
+	"bpm/runc/lifecycle"
+)
+
+var waitForCgroup bool
+
+func init() {
+	restartCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	restartCommand.Flags().BoolVar(&allProcesses, "all", false, "restart every process in the job")
+	restartCommand.Flags().BoolVar(&restartIfChanged, "if-changed", false, "only restart the process if it is running with an outdated configuration")
+	restartCommand.Flags().BoolVar(&waitForCgroup, "wait-cgroup-empty", false, "wait for every process in the old container to exit before starting the new one")
+	RootCmd.AddCommand(restartCommand)
+}
+
+var restartCommand = &cobra.Command{
+	RunE:     restart,
+	Short:    "restarts a BOSH Process",
+	Use:      "restart <job-name>",
+	PreRunE:  restartPre,
+	PostRunE: restartPost,
+}
+
+func restartPre(cmd *cobra.Command, args []string) error {
+	if err := validateAllInput(args); err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+
+	if err := setupBpmLogs("restart"); err != nil {
+		return err
+	}
+
+	// The supervisor takes the lifecycle lock itself.
+	if !allProcesses {
+		daemon = connectToSupervisor()
+	}
+
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
+	return acquireLifecycleLock()
+}
+
+func restartPost(cmd *cobra.Command, args []string) error {
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
+	return releaseLifecycleLock()
+}
+
+func restart(cmd *cobra.Command, _ []string) error {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	opts := api.RestartOptions{IfChanged: restartIfChanged, WaitCgroupEmpty: waitForCgroup}
+
+	if daemon != nil {
+		logger.Info("using-supervisor")
+		return daemon.Restart(bpmCfg.JobName(), procName, opts)
+	}
+
+	jobCfg, err := bpmCfg.ParseJobConfig()
+	if err != nil {
+		logger.Error("failed-to-parse-config", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	logConfigWarnings(jobCfg)
+
+	if allProcesses {
+		return restartAllProcesses(jobCfg, opts)
+	}
+
+	procCfg, err := processByNameFromJobConfig(jobCfg, procName)
+	if err != nil {
+		logger.Error("process-not-defined", err)
+		return errs.New(errs.ProcessNotFound, "process %q not present in job configuration (%s)", procName, bpmCfg.JobConfig())
+	}
+
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	stopped, err := stopForRestart(logger, runcLifecycle, bpmCfg, procCfg, opts)
+	if err != nil || !stopped {
+		return err
+	}
+
+	return startProcess(logger, runcLifecycle, bpmCfg, procCfg)
+}
+
+// restartAllProcesses restarts every process in the job. The lock of every
+// process is held from before the first process is stopped until after the
+// last process is started. The processes are stopped and then started in the
+// same order as stop --all and start --all.
+func restartAllProcesses(jobCfg *config.JobConfig, opts api.RestartOptions) error {
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	// The locks are always taken in the order the processes are configured
+	// in so that two commands which lock the whole job cannot deadlock.
+	for _, procCfg := range jobCfg.Processes {
+		cfg := config.NewBPMConfig(bosh.Root(), bpmCfg.JobName(), procCfg.Name)
+		l := logger.WithData(lager.Data{"process": procCfg.Name})
+
+		f, err := lockProcess(l, cfg)
+		if err != nil {
+			return err
+		}
+		defer func(f *os.File) {
+			if err := unlockProcess(l, cfg, f); err != nil {
+				l.Error("failed-to-release-lock", err)
+			}
+		}(f)
+	}
+
+	var mu sync.Mutex
+	stopped := map[string]bool{}
+
+	failures := forEachProcess(jobCfg, true, func(procCfg *config.ProcessConfig) error {
+		cfg := config.NewBPMConfig(bosh.Root(), bpmCfg.JobName(), procCfg.Name)
+		l := logger.WithData(lager.Data{"process": procCfg.Name})
+
+		ok, err := stopForRestart(l, runcLifecycle, cfg, procCfg, opts)
+
+		mu.Lock()
+		stopped[procCfg.Name] = ok
+		mu.Unlock()
+
+		return err
+	})
+
+	failures = append(failures, forEachProcess(jobCfg, false, func(procCfg *config.ProcessConfig) error {
+		mu.Lock()
+		ok := stopped[procCfg.Name]
+		mu.Unlock()
+
+		if !ok {
+			return nil
+		}
+
+		cfg := config.NewBPMConfig(bosh.Root(), bpmCfg.JobName(), procCfg.Name)
+		l := logger.WithData(lager.Data{"process": procCfg.Name})
+
+		if err := waitForDependencies(l, runcLifecycle, procCfg); err != nil {
+			return err
+		}
+
+		return startProcess(l, runcLifecycle, cfg, procCfg)
+	})...)
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to restart %d of %d processes:\n%w", len(failures), len(jobCfg.Processes), errs.Combine(failures))
+	}
+
+	return nil
+}
+
+// stopForRestart stops and removes a process so that it can be started
+// again. It returns false if the process should be left alone because it is
+// running with an up to date configuration and only changed processes are
+// being restarted.
+func stopForRestart(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig, opts api.RestartOptions) (bool, error) {
+	process, err := runcLifecycle.StatProcess(bpmCfg)
+	if lifecycle.IsNotExist(err) {
+		logger.Info("process-not-running")
+		return true, nil
+	} else if err != nil {
+		logger.Error("failed-to-get-job", err)
+		return false, errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
+	}
+
+	if opts.IfChanged && (process.Status == models.ProcessStateRunning || process.Status == models.ProcessStatePaused) {
+		changed, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+		if err != nil && !lifecycle.IsNotExist(err) {
+			logger.Error("failed-to-compare-spec", err)
+			return false, errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to compare job-process configuration: %w", err)
+		}
+
+		if !changed {
+			logger.Info("process-unchanged")
+			return false, nil
+		}
+	}
+
+	if err := stopProcess(logger, runcLifecycle, bpmCfg, opts.WaitCgroupEmpty); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
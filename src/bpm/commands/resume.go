@@ -0,0 +1,140 @@
+// Copyright (C) 2017-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+	"bpm/errs"
+	"bpm/models"
+	"bpm/runc/lifecycle"
+)
+
+func init() {
+	resumeCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	resumeCommand.Flags().BoolVar(&allProcesses, "all", false, "resume every process in the job")
+	RootCmd.AddCommand(resumeCommand)
+}
+
+var resumeCommand = &cobra.Command{
+	Long:     "Thaws every process in the container of a BOSH process which has been paused",
+	RunE:     resume,
+	Short:    "resumes a paused BOSH Process",
+	Use:      "resume <job-name>",
+	PreRunE:  resumePre,
+	PostRunE: resumePost,
+}
+
+func resumePre(cmd *cobra.Command, args []string) error {
+	if err := validateAllInput(args); err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+
+	if err := setupBpmLogs("resume"); err != nil {
+		return err
+	}
+
+	// The supervisor takes the lifecycle lock itself.
+	if !allProcesses {
+		daemon = connectToSupervisor()
+	}
+
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
+	return acquireLifecycleLock()
+}
+
+func resumePost(cmd *cobra.Command, args []string) error {
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
+	return releaseLifecycleLock()
+}
+
+func resume(cmd *cobra.Command, _ []string) error {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	if daemon != nil {
+		logger.Info("using-supervisor")
+		return daemon.Resume(bpmCfg.JobName(), procName)
+	}
+
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	if !allProcesses {
+		return resumeProcess(logger, runcLifecycle, bpmCfg)
+	}
+
+	jobCfg, err := bpmCfg.ParseJobConfig()
+	if err != nil {
+		logger.Error("failed-to-parse-config", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	failures := forEachProcess(jobCfg, false, func(procCfg *config.ProcessConfig) error {
+		cfg := config.NewBPMConfig(bosh.Root(), bpmCfg.JobName(), procCfg.Name)
+		l := logger.WithData(lager.Data{"process": procCfg.Name})
+
+		return withProcessLock(l, cfg, func() error {
+			return resumeProcess(l, runcLifecycle, cfg)
+		})
+	})
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to resume %d of %d processes:\n%w", len(failures), len(jobCfg.Processes), errs.Combine(failures))
+	}
+
+	return nil
+}
+
+func resumeProcess(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, bpmCfg *config.BPMConfig) error {
+	process, err := runcLifecycle.StatProcess(bpmCfg)
+	if lifecycle.IsNotExist(err) {
+		return errs.New(errs.ProcessNotFound, "process is not running or could not be found")
+	} else if err != nil {
+		logger.Error("failed-to-get-job", err)
+		return errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
+	}
+
+	switch process.Status {
+	case models.ProcessStateRunning:
+		logger.Info("process-not-paused")
+		return nil
+	case models.ProcessStatePaused:
+	default:
+		return errs.New(errs.ProcessNotFound, "process is not running or could not be found")
+	}
+
+	if err := runcLifecycle.ResumeProcess(logger, bpmCfg); err != nil {
+		logger.Error("failed-to-resume", err)
+		return errs.New(errs.RuntimeFailure, "failed to resume job-process: %w", err)
+	}
+
+	return nil
+}
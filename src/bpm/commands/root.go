@@ -20,14 +20,21 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"time"
 
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
 
+	"bpm/api"
 	"bpm/cgroups"
 	"bpm/config"
+	"bpm/errs"
+	"bpm/events"
+	"bpm/models"
+	"bpm/procwatch"
 	"bpm/runc/adapter"
 	"bpm/runc/client"
 	"bpm/runc/lifecycle"
@@ -36,14 +43,25 @@ import (
 )
 
 var (
-	bpmCfg      *config.BPMConfig
-	logger      lager.Logger
-	procName    string
-	showVersion bool
+	bpmCfg        *config.BPMConfig
+	logger        lager.Logger
+	procName      string
+	allProcesses  bool
+	showVersion   bool
+	lifecycleLock *os.File
+
+	// daemon is the control API of bpm supervise. It is set when a command
+	// is carried out by the supervisor rather than by the command itself.
+	daemon *api.Client
 )
 
+// offlineAnnotations marks commands which only read their arguments. They do
+// not need to be run as root and do not set up the system.
+var offlineAnnotations = map[string]string{"offline": "true"}
+
 var userFinder = usertools.NewUserFinder()
 var bosh = config.NewBosh(os.Getenv("BPM_BOSH_ROOT"))
+var journal = events.NewJournal(config.EventsJournal(bosh.Root()), clock.NewClock())
 
 func init() {
 	RootCmd.PersistentFlags().BoolVar(&showVersion, "version", false, "print BPM version")
@@ -64,6 +82,10 @@ func rootPre(cmd *cobra.Command, _ []string) error {
 		os.Exit(0)
 	}
 
+	if cmd.Annotations["offline"] == "true" {
+		return nil
+	}
+
 	usr, err := user.Current()
 	if err != nil {
 		return err
@@ -71,7 +93,7 @@ func rootPre(cmd *cobra.Command, _ []string) error {
 
 	if usr.Uid != "0" && usr.Gid != "0" {
 		cmd.SilenceUsage = true
-		return errors.New("bpm must be run as root. Please run 'sudo -i' to become the root user.")
+		return errs.New(errs.PermissionDenied, "bpm must be run as root. Please run 'sudo -i' to become the root user.")
 	}
 
 	return cgroups.Setup()
@@ -97,6 +119,35 @@ func validateInput(args []string) error {
 	return nil
 }
 
+// validateAllInput validates the arguments of a command which can act on
+// every process in the job with --all.
+func validateAllInput(args []string) error {
+	if allProcesses && procName != "" {
+		return errors.New("cannot specify a process with --all")
+	}
+
+	return validateInput(args)
+}
+
+// setupMachineLogs sets up the logger of a command which acts on every job on
+// the machine rather than on a single process.
+func setupMachineLogs(logPath, sessionName string, data ...lager.Data) error {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0750); err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	logger = lager.NewLogger("bpm")
+	logger.RegisterSink(lager.NewPrettySink(logFile, lager.INFO))
+	logger = logger.Session(sessionName, data...)
+
+	return nil
+}
+
 func setupBpmLogs(sessionName string) error {
 	err := os.MkdirAll(bpmCfg.LogDir(), 0750)
 	if err != nil {
@@ -128,38 +179,80 @@ func setupBpmLogs(sessionName string) error {
 	return nil
 }
 
+// connectToSupervisor returns a client for the control API if bpm supervise
+// is running and nil if it is not.
+func connectToSupervisor() *api.Client {
+	c := api.NewClient(config.SupervisorSocket(bosh.Root()))
+	if err := c.Ping(); err != nil {
+		return nil
+	}
+
+	return c
+}
+
 func acquireLifecycleLock() error {
+	f, err := lockProcess(logger, bpmCfg)
+	if err != nil {
+		return err
+	}
+
+	lifecycleLock = f
+	return nil
+}
+
+func releaseLifecycleLock() error {
+	return unlockProcess(logger, bpmCfg, lifecycleLock)
+}
+
+// lockProcess takes the lifecycle lock of a process so that no other bpm
+// command can change it at the same time.
+func lockProcess(logger lager.Logger, cfg *config.BPMConfig) (*os.File, error) {
 	l := logger.Session("acquiring-lifecycle-lock")
 	l.Info("starting")
 	defer l.Info("complete")
 
-	err := os.MkdirAll(bpmCfg.PidDir(), 0700)
+	err := os.MkdirAll(cfg.PidDir(), 0700)
 	if err != nil {
 		l.Error("failed-to-create-lock-dir", err)
-		return err
+		return nil, err
 	}
 
-	f, err := os.OpenFile(bpmCfg.LockFile(), os.O_CREATE|os.O_RDWR, 0600)
+	f, err := os.OpenFile(cfg.LockFile(), os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
 		l.Error("failed-to-create-lock-file", err)
-		return err
+		return nil, err
 	}
 
-	err = unix.Flock(int(f.Fd()), unix.LOCK_EX)
+	// The lock is tried without waiting first so that only the commands
+	// which had to wait for another are recorded in the journal.
+	err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		l.Info("waiting-for-lock")
+		waitStart := time.Now()
+		err = unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err == nil {
+			recordEvent(l, cfg, events.Event{Type: events.LockWaited, Waited: time.Since(waitStart).String()})
+		}
+	}
 	if err != nil {
 		l.Error("failed-to-acquire-lock", err)
-		return err
+		f.Close()
+		return nil, errs.New(errs.LockFailure, "failed to acquire lifecycle lock: %w", err)
 	}
 
-	return nil
+	return f, nil
 }
 
-func releaseLifecycleLock() error {
+func unlockProcess(logger lager.Logger, cfg *config.BPMConfig, f *os.File) error {
 	l := logger.Session("releasing-lifecycle-lock")
 	l.Info("starting")
 	defer l.Info("complete")
 
-	err := os.RemoveAll(bpmCfg.LockFile())
+	if f != nil {
+		defer f.Close()
+	}
+
+	err := os.RemoveAll(cfg.LockFile())
 	if err != nil {
 		l.Error("failed-to-remove-lock-file", err)
 		return err
@@ -168,6 +261,117 @@ func releaseLifecycleLock() error {
 	return nil
 }
 
+// withProcessLock runs fn while holding the lifecycle lock of a process.
+func withProcessLock(logger lager.Logger, cfg *config.BPMConfig, fn func() error) error {
+	f, err := lockProcess(logger, cfg)
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn()
+	if err := unlockProcess(logger, cfg, f); err != nil && fnErr == nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// forEachProcess runs fn for every process in the job at the same time
+// except where one process depends on another. Unless reverse is set, fn is
+// run for a process once it has succeeded for every process it depends on. If
+// reverse is set, fn is run for a process once it has finished for every
+// process which depends on it. The failures are returned in the order that
+// the processes are configured in.
+func forEachProcess(jobCfg *config.JobConfig, reverse bool, fn func(*config.ProcessConfig) error) []error {
+	procs := jobCfg.Processes
+
+	index := map[string]int{}
+	for i, p := range procs {
+		index[p.Name] = i
+	}
+
+	waitFor := make([][]int, len(procs))
+	for i, p := range procs {
+		for _, dep := range p.DependsOn {
+			j, ok := index[dep.Process]
+			if !ok {
+				continue
+			}
+
+			if reverse {
+				waitFor[j] = append(waitFor[j], i)
+			} else {
+				waitFor[i] = append(waitFor[i], j)
+			}
+		}
+	}
+
+	results := make([]error, len(procs))
+	done := make([]chan struct{}, len(procs))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	for i := range procs {
+		go func(i int) {
+			defer close(done[i])
+
+			for _, j := range waitFor[i] {
+				<-done[j]
+
+				if !reverse && results[j] != nil {
+					results[i] = errs.New(errs.KindOf(results[j], errs.RuntimeFailure), "%s: skipped because %s failed", procs[i].Name, procs[j].Name)
+					return
+				}
+			}
+
+			if err := fn(procs[i]); err != nil {
+				results[i] = fmt.Errorf("%s: %w", procs[i].Name, err)
+			}
+		}(i)
+	}
+
+	var failures []error
+	for i := range procs {
+		<-done[i]
+
+		if results[i] != nil {
+			failures = append(failures, results[i])
+		}
+	}
+
+	return failures
+}
+
+// recordEvent adds an event about a process to the journal. Failing to
+// record an event does not fail the command.
+func recordEvent(logger lager.Logger, cfg *config.BPMConfig, e events.Event) {
+	e.Job = cfg.JobName()
+	e.Process = cfg.ProcName()
+
+	if err := journal.Record(e); err != nil {
+		logger.Error("failed-to-record-event", err, lager.Data{"event": e.Type})
+	}
+}
+
+// recordCrash adds the exit of a process which has stopped without being
+// asked to the journal. Unless bpm supervise is running, this is the first
+// time that bpm learns of the exit. Its exit status is gone by now but the
+// memory cgroup of its container still records whether it ran out of memory
+// and so it must be called before the container is removed.
+func recordCrash(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, cfg *config.BPMConfig, process *models.Process) {
+	if process == nil || process.Status != models.ProcessStateFailed {
+		return
+	}
+
+	oomKilled, err := runcLifecycle.OOMKilled(cfg)
+	if err != nil {
+		logger.Error("failed-to-read-oom-kills", err)
+	}
+
+	recordEvent(logger, cfg, events.Event{Type: events.Exited, Pid: process.Pid, OOMKilled: oomKilled})
+}
+
 func newRuncLifecycle() (*lifecycle.RuncLifecycle, error) {
 	runcClient := client.NewRuncClient(
 		config.RuncPath(bosh.Root()),
@@ -175,7 +379,7 @@ func newRuncLifecycle() (*lifecycle.RuncLifecycle, error) {
 	)
 	features, err := sysfeat.Fetch()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch system features: %q", err)
+		return nil, errs.New(errs.RuntimeFailure, "failed to fetch system features: %q", err)
 	}
 	runcAdapter := adapter.NewRuncAdapter(*features)
 	clock := clock.NewClock()
@@ -185,11 +389,25 @@ func newRuncLifecycle() (*lifecycle.RuncLifecycle, error) {
 		runcAdapter,
 		userFinder,
 		lifecycle.NewCommandRunner(),
+		procwatch.NewWatcher(),
 		clock,
 		os.Remove,
+		journal,
 	), nil
 }
 
+// logConfigWarnings records the problems in the job configuration which only
+// bpm validate rejects so that release authors can find them.
+func logConfigWarnings(jobCfg *config.JobConfig) {
+	for _, warning := range jobCfg.Warnings() {
+		logger.Info("config-warning", lager.Data{
+			"path":    warning.Path,
+			"line":    warning.Line,
+			"message": warning.Message,
+		})
+	}
+}
+
 func processByNameFromJobConfig(jobCfg *config.JobConfig, procName string) (*config.ProcessConfig, error) {
 	for _, processConfig := range jobCfg.Processes {
 		if processConfig.Name == procName {
@@ -197,5 +415,5 @@ func processByNameFromJobConfig(jobCfg *config.JobConfig, procName string) (*con
 		}
 	}
 
-	return nil, fmt.Errorf("invalid process: %s", procName)
+	return nil, errs.New(errs.ProcessNotFound, "invalid process: %s", procName)
 }
@@ -17,10 +17,15 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/spf13/cobra"
 
 	"bpm/config"
+	"bpm/errs"
 	"bpm/exitstatus"
 	"bpm/models"
 	"bpm/runc/lifecycle"
@@ -32,12 +37,21 @@ var (
 
 	// Environment variables which come from command-line flags.
 	env []string
+
+	// Files of environment variables which come from command-line flags.
+	envFiles []string
+
+	// Names of environment variables to copy from the environment of bpm
+	// which come from command-line flags.
+	passEnv []string
 )
 
 func init() {
 	runCommand.Flags().StringVarP(&procName, "process", "p", "", "the optional process name")
 	runCommand.Flags().StringArrayVarP(&volumes, "volume", "v", []string{}, "Optional list of volumes (format: <path>[:<options>])")
 	runCommand.Flags().StringArrayVarP(&env, "env", "e", []string{}, "Additional environment variables (format: KEY=VALUE")
+	runCommand.Flags().StringArrayVar(&envFiles, "env-file", []string{}, "Files of additional environment variables (format: KEY=VALUE on each line)")
+	runCommand.Flags().StringArrayVar(&passEnv, "pass-env", []string{}, "Environment variables to copy from the current environment (format: KEY)")
 	RootCmd.AddCommand(runCommand)
 }
 
@@ -74,13 +88,20 @@ func run(cmd *cobra.Command, _ []string) error {
 	jobCfg, err := config.ParseJobConfig(bpmCfg.JobConfig())
 	if err != nil {
 		logger.Error("failed-to-parse-config", err)
-		return fmt.Errorf("failed to parse job configuration: %s", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	logConfigWarnings(jobCfg)
+
+	if err := jobCfg.Interpolate(bpmCfg.Variables()); err != nil {
+		logger.Error("failed-to-interpolate-config", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
 	}
 
 	procCfg, err := processByNameFromJobConfig(jobCfg, procName)
 	if err != nil {
 		logger.Error("process-not-defined", err)
-		return fmt.Errorf("process %q not present in job configuration (%s)", procName, bpmCfg.JobConfig())
+		return errs.New(errs.ProcessNotFound, "process %q not present in job configuration (%s)", procName, bpmCfg.JobConfig())
 	}
 
 	if err = procCfg.AddVolumes(volumes, bosh.Root(), bpmCfg.DefaultVolumes()); err != nil {
@@ -88,7 +109,16 @@ func run(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	if err = procCfg.AddEnvVars(env, bosh.Root(), bpmCfg.DefaultVolumes()); err != nil {
+	fileEnv, err := readEnvFiles(envFiles)
+	if err != nil {
+		logger.Error("failed-to-read-env-file", err)
+		return err
+	}
+
+	procEnv := append(fileEnv, passedEnv(passEnv)...)
+	procEnv = append(procEnv, env...)
+
+	if err = procCfg.AddEnvVars(procEnv, bosh.Root(), bpmCfg.DefaultVolumes()); err != nil {
 		logger.Error("invalid-environment-definition", err)
 		return err
 	}
@@ -100,7 +130,7 @@ func run(cmd *cobra.Command, _ []string) error {
 	process, err := runcLifecycle.StatProcess(bpmCfg)
 	if err != nil && !lifecycle.IsNotExist(err) {
 		logger.Error("failed-getting-job", err)
-		return err
+		return errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
 	}
 
 	var state string
@@ -109,24 +139,68 @@ func run(cmd *cobra.Command, _ []string) error {
 	}
 
 	switch state {
-	case models.ProcessStateRunning:
-		logger.Info("process-already-running")
-		return nil
+	case models.ProcessStateRunning, models.ProcessStatePaused:
+		logger.Info("process-already-running", lager.Data{"state": state})
+		return errs.New(errs.AlreadyRunning, "process is already running (%s)", state)
 	case models.ProcessStateFailed:
 		logger.Info("removing-stopped-process")
 		if err := runcLifecycle.RemoveProcess(logger, bpmCfg); err != nil {
 			logger.Error("failed-to-cleanup", err)
-			return fmt.Errorf("failed to clean up stale job-process: %s", err)
+			return errs.New(errs.RuntimeFailure, "failed to clean up stale job-process: %w", err)
 		}
 		fallthrough
 	default:
 		if status, err := runcLifecycle.RunProcess(logger, bpmCfg, procCfg); err != nil {
 			return &exitstatus.Error{
 				Status: status,
-				Err:    fmt.Errorf("failed to run job-process: %s", err),
+				Err:    errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to run job-process: %w", err),
 			}
 		}
 	}
 
 	return nil
 }
+
+// readEnvFiles reads the environment variables in each file. Each line of a
+// file is a KEY=VALUE pair. Blank lines and lines starting with # are ignored.
+// Passing variables in a file keeps them out of the process table, so an
+// invalid line is reported by its number rather than its contents.
+func readEnvFiles(paths []string) ([]string, error) {
+	var vars []string
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read environment file: %w", err)
+		}
+
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if !strings.Contains(line, "=") {
+				return nil, fmt.Errorf("invalid environment file %s: line %d is not a KEY=value pair", path, i+1)
+			}
+
+			vars = append(vars, line)
+		}
+	}
+
+	return vars, nil
+}
+
+// passedEnv copies the named variables from the environment which bpm was run
+// in. Variables which are not set are skipped.
+func passedEnv(names []string) []string {
+	var vars []string
+
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			vars = append(vars, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+
+	return vars
+}
@@ -0,0 +1,48 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+)
+
+func init() {
+	RootCmd.AddCommand(schemaCommand)
+}
+
+var schemaCommand = &cobra.Command{
+	Annotations: offlineAnnotations,
+	Long:        "Prints the JSON schema for job configuration files. This can be used by editors and CI to check configuration before it is deployed.",
+	RunE:        printSchema,
+	Short:       "prints the JSON schema for job configuration",
+	Use:         "schema",
+}
+
+func printSchema(cmd *cobra.Command, _ []string) error {
+	data, err := json.MarshalIndent(config.JobConfigSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s\n", data)
+
+	return nil
+}
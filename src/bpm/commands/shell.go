@@ -16,12 +16,11 @@
 package commands
 
 import (
-	"errors"
-	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 
+	"bpm/errs"
 	"bpm/models"
 	"bpm/runc/lifecycle"
 )
@@ -52,9 +51,9 @@ func shell(cmd *cobra.Command, _ []string) error {
 
 	process, err := runcLifecycle.StatProcess(bpmCfg)
 	if lifecycle.IsNotExist(err) || process.Status == models.ProcessStateFailed {
-		return errors.New("process is not running or could not be found")
+		return errs.New(errs.ProcessNotFound, "process is not running or could not be found")
 	} else if err != nil {
-		return fmt.Errorf("failed to get process: %s", err)
+		return errs.New(errs.RuntimeFailure, "failed to get process: %w", err)
 	}
 
 	return runcLifecycle.OpenShell(bpmCfg, os.Stdin, cmd.OutOrStdout(), cmd.OutOrStderr())
@@ -16,16 +16,25 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/spf13/cobra"
 
+	"bpm/config"
+	"bpm/errs"
+	"bpm/events"
 	"bpm/models"
 	"bpm/runc/lifecycle"
 )
 
+var restartIfChanged bool
+
 func init() {
 	startCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	startCommand.Flags().BoolVar(&allProcesses, "all", false, "start every process in the job")
+	startCommand.Flags().BoolVar(&restartIfChanged, "restart-if-changed", false, "restart the process if it is running with an outdated configuration")
 	RootCmd.AddCommand(startCommand)
 }
 
@@ -38,7 +47,7 @@ var startCommand = &cobra.Command{
 }
 
 func startPre(cmd *cobra.Command, args []string) error {
-	if err := validateInput(args); err != nil {
+	if err := validateAllInput(args); err != nil {
 		return err
 	}
 
@@ -48,10 +57,23 @@ func startPre(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// The supervisor takes the lifecycle lock itself.
+	if !allProcesses && !restartIfChanged {
+		daemon = connectToSupervisor()
+	}
+
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
 	return acquireLifecycleLock()
 }
 
 func startPost(cmd *cobra.Command, args []string) error {
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
 	return releaseLifecycleLock()
 }
 
@@ -59,48 +81,148 @@ func start(cmd *cobra.Command, _ []string) error {
 	logger.Info("starting")
 	defer logger.Info("complete")
 
+	if daemon != nil {
+		logger.Info("using-supervisor")
+		return daemon.Start(bpmCfg.JobName(), procName)
+	}
+
 	jobCfg, err := bpmCfg.ParseJobConfig()
 	if err != nil {
 		logger.Error("failed-to-parse-config", err)
-		return fmt.Errorf("failed to parse job configuration: %s", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	logConfigWarnings(jobCfg)
+
+	if allProcesses {
+		return startAllProcesses(jobCfg)
 	}
 
 	procCfg, err := processByNameFromJobConfig(jobCfg, procName)
 	if err != nil {
 		logger.Error("process-not-defined", err)
-		return fmt.Errorf("process %q not present in job configuration (%s)", procName, bpmCfg.JobConfig())
+		return errs.New(errs.ProcessNotFound, "process %q not present in job configuration (%s)", procName, bpmCfg.JobConfig())
 	}
 
 	runcLifecycle, err := newRuncLifecycle()
 	if err != nil {
 		return err
 	}
+
+	return startProcess(logger, runcLifecycle, bpmCfg, procCfg)
+}
+
+// startAllProcesses starts every process in the job at the same time except
+// that a process is not started until the processes it depends on are ready.
+func startAllProcesses(jobCfg *config.JobConfig) error {
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	failures := forEachProcess(jobCfg, false, func(procCfg *config.ProcessConfig) error {
+		cfg := config.NewBPMConfig(bosh.Root(), bpmCfg.JobName(), procCfg.Name)
+		l := logger.WithData(lager.Data{"process": procCfg.Name})
+
+		if err := waitForDependencies(l, runcLifecycle, procCfg); err != nil {
+			return err
+		}
+
+		err := withProcessLock(l, cfg, func() error {
+			return startProcess(l, runcLifecycle, cfg, procCfg)
+		})
+
+		// Starting the whole job only starts the processes which are not
+		// already running.
+		if errors.Is(err, errs.AlreadyRunning) {
+			return nil
+		}
+
+		return err
+	})
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to start %d of %d processes:\n%w", len(failures), len(jobCfg.Processes), errs.Combine(failures))
+	}
+
+	return nil
+}
+
+// waitForDependencies waits for the processes which a process depends on to
+// become ready.
+func waitForDependencies(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, procCfg *config.ProcessConfig) error {
+	for _, dep := range procCfg.DependsOn {
+		if dep.Ready == nil {
+			continue
+		}
+
+		logger.Info("waiting-for-dependency", lager.Data{"dependency": dep.Process})
+		if err := runcLifecycle.WaitForReady(logger, dep.Ready); err != nil {
+			logger.Error("dependency-not-ready", err, lager.Data{"dependency": dep.Process})
+			return errs.New(errs.NotReady, "dependency %q did not become ready: %w", dep.Process, err)
+		}
+	}
+
+	return nil
+}
+
+func startProcess(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) error {
+	// A container left behind by an unclean reboot cannot be stat'd or
+	// removed by runc and so it is repaired first.
+	if _, err := runcLifecycle.RepairProcess(logger, bpmCfg); err != nil {
+		logger.Error("failed-to-repair", err)
+	}
+
 	process, err := runcLifecycle.StatProcess(bpmCfg)
 	if err != nil && !lifecycle.IsNotExist(err) {
 		logger.Error("failed-getting-job", err)
-		return err
+		return errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
 	}
 
+	recordCrash(logger, runcLifecycle, bpmCfg, process)
+	recordEvent(logger, bpmCfg, events.Event{Type: events.StartRequested})
+
 	var state string
 	if process != nil {
 		state = process.Status
 	}
 
 	switch state {
-	case models.ProcessStateRunning:
-		logger.Info("process-already-running")
-		return nil
+	case models.ProcessStateRunning, models.ProcessStatePaused:
+		// A paused process is left paused until it is resumed.
+		if !restartIfChanged {
+			logger.Info("process-already-running", lager.Data{"state": state})
+			return errs.New(errs.AlreadyRunning, "process is already running (%s)", state)
+		}
+
+		changed, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+		if err != nil && !lifecycle.IsNotExist(err) {
+			logger.Error("failed-to-compare-spec", err)
+			return errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to compare job-process configuration: %w", err)
+		}
+
+		if !changed {
+			logger.Info("process-already-running")
+			return nil
+		}
+
+		logger.Info("stopping-changed-process")
+		resumeIfPaused(logger, runcLifecycle, bpmCfg, state)
+		if err := runcLifecycle.StopProcess(logger, bpmCfg, DefaultStopTimeout); err != nil {
+			logger.Error("failed-to-stop", err)
+		}
+		fallthrough
 	case models.ProcessStateFailed:
 		logger.Info("removing-stopped-process")
 		if err := runcLifecycle.RemoveProcess(logger, bpmCfg); err != nil {
 			logger.Error("failed-to-cleanup", err)
-			return fmt.Errorf("failed to clean up stale job-process: %s", err)
+			return errs.New(errs.RuntimeFailure, "failed to clean up stale job-process: %w", err)
 		}
 		fallthrough
 	default:
 		if err := runcLifecycle.StartProcess(logger, bpmCfg, procCfg); err != nil {
 			logger.Error("failed-to-start", err)
-			return fmt.Errorf("failed to start job-process: %s", err)
+			return errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to start job-process: %w", err)
 		}
 	}
 
@@ -0,0 +1,107 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"bpm/errs"
+	"bpm/models"
+	"bpm/presenters"
+	"bpm/runc/lifecycle"
+)
+
+func init() {
+	statusCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	RootCmd.AddCommand(statusCommand)
+}
+
+var statusCommand = &cobra.Command{
+	Long:    "Shows the state of a BOSH process and whether its configuration has changed since it was started",
+	RunE:    status,
+	Short:   "shows the state of a BOSH process",
+	Use:     "status <job-name>",
+	PreRunE: statusPre,
+}
+
+func statusPre(cmd *cobra.Command, args []string) error {
+	if err := validateInput(args); err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+
+	return setupBpmLogs("status")
+}
+
+func status(cmd *cobra.Command, _ []string) error {
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	process, err := runcLifecycle.StatProcess(bpmCfg)
+	if lifecycle.IsNotExist(err) {
+		process = &models.Process{
+			Name:   bpmCfg.ContainerID(),
+			Status: models.ProcessStateStopped,
+		}
+	} else if err != nil {
+		logger.Error("failed-getting-job", err)
+		return errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
+	}
+
+	configState, err := processConfigState(runcLifecycle)
+	if err != nil {
+		return err
+	}
+
+	return presenters.PrintStatus(process, configState, cmd.OutOrStdout())
+}
+
+// processConfigState compares the spec the process was started with to the
+// spec which would be built from its configuration now.
+func processConfigState(runcLifecycle *lifecycle.RuncLifecycle) (string, error) {
+	jobCfg, err := bpmCfg.ParseJobConfig()
+	if err != nil {
+		logger.Error("failed-to-parse-config", err)
+		return models.ConfigStateInvalid, nil
+	}
+
+	procCfg, err := processByNameFromJobConfig(jobCfg, procName)
+	if err != nil {
+		logger.Error("process-not-defined", err)
+		return models.ConfigStateInvalid, nil
+	}
+
+	changed, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+	switch {
+	case lifecycle.IsNotExist(err):
+		return models.ConfigStateUnknown, nil
+	case errors.Is(err, errs.ConfigInvalid):
+		logger.Error("failed-to-build-spec", err)
+		return models.ConfigStateInvalid, nil
+	case err != nil:
+		logger.Error("failed-to-compare-spec", err)
+		return "", errs.New(errs.RuntimeFailure, "failed to compare job-process configuration: %w", err)
+	case changed:
+		return models.ConfigStateChanged, nil
+	default:
+		return models.ConfigStateUnchanged, nil
+	}
+}
@@ -19,8 +19,13 @@ import (
 	"fmt"
 	"time"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/spf13/cobra"
 
+	"bpm/config"
+	"bpm/errs"
+	"bpm/events"
+	"bpm/models"
 	"bpm/runc/lifecycle"
 )
 
@@ -28,6 +33,7 @@ const DefaultStopTimeout = 15 * time.Second
 
 func init() {
 	stopCommand.Flags().StringVarP(&procName, "process", "p", "", "optional process name")
+	stopCommand.Flags().BoolVar(&allProcesses, "all", false, "stop every process in the job")
 	RootCmd.AddCommand(stopCommand)
 }
 
@@ -40,7 +46,7 @@ var stopCommand = &cobra.Command{
 }
 
 func stopPre(cmd *cobra.Command, args []string) error {
-	if err := validateInput(args); err != nil {
+	if err := validateAllInput(args); err != nil {
 		return err
 	}
 
@@ -50,10 +56,23 @@ func stopPre(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// The supervisor takes the lifecycle lock itself.
+	if !allProcesses {
+		daemon = connectToSupervisor()
+	}
+
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
 	return acquireLifecycleLock()
 }
 
 func stopPost(cmd *cobra.Command, args []string) error {
+	if allProcesses || daemon != nil {
+		return nil
+	}
+
 	return releaseLifecycleLock()
 }
 
@@ -61,27 +80,119 @@ func stop(cmd *cobra.Command, _ []string) error {
 	logger.Info("starting")
 	defer logger.Info("complete")
 
+	if daemon != nil {
+		logger.Info("using-supervisor")
+		return daemon.Stop(bpmCfg.JobName(), procName)
+	}
+
 	runcLifecycle, err := newRuncLifecycle()
 	if err != nil {
 		return err
 	}
 
-	if _, err := runcLifecycle.StatProcess(bpmCfg); lifecycle.IsNotExist(err) {
+	if allProcesses {
+		return stopAllProcesses(runcLifecycle)
+	}
+
+	return stopProcess(logger, runcLifecycle, bpmCfg, false)
+}
+
+// stopAllProcesses stops every process in the job at the same time except
+// that a process is not stopped until the processes which depend on it have
+// stopped. A process which fails to stop does not prevent the others from
+// being stopped.
+func stopAllProcesses(runcLifecycle *lifecycle.RuncLifecycle) error {
+	jobCfg, err := bpmCfg.ParseJobConfig()
+	if err != nil {
+		logger.Error("failed-to-parse-config", err)
+		return errs.New(errs.ConfigInvalid, "failed to parse job configuration: %w", err)
+	}
+
+	failures := forEachProcess(jobCfg, true, func(procCfg *config.ProcessConfig) error {
+		cfg := config.NewBPMConfig(bosh.Root(), bpmCfg.JobName(), procCfg.Name)
+		l := logger.WithData(lager.Data{"process": procCfg.Name})
+
+		return withProcessLock(l, cfg, func() error {
+			return stopProcess(l, runcLifecycle, cfg, false)
+		})
+	})
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to stop %d of %d processes:\n%w", len(failures), len(jobCfg.Processes), errs.Combine(failures))
+	}
+
+	return nil
+}
+
+// stopProcess stops and removes a process. When waitCgroupEmpty is set every
+// process left in the container must exit before the container is removed.
+func stopProcess(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, bpmCfg *config.BPMConfig, waitCgroupEmpty bool) error {
+	process, err := runcLifecycle.StatProcess(bpmCfg)
+	if err != nil && !lifecycle.IsNotExist(err) {
+		logger.Error("failed-to-get-job", err)
+		return errs.New(errs.RuntimeFailure, "failed to get job-process status: %w", err)
+	}
+
+	recordCrash(logger, runcLifecycle, bpmCfg, process)
+	recordEvent(logger, bpmCfg, events.Event{Type: events.StopRequested})
+
+	if lifecycle.IsNotExist(err) {
 		logger.Info("job-already-stopped")
 		return nil
-	} else if err != nil {
-		logger.Error("failed-to-get-job", err)
-		return fmt.Errorf("failed to get job-process status: %s", err)
 	}
 
+	resumeIfPaused(logger, runcLifecycle, bpmCfg, process.Status)
+
 	if err := runcLifecycle.StopProcess(logger, bpmCfg, DefaultStopTimeout); err != nil {
 		logger.Error("failed-to-stop", err)
 	}
 
+	if waitCgroupEmpty {
+		if err := waitForContainerCgroup(logger, runcLifecycle, bpmCfg); err != nil {
+			return err
+		}
+	}
+
 	if err := runcLifecycle.RemoveProcess(logger, bpmCfg); err != nil {
 		logger.Error("failed-to-cleanup", err)
-		return fmt.Errorf("failed to cleanup job-process: %s", err)
+		return errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to cleanup job-process: %w", err)
+	}
+
+	return nil
+}
+
+// waitForContainerCgroup waits for every process in the cgroup of a stopped
+// container to exit. It must be called before the container is removed as
+// removing the container also removes its cgroup.
+func waitForContainerCgroup(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, bpmCfg *config.BPMConfig) error {
+	cgroup, err := runcLifecycle.ContainerCgroup(bpmCfg)
+	if err != nil {
+		logger.Error("failed-to-find-cgroup", err)
+		return errs.New(errs.RuntimeFailure, "failed to find the cgroup of the job-process: %w", err)
+	}
+
+	if cgroup == "" {
+		return nil
+	}
+
+	logger.Info("waiting-for-cgroup", lager.Data{"cgroup": cgroup})
+	if err := runcLifecycle.WaitForCgroupEmpty(logger, cgroup, DefaultStopTimeout); err != nil {
+		logger.Error("failed-waiting-for-cgroup", err)
+		return errs.New(errs.KindOf(err, errs.RuntimeFailure), "failed to wait for the old job-process to exit: %w", err)
 	}
 
 	return nil
 }
+
+// resumeIfPaused resumes a paused process before it is stopped. The processes
+// in a paused container cannot handle the signal to stop until they are
+// thawed.
+func resumeIfPaused(logger lager.Logger, runcLifecycle *lifecycle.RuncLifecycle, bpmCfg *config.BPMConfig, state string) {
+	if state != models.ProcessStatePaused {
+		return
+	}
+
+	if err := runcLifecycle.ResumeProcess(logger, bpmCfg); err != nil {
+		logger.Error("failed-to-resume", err)
+	}
+}
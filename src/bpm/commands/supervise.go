@@ -0,0 +1,189 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"bpm/api"
+	"bpm/config"
+	"bpm/supervisor"
+)
+
+func init() {
+	RootCmd.AddCommand(superviseCommand)
+}
+
+var superviseCommand = &cobra.Command{
+	Long:    "Starts every process on this machine and restarts them when they exit according to their restart policy",
+	RunE:    supervise,
+	Short:   "supervises every process on this machine",
+	Use:     "supervise",
+	PreRunE: supervisePre,
+}
+
+func supervisePre(cmd *cobra.Command, _ []string) error {
+	cmd.SilenceUsage = true
+
+	return setupMachineLogs(config.SupervisorLog(bosh.Root()), "supervise")
+}
+
+func supervise(cmd *cobra.Command, _ []string) error {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	// The init process of a container is orphaned when runc exits. Making
+	// the supervisor a subreaper means that it is adopted by the supervisor
+	// so that its exit status can be collected.
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		logger.Error("failed-to-become-subreaper", err)
+		return fmt.Errorf("failed to become a subreaper: %s", err)
+	}
+
+	pidFile := config.SupervisorPidFile(bosh.Root())
+	if err := os.MkdirAll(filepath.Dir(pidFile), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(pidFile)
+
+	runcLifecycle, err := newRuncLifecycle()
+	if err != nil {
+		return err
+	}
+
+	reaper := supervisor.NewReaper(clock.NewClock())
+
+	s := supervisor.New(
+		logger,
+		runcLifecycle,
+		reaper,
+		lockForSupervisor,
+		machineProcesses,
+		clock.NewClock(),
+		journal,
+	)
+
+	listener, err := api.Listen(config.SupervisorSocket(bosh.Root()))
+	if err != nil {
+		logger.Error("failed-to-listen", err)
+		return fmt.Errorf("failed to listen on the control socket: %s", err)
+	}
+	defer os.Remove(config.SupervisorSocket(bosh.Root()))
+
+	server := &http.Server{
+		Handler: api.NewHandler(logger, &controller{logger: logger, runcLifecycle: runcLifecycle}),
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("failed-to-serve", err)
+		}
+	}()
+	defer server.Close()
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		logger.Info("received-signal", lager.Data{"signal": sig.String()})
+		close(stop)
+	}()
+
+	// SIGHUP makes the supervisor read the configuration of the jobs on
+	// the machine again straight away.
+	reload := make(chan struct{})
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			select {
+			case reload <- struct{}{}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// Processes which are orphaned inside a container are adopted by the
+	// supervisor as well and must be reaped even though nobody waits for
+	// them.
+	go reaper.Run(stop)
+
+	for _, p := range machineProcesses() {
+		if _, err := repairProcess(logger, runcLifecycle, p.Config); err != nil {
+			logger.Error("failed-to-repair", err, lager.Data{"job": p.Config.JobName(), "process": p.Config.ProcName()})
+		}
+	}
+
+	s.Run(reload, stop)
+
+	return nil
+}
+
+// machineProcesses returns every process of every job on this machine. Jobs
+// with invalid configuration are skipped.
+func machineProcesses() []supervisor.Process {
+	var processes []supervisor.Process
+
+	for _, job := range bosh.JobNames() {
+		jobCfg, err := config.NewBPMConfig(bosh.Root(), job, "").ParseJobConfig()
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			logger.Error("invalid-config", err, lager.Data{"job": job})
+			continue
+		}
+
+		for _, procCfg := range jobCfg.Processes {
+			processes = append(processes, supervisor.Process{
+				Config:        config.NewBPMConfig(bosh.Root(), job, procCfg.Name),
+				ProcessConfig: procCfg,
+			})
+		}
+	}
+
+	return processes
+}
+
+func lockForSupervisor(l lager.Logger, cfg *config.BPMConfig) (func(), error) {
+	f, err := lockProcess(l, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := unlockProcess(l, cfg, f); err != nil {
+			l.Error("failed-to-release-lock", err)
+		}
+	}, nil
+}
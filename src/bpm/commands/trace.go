@@ -16,7 +16,6 @@
 package commands
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -24,6 +23,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"bpm/errs"
 	"bpm/models"
 	"bpm/runc/lifecycle"
 )
@@ -66,9 +66,9 @@ func trace(cmd *cobra.Command, _ []string) error {
 
 	process, err := runcLifecycle.StatProcess(bpmCfg)
 	if lifecycle.IsNotExist(err) || process.Status == models.ProcessStateFailed {
-		return errors.New("process is not running or could not be found")
+		return errs.New(errs.ProcessNotFound, "process is not running or could not be found")
 	} else if err != nil {
-		return fmt.Errorf("failed to get process: %s", err)
+		return errs.New(errs.RuntimeFailure, "failed to get process: %w", err)
 	}
 
 	straceCmd := exec.Command("strace", "-s", "100", "-f", "-y", "-yy", "-p", fmt.Sprintf("%d", process.Pid))
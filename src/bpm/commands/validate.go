@@ -0,0 +1,78 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"bpm/config"
+	"bpm/errs"
+)
+
+var (
+	validateBoshRoot string
+	validateJobName  string
+)
+
+func init() {
+	validateCommand.Flags().StringVar(&validateBoshRoot, "bosh-root", bosh.Root(), "the BOSH root the configuration will be deployed to")
+	validateCommand.Flags().StringVar(&validateJobName, "job", "", "the job name (defaults to the directory two levels above the configuration file)")
+	RootCmd.AddCommand(validateCommand)
+}
+
+var validateCommand = &cobra.Command{
+	Annotations: offlineAnnotations,
+	Long:        "Validates a job configuration file. This does not need root privileges and does not inspect or change the system.",
+	RunE:        validateJobConfig,
+	Short:       "validates a job configuration file",
+	Use:         "validate <path-to-bpm.yml>",
+}
+
+func validateJobConfig(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.New("must specify a configuration file")
+	}
+
+	cmd.SilenceUsage = true
+
+	path := args[0]
+	jobName := validateJobName
+	if jobName == "" {
+		jobName = filepath.Base(filepath.Dir(filepath.Dir(path)))
+	}
+
+	jobCfg, err := config.ParseJobConfigStrict(path)
+	if err != nil {
+		return errs.New(errs.ConfigInvalid, "%w", err)
+	}
+
+	cfg := config.NewBPMConfig(validateBoshRoot, jobName, jobName)
+	if err := jobCfg.Interpolate(cfg.Variables()); err != nil {
+		return errs.New(errs.ConfigInvalid, "%w", err)
+	}
+
+	if err := jobCfg.ValidateStrict(validateBoshRoot, cfg.DefaultVolumes()); err != nil {
+		return errs.New(errs.ConfigInvalid, "%w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s: valid configuration for job %s\n", path, jobName)
+
+	return nil
+}
@@ -16,8 +16,10 @@
 package config
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 )
 
 const DefaultBoshRoot = "/var/vcap"
@@ -54,3 +56,59 @@ func (b *Bosh) JobNames() []string {
 
 	return jobs
 }
+
+// InstanceSpec is the part of the BOSH instance spec (written by the BOSH agent
+// to bosh/spec.json) which describes the instance that bpm is running on.
+type InstanceSpec struct {
+	Deployment string                     `json:"deployment"`
+	Name       string                     `json:"name"`
+	Index      int                        `json:"index"`
+	ID         string                     `json:"id"`
+	AZ         string                     `json:"az"`
+	Address    string                     `json:"address"`
+	Bootstrap  bool                       `json:"bootstrap"`
+	Networks   map[string]InstanceNetwork `json:"networks"`
+}
+
+type InstanceNetwork struct {
+	IP      string   `json:"ip"`
+	Default []string `json:"default"`
+}
+
+// InstanceSpec reads the BOSH instance spec of the instance.
+func (b *Bosh) InstanceSpec() (*InstanceSpec, error) {
+	data, err := ioutil.ReadFile(filepath.Join(b.root, "bosh", "spec.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var spec InstanceSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// IP returns the IP address of the network which provides the default gateway
+// of the instance. If no network provides it then the IP address of the first
+// network (in name order) is used.
+func (s *InstanceSpec) IP() string {
+	var names []string
+	for name := range s.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if contains(s.Networks[name].Default, "gateway") {
+			return s.Networks[name].IP
+		}
+	}
+
+	if len(names) > 0 {
+		return s.Networks[names[0]].IP
+	}
+
+	return ""
+}
@@ -66,4 +66,60 @@ var _ = Describe("Bosh", func() {
 			Expect(paths).To(ConsistOf("job-a", "job-b"))
 		})
 	})
+
+	Describe("InstanceSpec", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(root, "bosh"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(root, "bosh", "spec.json"), []byte(`{
+				"deployment": "cf",
+				"name": "router",
+				"index": 2,
+				"id": "5d9b4e1f-7c2a-4b8e-9f3d-1a2b3c4d5e6f",
+				"az": "z1",
+				"address": "5d9b4e1f.router.default.cf.bosh",
+				"bootstrap": true,
+				"networks": {
+					"private": {"ip": "10.0.1.5"},
+					"public": {"ip": "10.0.0.5", "default": ["dns", "gateway"]}
+				},
+				"job": {"name": "router"}
+			}`), 0600)).To(Succeed())
+		})
+
+		It("reads the instance spec written by the BOSH agent", func() {
+			spec, err := config.NewBosh(root).InstanceSpec()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spec.Deployment).To(Equal("cf"))
+			Expect(spec.Name).To(Equal("router"))
+			Expect(spec.Index).To(Equal(2))
+			Expect(spec.ID).To(Equal("5d9b4e1f-7c2a-4b8e-9f3d-1a2b3c4d5e6f"))
+			Expect(spec.AZ).To(Equal("z1"))
+			Expect(spec.Address).To(Equal("5d9b4e1f.router.default.cf.bosh"))
+			Expect(spec.Bootstrap).To(BeTrue())
+		})
+
+		It("uses the IP address of the network with the default gateway", func() {
+			spec, err := config.NewBosh(root).InstanceSpec()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.IP()).To(Equal("10.0.0.5"))
+		})
+
+		Context("when no network has the default gateway", func() {
+			It("uses the IP address of the first network", func() {
+				spec := &config.InstanceSpec{Networks: map[string]config.InstanceNetwork{
+					"b": {IP: "10.0.0.2"},
+					"a": {IP: "10.0.0.1"},
+				}}
+				Expect(spec.IP()).To(Equal("10.0.0.1"))
+			})
+		})
+
+		Context("when the instance spec does not exist", func() {
+			It("returns an error", func() {
+				_, err := config.NewBosh(filepath.Join(root, "missing")).InstanceSpec()
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
 })
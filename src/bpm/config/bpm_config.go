@@ -33,6 +33,39 @@ func RuncRoot(boshRoot string) string {
 	return filepath.Join(boshRoot, "data", "bpm", "runc")
 }
 
+// SupervisorDir is where bpm supervise keeps its pidfile and socket.
+func SupervisorDir(boshRoot string) string {
+	return filepath.Join(boshRoot, "sys", "run", "bpm")
+}
+
+func SupervisorPidFile(boshRoot string) string {
+	return filepath.Join(SupervisorDir(boshRoot), "supervise.pid")
+}
+
+// SupervisorSocket is the unix socket which bpm supervise serves the control
+// API on.
+func SupervisorSocket(boshRoot string) string {
+	return filepath.Join(SupervisorDir(boshRoot), "bpm.sock")
+}
+
+func SupervisorLog(boshRoot string) string {
+	return filepath.Join(boshRoot, "sys", "log", "bpm", "supervise.log")
+}
+
+func GCLog(boshRoot string) string {
+	return filepath.Join(boshRoot, "sys", "log", "bpm", "gc.log")
+}
+
+func RepairLog(boshRoot string) string {
+	return filepath.Join(boshRoot, "sys", "log", "bpm", "repair.log")
+}
+
+// EventsJournal is the journal of the lifecycle events of every process on
+// the machine (see package events).
+func EventsJournal(boshRoot string) string {
+	return filepath.Join(boshRoot, "sys", "log", "bpm", "events.log")
+}
+
 type BPMConfig struct {
 	boshRoot string
 	jobName  string
@@ -47,6 +80,11 @@ func NewBPMConfig(boshRoot, jobName, procName string) *BPMConfig {
 	}
 }
 
+// Bosh is the BOSH installation which the job is part of.
+func (c *BPMConfig) Bosh() *Bosh {
+	return NewBosh(c.boshRoot)
+}
+
 func (c *BPMConfig) JobName() string {
 	return c.jobName
 }
@@ -95,6 +133,13 @@ func (c *BPMConfig) LockFile() string {
 	return filepath.Join(c.PidDir(), fmt.Sprintf("%s.lock", c.procName))
 }
 
+// SecretsDir is the directory on the host which holds the secrets of the
+// process while it is running. A tmpfs is mounted on it so that the secrets
+// are never written to disk.
+func (c *BPMConfig) SecretsDir() string {
+	return filepath.Join(c.PidDir(), "secrets", c.procName)
+}
+
 func (c *BPMConfig) PackageDir() string {
 	return filepath.Join(c.boshRoot, "packages")
 }
@@ -115,12 +160,30 @@ func (c *BPMConfig) DefaultVolumes() []string {
 	return []string{c.DataDir(), c.StoreDir()}
 }
 
+// Variables are the well-known variables of the job which its configuration
+// can refer to (see JobConfig.Interpolate).
+func (c *BPMConfig) Variables() map[string]string {
+	return map[string]string{
+		"JOB":        c.jobName,
+		"JOB_DIR":    c.JobDir(),
+		"DATA_DIR":   c.DataDir(),
+		"STORE_DIR":  c.StoreDir(),
+		"LOG_DIR":    c.LogDir(),
+		"SOCKET_DIR": c.SocketDir(),
+	}
+}
+
 func (c *BPMConfig) ParseJobConfig() (*JobConfig, error) {
 	cfg, err := ParseJobConfig(c.JobConfig())
 	if err != nil {
 		return nil, err
 	}
 
+	err = cfg.Interpolate(c.Variables())
+	if err != nil {
+		return nil, err
+	}
+
 	err = cfg.Validate(c.boshRoot, c.DefaultVolumes())
 	if err != nil {
 		return nil, err
@@ -137,6 +200,13 @@ func (c *BPMConfig) BundlePath() string {
 	return filepath.Join(BundlesRoot(c.boshRoot), c.jobName, c.procName)
 }
 
+// RuncLog is where runc logs to while it runs the container. It is kept
+// outside of the bundle so that it survives the container being removed
+// after runc fails.
+func (c *BPMConfig) RuncLog() string {
+	return filepath.Join(c.LogDir(), fmt.Sprintf("%s.runc.log", c.procName))
+}
+
 func (c *BPMConfig) RootFSPath() string {
 	return filepath.Join(c.BundlePath(), "rootfs")
 }
@@ -0,0 +1,201 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import "strings"
+
+// Defaults is configuration which is shared by every process in a job. It is
+// merged into each process when the job configuration is parsed:
+//
+//   - env: the process inherits every default variable which it does not set
+//     itself.
+//   - capabilities: the process is granted the default capabilities as well as
+//     its own.
+//   - additional_volumes: the process mounts the default volumes as well as its
+//     own. A volume in the process with the same path as a default volume
+//     replaces it.
+//   - limits: the process inherits each default limit which it does not set
+//     itself.
+//
+// A process can choose not to inherit default capabilities and volumes by
+// listing them in its remove_defaults.
+type Defaults struct {
+	Env               map[string]string `yaml:"env,omitempty" description:"Environment variables to be included in the environment of every process."`
+	AdditionalVolumes []Volume          `yaml:"additional_volumes,omitempty" description:"Additional volumes to mount inside every process."`
+	Capabilities      []string          `yaml:"capabilities,omitempty" description:"The list of capabilities (without CAP_) which should be granted to every process."`
+	Limits            *Limits           `yaml:"limits,omitempty" description:"The limit configuration for every process."`
+}
+
+// RemoveDefaults lists the entries of the job defaults which a process should
+// not inherit.
+type RemoveDefaults struct {
+	AdditionalVolumes []string `yaml:"additional_volumes,omitempty" description:"The paths of the default volumes which should not be mounted inside this process."`
+	Capabilities      []string `yaml:"capabilities,omitempty" description:"The default capabilities which should not be granted to this process."`
+}
+
+func (c *JobConfig) applyDefaults() {
+	if c.Defaults == nil {
+		return
+	}
+
+	for i, p := range c.Processes {
+		if p != nil {
+			p.inherit(c.Defaults, c.processSource(i).path)
+		}
+	}
+}
+
+// inherit merges the job defaults into the process. The path is the location
+// of the process in the job configuration and is used to record where each
+// merged entry came from so that problems with it can be reported against the
+// right part of the configuration.
+func (c *ProcessConfig) inherit(d *Defaults, path string) {
+	var removed RemoveDefaults
+	if c.RemoveDefaults != nil {
+		removed = *c.RemoveDefaults
+	}
+
+	c.origins = map[string]string{}
+
+	if len(d.Env) > 0 {
+		env := map[string]string{}
+		for k, v := range d.Env {
+			env[k] = v
+			c.origins[joinPath("env", k)] = joinPath("defaults.env", k)
+		}
+		for k, v := range c.Env {
+			env[k] = v
+			delete(c.origins, joinPath("env", k))
+		}
+		c.Env = env
+	}
+
+	var capabilities []string
+	for i, capability := range d.Capabilities {
+		if contains(removed.Capabilities, capability) || contains(capabilities, capability) {
+			continue
+		}
+
+		c.origins[indexPath("capabilities", len(capabilities))] = indexPath("defaults.capabilities", i)
+		capabilities = append(capabilities, capability)
+	}
+	for i, capability := range c.Capabilities {
+		if contains(capabilities, capability) {
+			continue
+		}
+
+		c.origins[indexPath("capabilities", len(capabilities))] = joinPath(path, indexPath("capabilities", i))
+		capabilities = append(capabilities, capability)
+	}
+	c.Capabilities = capabilities
+
+	var volumes []Volume
+	for i, vol := range d.AdditionalVolumes {
+		if contains(removed.AdditionalVolumes, vol.Path) || hasVolume(c.AdditionalVolumes, vol.Path) {
+			continue
+		}
+
+		c.origins[indexPath("additional_volumes", len(volumes))] = indexPath("defaults.additional_volumes", i)
+		volumes = append(volumes, vol)
+	}
+	for i, vol := range c.AdditionalVolumes {
+		c.origins[indexPath("additional_volumes", len(volumes))] = joinPath(path, indexPath("additional_volumes", i))
+		volumes = append(volumes, vol)
+	}
+	c.AdditionalVolumes = volumes
+
+	if d.Limits != nil {
+		var limits Limits
+		if c.Limits != nil {
+			limits = *c.Limits
+		}
+
+		if limits.Memory == nil && d.Limits.Memory != nil {
+			limits.Memory = d.Limits.Memory
+			c.origins["limits.memory"] = "defaults.limits.memory"
+		}
+
+		if limits.OpenFiles == nil && d.Limits.OpenFiles != nil {
+			limits.OpenFiles = d.Limits.OpenFiles
+			c.origins["limits.open_files"] = "defaults.limits.open_files"
+		}
+
+		if limits.Processes == nil && d.Limits.Processes != nil {
+			limits.Processes = d.Limits.Processes
+			c.origins["limits.processes"] = "defaults.limits.processes"
+		}
+
+		c.Limits = &limits
+	}
+}
+
+// origin returns the location in the job configuration of a field in the
+// process. Fields which were inherited or moved when the defaults were merged
+// are found using the recorded origins. Any other field is inside the process
+// at path.
+func (c *ProcessConfig) origin(path, field string) string {
+	for f := field; f != ""; {
+		if origin, ok := c.origins[f]; ok {
+			return origin + field[len(f):]
+		}
+
+		i := strings.LastIndexAny(f, ".[")
+		if i < 0 {
+			break
+		}
+		f = f[:i]
+	}
+
+	return joinPath(path, field)
+}
+
+// validateRemoveDefaults checks that every entry the process does not want to
+// inherit is one of the job defaults.
+func (c *ProcessConfig) validateRemoveDefaults(d *Defaults) ValidationErrors {
+	var verrs ValidationErrors
+
+	if c.RemoveDefaults == nil {
+		return verrs
+	}
+
+	if d == nil {
+		d = &Defaults{}
+	}
+
+	for i, capability := range c.RemoveDefaults.Capabilities {
+		if !contains(d.Capabilities, capability) {
+			verrs.add(indexPath("remove_defaults.capabilities", i), "%s is not a default capability", capability)
+		}
+	}
+
+	for i, path := range c.RemoveDefaults.AdditionalVolumes {
+		if !hasVolume(d.AdditionalVolumes, path) {
+			verrs.add(indexPath("remove_defaults.additional_volumes", i), "%s is not a default volume", path)
+		}
+	}
+
+	return verrs
+}
+
+func hasVolume(volumes []Volume, path string) bool {
+	for _, vol := range volumes {
+		if vol.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
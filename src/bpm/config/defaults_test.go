@@ -0,0 +1,105 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+)
+
+var _ = Describe("Defaults", func() {
+	var (
+		server *config.ProcessConfig
+		worker *config.ProcessConfig
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.ParseJobConfigStrict("testdata/example-defaults.yml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Validate("/var/vcap", []string{})).To(Succeed())
+
+		Expect(cfg.Processes).To(HaveLen(2))
+		server, worker = cfg.Processes[0], cfg.Processes[1]
+	})
+
+	It("gives every process the default environment", func() {
+		Expect(server.Env).To(Equal(map[string]string{"LOG_LEVEL": "info", "REGION": "east"}))
+	})
+
+	It("lets a process override default environment variables", func() {
+		Expect(worker.Env).To(Equal(map[string]string{"LOG_LEVEL": "debug", "REGION": "east"}))
+	})
+
+	It("grants the default capabilities as well as the process capabilities", func() {
+		Expect(server.Capabilities).To(Equal([]string{"NET_BIND_SERVICE", "SYS_TIME"}))
+		Expect(worker.Capabilities).To(Equal([]string{"NET_BIND_SERVICE", "CHOWN"}))
+	})
+
+	It("mounts the default volumes as well as the process volumes", func() {
+		Expect(server.AdditionalVolumes).To(Equal([]config.Volume{
+			{Path: "/var/vcap/data/shared", Writable: true},
+			{Path: "/var/vcap/data/cache"},
+		}))
+	})
+
+	It("lets a process replace or remove default volumes", func() {
+		Expect(worker.AdditionalVolumes).To(Equal([]config.Volume{
+			{Path: "/var/vcap/data/shared"},
+			{Path: "/var/vcap/data/worker"},
+		}))
+	})
+
+	It("inherits each default limit which the process does not set", func() {
+		Expect(*server.Limits.Memory).To(Equal("1G"))
+		Expect(*server.Limits.OpenFiles).To(Equal(uint64(100)))
+		Expect(server.Limits.Processes).To(BeNil())
+
+		Expect(*worker.Limits.Memory).To(Equal("2G"))
+		Expect(*worker.Limits.OpenFiles).To(Equal(uint64(100)))
+	})
+
+	Context("when the defaults or a process are invalid", func() {
+		It("reports problems with the defaults once against the defaults", func() {
+			cfg, err := config.ParseJobConfigStrict("testdata/example-defaults-invalid.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = cfg.ValidateStrict("/var/vcap", []string{})
+			Expect(err).To(HaveOccurred())
+
+			verrs, ok := err.(config.ValidationErrors)
+			Expect(ok).To(BeTrue())
+			Expect(verrs).To(ConsistOf(
+				config.ValidationError{
+					Path:    "defaults.capabilities[0]",
+					Line:    5,
+					Message: "invalid capability name: CAP_MAKE_COFFEE (capabilities are upper case and should not include the CAP_ prefix)",
+				},
+				config.ValidationError{
+					Path:    "processes[1].capabilities[0]",
+					Line:    12,
+					Message: "invalid capability name: brew_tea (capabilities are upper case and should not include the CAP_ prefix)",
+				},
+				config.ValidationError{
+					Path:    "processes[1].remove_defaults.capabilities[0]",
+					Line:    15,
+					Message: "CHOWN is not a default capability",
+				},
+			))
+		})
+	})
+})
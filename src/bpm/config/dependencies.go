@@ -0,0 +1,221 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultReadinessTimeout is how long to wait for a process to become ready
+// if its readiness condition does not say.
+const DefaultReadinessTimeout = 30 * time.Second
+
+// Dependency is another process in the same job which must be started before
+// the process which depends on it. `bpm start --all` starts the processes of
+// a job in dependency order and `bpm stop --all` stops them in reverse.
+type Dependency struct {
+	Process string     `yaml:"process" schema:"required" description:"The name of the process in this job which this process depends on."`
+	Ready   *Readiness `yaml:"ready,omitempty" description:"The condition which shows that the process depended on is ready. If not specified it is ready as soon as it has started."`
+}
+
+// Readiness is a condition which shows that a process is ready for the
+// processes which depend on it to be started. Exactly one of Path or Port
+// should be set.
+type Readiness struct {
+	Path    string `yaml:"path,omitempty" description:"A path (e.g. a socket) which exists once the process is ready."`
+	Port    int    `yaml:"port,omitempty" description:"A local TCP port which accepts connections once the process is ready."`
+	Timeout string `yaml:"timeout,omitempty" description:"How long to wait for the process to become ready e.g. 30s, 2m. If not specified this is 30s."`
+}
+
+// TimeoutDuration returns how long to wait for the condition to become true.
+func (r *Readiness) TimeoutDuration() time.Duration {
+	if r.Timeout == "" {
+		return DefaultReadinessTimeout
+	}
+
+	timeout, err := time.ParseDuration(r.Timeout)
+	if err != nil {
+		return DefaultReadinessTimeout
+	}
+
+	return timeout
+}
+
+func (r *Readiness) validate() ValidationErrors {
+	var verrs ValidationErrors
+
+	switch {
+	case r.Path == "" && r.Port == 0:
+		verrs.add("", "must specify one of path or port")
+	case r.Path != "" && r.Port != 0:
+		verrs.add("", "must specify only one of path or port")
+	case r.Path != "" && !filepath.IsAbs(r.Path):
+		verrs.add("path", "must be an absolute path: %s", r.Path)
+	case r.Port < 0 || r.Port > 65535:
+		verrs.add("port", "invalid port: %d", r.Port)
+	}
+
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil || timeout <= 0 {
+			verrs.add("timeout", "invalid timeout %q: must be a positive duration e.g. 30s", r.Timeout)
+		}
+	}
+
+	return verrs
+}
+
+func (c *ProcessConfig) validateDependsOn() ValidationErrors {
+	var verrs ValidationErrors
+
+	for i, dep := range c.DependsOn {
+		path := indexPath("depends_on", i)
+
+		if dep.Process == "" {
+			verrs.add(joinPath(path, "process"), "is required")
+		} else if dep.Process == c.Name {
+			verrs.add(joinPath(path, "process"), "a process cannot depend on itself")
+		}
+
+		if dep.Ready != nil {
+			verrs = append(verrs, dep.Ready.validate().prefixed(joinPath(path, "ready"))...)
+		}
+	}
+
+	return verrs
+}
+
+// validateDependencies checks that every process which the i-th process
+// depends on is defined in the job and that it does not depend on itself
+// through other processes.
+func (c *JobConfig) validateDependencies(i int) ValidationErrors {
+	var verrs ValidationErrors
+
+	procs := c.processesByName()
+	p := c.Processes[i]
+
+	for j, dep := range p.DependsOn {
+		path := indexPath("depends_on", j) + ".process"
+
+		if dep.Process == "" || dep.Process == p.Name {
+			continue
+		}
+
+		if _, ok := procs[dep.Process]; !ok {
+			verrs.add(path, "unknown process: %s", dep.Process)
+			continue
+		}
+
+		if cycle := dependencyPath(procs, dep.Process, p.Name, map[string]bool{}); cycle != nil {
+			verrs.add(path, "dependency cycle: %s", strings.Join(append([]string{p.Name}, cycle...), " -> "))
+		}
+	}
+
+	return verrs
+}
+
+// dependencyPath returns the chain of dependencies from one process to
+// another or nil if from does not depend on to.
+func dependencyPath(procs map[string]*ProcessConfig, from, to string, seen map[string]bool) []string {
+	if from == to {
+		return []string{to}
+	}
+
+	if seen[from] {
+		return nil
+	}
+	seen[from] = true
+
+	p, ok := procs[from]
+	if !ok {
+		return nil
+	}
+
+	for _, dep := range p.DependsOn {
+		if rest := dependencyPath(procs, dep.Process, to, seen); rest != nil {
+			return append([]string{from}, rest...)
+		}
+	}
+
+	return nil
+}
+
+func (c *JobConfig) processesByName() map[string]*ProcessConfig {
+	procs := map[string]*ProcessConfig{}
+
+	for _, p := range c.Processes {
+		if p == nil {
+			continue
+		}
+
+		if _, ok := procs[p.Name]; !ok {
+			procs[p.Name] = p
+		}
+	}
+
+	return procs
+}
+
+// StartOrder returns the processes of the job in the order which they should
+// be started so that every process is started after the processes it depends
+// on. Processes which do not depend on each other are kept in the order they
+// are configured in. The processes should be stopped in the reverse order.
+// The job configuration must be valid.
+func (c *JobConfig) StartOrder() []*ProcessConfig {
+	var order []*ProcessConfig
+
+	added := make([]bool, len(c.Processes))
+	started := map[string]bool{}
+	for len(order) < len(c.Processes) {
+		progress := false
+
+		for i, p := range c.Processes {
+			if added[i] || !dependenciesStarted(p, started) {
+				continue
+			}
+
+			added[i] = true
+			started[p.Name] = true
+			order = append(order, p)
+			progress = true
+		}
+
+		if !progress {
+			// This can only happen with a cycle which validation would have
+			// reported. Start the rest in the order they are configured.
+			for i, p := range c.Processes {
+				if !added[i] {
+					added[i] = true
+					order = append(order, p)
+				}
+			}
+		}
+	}
+
+	return order
+}
+
+func dependenciesStarted(p *ProcessConfig, started map[string]bool) bool {
+	for _, dep := range p.DependsOn {
+		if !started[dep.Process] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,213 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+)
+
+var _ = Describe("Dependencies", func() {
+	parse := func(contents string) *config.JobConfig {
+		path := writeTempConfig(contents)
+		defer os.Remove(path)
+
+		cfg, err := config.ParseJobConfigStrict(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		return cfg
+	}
+
+	names := func(procs []*config.ProcessConfig) []string {
+		var names []string
+		for _, p := range procs {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	It("reads the dependencies of each process", func() {
+		cfg := parse(`version: 2
+processes:
+- name: server
+  executable: /bin/server
+  depends_on:
+  - process: database
+    ready:
+      path: /var/vcap/sys/run/example/database.sock
+      timeout: 1m
+  - process: cache
+- name: database
+  executable: /bin/database
+- name: cache
+  executable: /bin/cache
+`)
+		Expect(cfg.Validate("/var/vcap", []string{})).To(Succeed())
+		Expect(cfg.Processes[0].DependsOn).To(Equal([]config.Dependency{
+			{
+				Process: "database",
+				Ready: &config.Readiness{
+					Path:    "/var/vcap/sys/run/example/database.sock",
+					Timeout: "1m",
+				},
+			},
+			{Process: "cache"},
+		}))
+		Expect(cfg.Processes[0].DependsOn[0].Ready.TimeoutDuration()).To(Equal(time.Minute))
+	})
+
+	It("waits for the default timeout if one is not given", func() {
+		ready := &config.Readiness{Port: 8080}
+		Expect(ready.TimeoutDuration()).To(Equal(config.DefaultReadinessTimeout))
+	})
+
+	Describe("StartOrder", func() {
+		It("starts every process after the processes it depends on", func() {
+			cfg := parse(`version: 2
+processes:
+- name: server
+  executable: /bin/server
+  depends_on:
+  - process: database
+  - process: cache
+- name: worker
+  executable: /bin/worker
+- name: cache
+  executable: /bin/cache
+  depends_on:
+  - process: database
+- name: database
+  executable: /bin/database
+`)
+			Expect(cfg.Validate("/var/vcap", []string{})).To(Succeed())
+			Expect(names(cfg.StartOrder())).To(Equal([]string{"worker", "database", "cache", "server"}))
+		})
+
+		It("keeps the configured order of processes without dependencies", func() {
+			cfg := parse(`version: 2
+processes:
+- name: first
+  executable: /bin/first
+- name: second
+  executable: /bin/second
+- name: third
+  executable: /bin/third
+`)
+			Expect(names(cfg.StartOrder())).To(Equal([]string{"first", "second", "third"}))
+		})
+	})
+
+	Context("when a process depends on an unknown process", func() {
+		It("returns an error", func() {
+			cfg := parse(`version: 2
+processes:
+- name: server
+  executable: /bin/server
+  depends_on:
+  - process: database
+`)
+			err := cfg.Validate("/var/vcap", []string{})
+			Expect(err).To(MatchError("invalid config: processes[0].depends_on[0].process (line 6): unknown process: database"))
+		})
+	})
+
+	Context("when a process depends on itself", func() {
+		It("returns an error", func() {
+			cfg := parse(`version: 2
+processes:
+- name: server
+  executable: /bin/server
+  depends_on:
+  - process: server
+`)
+			err := cfg.Validate("/var/vcap", []string{})
+			Expect(err).To(MatchError("invalid config: processes[0].depends_on[0].process (line 6): a process cannot depend on itself"))
+		})
+	})
+
+	Context("when the dependencies form a cycle", func() {
+		It("reports the cycle against each process in it", func() {
+			cfg := parse(`version: 2
+processes:
+- name: server
+  executable: /bin/server
+  depends_on:
+  - process: worker
+- name: worker
+  executable: /bin/worker
+  depends_on:
+  - process: cache
+- name: cache
+  executable: /bin/cache
+  depends_on:
+  - process: server
+`)
+			err := cfg.Validate("/var/vcap", []string{})
+			Expect(err).To(HaveOccurred())
+
+			verrs, ok := err.(config.ValidationErrors)
+			Expect(ok).To(BeTrue())
+			Expect(verrs).To(ConsistOf(
+				config.ValidationError{Path: "processes[0].depends_on[0].process", Line: 6, Message: "dependency cycle: server -> worker -> cache -> server"},
+				config.ValidationError{Path: "processes[1].depends_on[0].process", Line: 10, Message: "dependency cycle: worker -> cache -> server -> worker"},
+				config.ValidationError{Path: "processes[2].depends_on[0].process", Line: 14, Message: "dependency cycle: cache -> server -> worker -> cache"},
+			))
+		})
+	})
+
+	Context("when the readiness condition is invalid", func() {
+		It("returns an error", func() {
+			cfg := parse(`version: 2
+processes:
+- name: server
+  executable: /bin/server
+  depends_on:
+  - process: database
+    ready: {}
+  - process: cache
+    ready:
+      path: relative.sock
+      port: 8080
+  - process: worker
+    ready:
+      port: 70000
+      timeout: soon
+- name: database
+  executable: /bin/database
+- name: cache
+  executable: /bin/cache
+- name: worker
+  executable: /bin/worker
+`)
+			err := cfg.Validate("/var/vcap", []string{})
+			Expect(err).To(HaveOccurred())
+
+			verrs, ok := err.(config.ValidationErrors)
+			Expect(ok).To(BeTrue())
+			Expect(verrs).To(ConsistOf(
+				config.ValidationError{Path: "processes[0].depends_on[0].ready", Line: 7, Message: "must specify one of path or port"},
+				config.ValidationError{Path: "processes[0].depends_on[1].ready", Line: 9, Message: "must specify only one of path or port"},
+				config.ValidationError{Path: "processes[0].depends_on[2].ready.port", Line: 14, Message: "invalid port: 70000"},
+				config.ValidationError{Path: "processes[0].depends_on[2].ready.timeout", Line: 15, Message: `invalid timeout "soon": must be a positive duration e.g. 30s`},
+			))
+		})
+	})
+})
@@ -0,0 +1,187 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// fragmentsDir is the directory beside bpm.yml which holds job configuration
+// fragments.
+const fragmentsDir = "bpm.d"
+
+// A job configuration can be split across bpm.yml and any number of
+// fragments in bpm.d/*.yml. This lets a job template each optional process in
+// its own file rather than wrapping it in conditionals. The files are merged
+// in lexical order with bpm.yml first:
+//
+//   * processes: the processes in every file are combined. Each process may
+//     only be defined once.
+//   * defaults: may only be defined in one of the files and apply to every
+//     process in the job.
+//
+// Each file may be written in any version of the configuration format. The
+// bpm.yml file may be missing if the fragments define every process.
+
+// source is the place where part of a merged job configuration was defined:
+// the file it was in, its path in that file, the line index of the file, and
+// the version of the format the file was written in. The file is empty if the
+// job configuration is a single file.
+type source struct {
+	file    string
+	path    string
+	lines   lineIndex
+	version int
+}
+
+// locate fills in the file and line of an error whose path is in this
+// source's file.
+func (s source) locate(err ValidationError) ValidationError {
+	err.File = s.file
+	if err.Line == 0 {
+		err.Line = s.lines.lineFor(err.Path)
+	}
+
+	return err
+}
+
+func (c *JobConfig) processSource(i int) source {
+	if i < len(c.processSources) {
+		return c.processSources[i]
+	}
+
+	return source{path: indexPath("processes", i), lines: c.lines, version: c.Version}
+}
+
+func (c *JobConfig) defaultsSource() source {
+	if c.defaultsFrom != nil {
+		return *c.defaultsFrom
+	}
+
+	return source{path: "defaults", lines: c.lines, version: c.Version}
+}
+
+// findFragments returns the paths of the fragments beside the job
+// configuration at configPath in lexical order.
+func findFragments(configPath string) ([]string, error) {
+	dir := filepath.Join(filepath.Dir(configPath), fragmentsDir)
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var fragments []string
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".yml" {
+			continue
+		}
+
+		fragments = append(fragments, filepath.Join(dir, info.Name()))
+	}
+
+	return fragments, nil
+}
+
+func parseJobConfigFragments(configPath string, fragments []string, unmarshal unmarshalFunc) (*JobConfig, error) {
+	paths := fragments
+	if _, err := os.Stat(configPath); err == nil {
+		paths = append([]string{configPath}, fragments...)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	merged := &JobConfig{}
+	defined := map[string]string{}
+
+	var verrs ValidationErrors
+	for i, path := range paths {
+		// Files are named relative to the directory containing bpm.yml so
+		// that it is clear which fragment they are.
+		file, err := filepath.Rel(filepath.Dir(configPath), path)
+		if err != nil {
+			file = path
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := parseJobConfigData(data, unmarshal)
+		if fileErrs, ok := err.(ValidationErrors); ok {
+			for _, err := range fileErrs {
+				err.File = file
+				verrs = append(verrs, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+
+		if i == 0 {
+			merged.Version = cfg.Version
+		}
+
+		if cfg.Defaults != nil {
+			src := source{file: file, path: "defaults", lines: cfg.lines, version: cfg.Version}
+			if merged.defaultsFrom != nil {
+				verrs = append(verrs, src.locate(ValidationError{
+					Path:    src.path,
+					Message: fmt.Sprintf("defaults are already defined in %s", merged.defaultsFrom.file),
+				}))
+			} else {
+				merged.Defaults = cfg.Defaults
+				merged.defaultsFrom = &src
+			}
+		}
+
+		for j, p := range cfg.Processes {
+			src := source{file: file, path: indexPath("processes", j), lines: cfg.lines, version: cfg.Version}
+
+			if p != nil && p.Name != "" {
+				if other, ok := defined[p.Name]; ok {
+					verrs = append(verrs, src.locate(ValidationError{
+						Path:    joinPath(src.path, "name"),
+						Message: fmt.Sprintf("process %q is already defined in %s", p.Name, other),
+					}))
+					continue
+				}
+				defined[p.Name] = file
+			}
+
+			merged.Processes = append(merged.Processes, p)
+			merged.processSources = append(merged.processSources, src)
+		}
+	}
+
+	if err := verrs.errorOrNil(); err != nil {
+		return nil, err
+	}
+
+	merged.applyDefaults()
+
+	return merged, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+)
+
+var _ = Describe("Fragments", func() {
+	It("merges the fragments into the job configuration in lexical order", func() {
+		cfg, err := config.ParseJobConfig("testdata/fragments/bpm.yml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Validate("/var/vcap", []string{})).To(Succeed())
+
+		var names []string
+		for _, p := range cfg.Processes {
+			names = append(names, p.Name)
+		}
+		Expect(names).To(Equal([]string{"server", "metrics", "worker"}))
+	})
+
+	It("applies the defaults to the processes in every fragment", func() {
+		cfg, err := config.ParseJobConfig("testdata/fragments/bpm.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.Processes[0].Env).To(Equal(map[string]string{"LOG_LEVEL": "info"}))
+		Expect(cfg.Processes[1].Env).To(Equal(map[string]string{"LOG_LEVEL": "info"}))
+		Expect(cfg.Processes[2].Env).To(Equal(map[string]string{"LOG_LEVEL": "debug"}))
+	})
+
+	It("reads each fragment in its own version of the format", func() {
+		cfg, err := config.ParseJobConfig("testdata/fragments/bpm.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.Version).To(Equal(2))
+		Expect(cfg.Processes[1].WorkDir).To(Equal("/var/vcap/data/server/metrics"))
+	})
+
+	It("does not need a bpm.yml if the fragments define the processes", func() {
+		cfg, err := config.ParseJobConfig("testdata/fragments-only/bpm.yml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Processes).To(HaveLen(1))
+		Expect(cfg.Processes[0].Name).To(Equal("server"))
+	})
+
+	It("returns a not exist error if there is no bpm.yml or fragments", func() {
+		_, err := config.ParseJobConfig("testdata/fragments-missing/bpm.yml")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("does not allow a process or the defaults to be defined twice", func() {
+		_, err := config.ParseJobConfig("testdata/fragments-duplicate/bpm.yml")
+		Expect(err).To(HaveOccurred())
+
+		verrs, ok := err.(config.ValidationErrors)
+		Expect(ok).To(BeTrue())
+		Expect(verrs).To(ConsistOf(
+			config.ValidationError{
+				File:    "bpm.d/10-worker.yml",
+				Path:    "defaults",
+				Line:    3,
+				Message: "defaults are already defined in bpm.yml",
+			},
+			config.ValidationError{
+				File:    "bpm.d/10-worker.yml",
+				Path:    "processes[1].name",
+				Line:    9,
+				Message: `process "server" is already defined in bpm.yml`,
+			},
+		))
+		Expect(err.Error()).To(ContainSubstring(`bpm.d/10-worker.yml: processes[1].name (line 9): process "server" is already defined in bpm.yml`))
+	})
+
+	It("names the file which each validation error was found in", func() {
+		cfg, err := config.ParseJobConfig("testdata/fragments-invalid/bpm.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cfg.ValidateStrict("/var/vcap", []string{})
+		Expect(err).To(HaveOccurred())
+
+		verrs, ok := err.(config.ValidationErrors)
+		Expect(ok).To(BeTrue())
+		Expect(verrs).To(ConsistOf(
+			config.ValidationError{
+				File:    "bpm.yml",
+				Path:    "defaults.capabilities[0]",
+				Line:    5,
+				Message: "invalid capability name: CAP_NOT_A_CAPABILITY (capabilities are upper case and should not include the CAP_ prefix)",
+			},
+			config.ValidationError{
+				File:    "bpm.d/metrics.yml",
+				Path:    "processes[0].executable",
+				Line:    4,
+				Message: "is required",
+			},
+			config.ValidationError{
+				File:    "bpm.d/metrics.yml",
+				Path:    "processes[0].hooks.pre_start",
+				Line:    6,
+				Message: "must be an absolute path: bin/setup",
+			},
+		))
+	})
+
+	It("names the fragment which a parsing error was found in", func() {
+		_, err := config.ParseJobConfigStrict("testdata/fragments-invalid/bpm.yml")
+		Expect(err).To(HaveOccurred())
+
+		verrs, ok := err.(config.ValidationErrors)
+		Expect(ok).To(BeTrue())
+		Expect(verrs).To(ConsistOf(config.ValidationError{
+			File:    "bpm.d/worker.yml",
+			Path:    "processes[0].unknown_field",
+			Line:    6,
+			Message: `unknown field "unknown_field"`,
+		}))
+	})
+})
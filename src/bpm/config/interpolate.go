@@ -0,0 +1,239 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Version 2 of the job configuration format can refer to variables in the
+// executable, args, env, workdir, volume paths, and readiness paths of a
+// process by writing ${NAME}. A variable is either one of the well-known
+// variables of the job (see BPMConfig.Variables), PROCESS (the name of the
+// process), or another variable in the env of the process. A literal ${ is
+// written as $${.
+
+// Interpolate expands the variable references in every process using the
+// well-known variables in vars. Any reference to a variable which is not
+// defined is returned as a ValidationError. Processes written in a version
+// of the format before variables were introduced are not changed.
+func (c *JobConfig) Interpolate(vars map[string]string) error {
+	var verrs ValidationErrors
+
+	for i, v := range c.Processes {
+		if v == nil || c.processSource(i).version < 2 {
+			continue
+		}
+
+		for _, err := range v.interpolate(vars) {
+			verrs = append(verrs, c.locate(i, err))
+		}
+	}
+
+	// A problem with an inherited entry is found in every process which
+	// inherits it but it only needs to be reported once.
+	return verrs.unique().errorOrNil()
+}
+
+func (c *ProcessConfig) interpolate(vars map[string]string) ValidationErrors {
+	var verrs ValidationErrors
+
+	in := &interpolator{
+		vars:      map[string]string{"PROCESS": c.Name},
+		env:       c.Env,
+		resolved:  map[string]string{},
+		resolving: map[string]bool{},
+	}
+	for k, v := range vars {
+		in.vars[k] = v
+	}
+
+	expand := func(path string, s *string) {
+		expanded, err := in.expand(*s)
+		if err == errIndirect {
+			return
+		}
+
+		if err != nil {
+			verrs.add(path, "%s", err)
+			return
+		}
+
+		*s = expanded
+	}
+
+	var names []string
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := map[string]string{}
+	for _, name := range names {
+		value, err := in.resolve(name)
+		switch {
+		case err == errIndirect:
+			value = c.Env[name]
+		case err != nil:
+			verrs.add(joinPath("env", name), "%s", err)
+			value = c.Env[name]
+		}
+		env[name] = value
+	}
+	if c.Env != nil {
+		c.Env = env
+	}
+
+	c.eachInterpolated(expand)
+
+	return verrs
+}
+
+// eachInterpolated calls fn with the path of each field of the process which
+// can refer to variables, other than the variables in its env.
+func (c *ProcessConfig) eachInterpolated(fn func(path string, s *string)) {
+	fn("executable", &c.Executable)
+
+	for i := range c.Args {
+		fn(indexPath("args", i), &c.Args[i])
+	}
+
+	fn("workdir", &c.WorkDir)
+
+	for i := range c.AdditionalVolumes {
+		fn(indexPath("additional_volumes", i)+".path", &c.AdditionalVolumes[i].Path)
+	}
+
+	for i := range c.DependsOn {
+		if c.DependsOn[i].Ready != nil {
+			fn(indexPath("depends_on", i)+".ready.path", &c.DependsOn[i].Ready.Path)
+		}
+	}
+
+	if c.Unsafe != nil {
+		for i := range c.Unsafe.UnrestrictedVolumes {
+			fn(indexPath("unsafe.unrestricted_volumes", i)+".path", &c.Unsafe.UnrestrictedVolumes[i].Path)
+		}
+	}
+}
+
+// errIndirect is returned when a reference cannot be expanded because the
+// env variable it refers to has a problem of its own. The problem is
+// reported against that variable instead.
+var errIndirect = errors.New("variable refers to an invalid variable")
+
+type circularReference string
+
+func (name circularReference) Error() string {
+	return fmt.Sprintf("circular reference to %s", string(name))
+}
+
+type interpolator struct {
+	vars      map[string]string
+	env       map[string]string
+	resolved  map[string]string
+	resolving map[string]bool
+}
+
+// lookup returns the value of a variable. The well-known variables are
+// checked before the env of the process so that they always refer to the
+// same thing.
+func (in *interpolator) lookup(name string) (string, error) {
+	if value, ok := in.vars[name]; ok {
+		return value, nil
+	}
+
+	if _, ok := in.env[name]; !ok {
+		return "", fmt.Errorf("undefined variable: %s", name)
+	}
+
+	if in.resolving[name] {
+		return "", circularReference(name)
+	}
+
+	value, err := in.resolve(name)
+	if _, ok := err.(circularReference); ok {
+		return "", err
+	}
+
+	if err != nil {
+		return "", errIndirect
+	}
+
+	return value, nil
+}
+
+// resolve expands the references in an env variable of the process.
+func (in *interpolator) resolve(name string) (string, error) {
+	if value, ok := in.resolved[name]; ok {
+		return value, nil
+	}
+
+	in.resolving[name] = true
+	defer delete(in.resolving, name)
+
+	value, err := in.expand(in.env[name])
+	if err != nil {
+		return "", err
+	}
+
+	in.resolved[name] = value
+
+	return value, nil
+}
+
+// expand replaces every ${NAME} in s with the value of the variable.
+func (in *interpolator) expand(s string) (string, error) {
+	var expanded strings.Builder
+
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			expanded.WriteString(s)
+			return expanded.String(), nil
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			expanded.WriteString(s[:i-1])
+			expanded.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		expanded.WriteString(s[:i])
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference: %s", s[i:])
+		}
+
+		name := s[i+2 : i+end]
+		if name == "" {
+			return "", errors.New("empty variable reference: ${}")
+		}
+
+		value, err := in.lookup(name)
+		if err != nil {
+			return "", err
+		}
+
+		expanded.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
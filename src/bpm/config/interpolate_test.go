@@ -0,0 +1,93 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+)
+
+var _ = Describe("Interpolate", func() {
+	var bpmCfg *config.BPMConfig
+
+	BeforeEach(func() {
+		bpmCfg = config.NewBPMConfig("/var/vcap", "example", "server")
+	})
+
+	It("expands references to the well-known variables and env", func() {
+		cfg, err := config.ParseJobConfigStrict("testdata/example-v2.yml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Interpolate(bpmCfg.Variables())).To(Succeed())
+		Expect(cfg.Validate("/var/vcap", bpmCfg.DefaultVolumes())).To(Succeed())
+
+		server := cfg.Processes[0]
+		Expect(server.Executable).To(Equal("/var/vcap/jobs/example/bin/server"))
+		Expect(server.Args).To(Equal([]string{
+			"--config", "/var/vcap/jobs/example/config/server.yml",
+			"--price", "${PRICE}",
+		}))
+		Expect(server.Env).To(Equal(map[string]string{
+			"CACHE_DIR":  "/var/vcap/data/example/cache",
+			"CONFIG":     "/var/vcap/jobs/example/config/server.yml",
+			"PIDFILE":    "/var/vcap/sys/run/example/server.pid",
+			"SERVER_LOG": "/var/vcap/sys/log/example/server.log",
+			"LOGS":       "/var/vcap/sys/log/example",
+		}))
+		Expect(server.WorkDir).To(Equal("/var/vcap/store/example"))
+		Expect(server.AdditionalVolumes).To(Equal([]config.Volume{
+			{Path: "/var/vcap/data/example/cache", Writable: true},
+		}))
+	})
+
+	It("reports every reference which cannot be expanded", func() {
+		cfg, err := config.ParseJobConfigStrict("testdata/example-v2-invalid.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cfg.Interpolate(bpmCfg.Variables())
+		Expect(err).To(HaveOccurred())
+
+		verrs, ok := err.(config.ValidationErrors)
+		Expect(ok).To(BeTrue())
+		Expect(verrs).To(ConsistOf(
+			config.ValidationError{Path: "processes[0].executable", Line: 8, Message: "undefined variable: BINARY"},
+			config.ValidationError{Path: "processes[0].args[0]", Line: 10, Message: "unterminated variable reference: ${PROCESS"},
+			config.ValidationError{Path: "processes[0].env.FIRST", Line: 12, Message: "circular reference to FIRST"},
+			config.ValidationError{Path: "processes[0].env.SECOND", Line: 13, Message: "circular reference to SECOND"},
+			config.ValidationError{Path: "defaults.env.REGION", Line: 5, Message: "undefined variable: ZONE"},
+		))
+	})
+
+	It("does not expand configuration written before version 2", func() {
+		path := writeTempConfig(`version: 1
+processes:
+- name: example
+  executable: /bin/sh
+  args:
+  - -c
+  - echo ${HOME}
+`)
+		defer os.Remove(path)
+
+		cfg, err := config.ParseJobConfig(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Interpolate(bpmCfg.Variables())).To(Succeed())
+		Expect(cfg.Processes[0].Args).To(Equal([]string{"-c", "echo ${HOME}"}))
+	})
+})
@@ -16,110 +16,298 @@
 package config
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
+	"code.cloudfoundry.org/bytefmt"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// The schema and description tags on the configuration types are used to
+// generate the JSON schema for job configuration (see schema.go). The
+// descriptions should be kept in step with docs/config.md.
+
 type JobConfig struct {
-	Processes []*ProcessConfig `yaml:"processes"`
+	Version   int              `yaml:"version,omitempty" description:"The version of the configuration format. If not specified this is version 1."`
+	Defaults  *Defaults        `yaml:"defaults,omitempty" description:"Configuration which is shared by every process in your job."`
+	Processes []*ProcessConfig `yaml:"processes" schema:"required" description:"A top-level listing of all of the processes in your job."`
+
+	// lines records where each field was found in the configuration file so
+	// that validation errors can refer to it.
+	lines lineIndex
+
+	// processSources and defaultsFrom record which file each process and the
+	// defaults were defined in when the configuration is made up of
+	// fragments (see fragments.go).
+	processSources []source
+	defaultsFrom   *source
 }
 
 type ProcessConfig struct {
-	Name              string            `yaml:"name"`
-	Executable        string            `yaml:"executable"`
-	Args              []string          `yaml:"args"`
-	Env               map[string]string `yaml:"env"`
-	AdditionalVolumes []Volume          `yaml:"additional_volumes"`
-	Capabilities      []string          `yaml:"capabilities"`
-	EphemeralDisk     bool              `yaml:"ephemeral_disk"`
-	Hooks             *Hooks            `yaml:"hooks,omitempty"`
-	Limits            *Limits           `yaml:"limits"`
-	PersistentDisk    bool              `yaml:"persistent_disk"`
-	WorkDir           string            `yaml:"workdir"`
-	Unsafe            *Unsafe           `yaml:"unsafe"`
+	Name              string            `yaml:"name" schema:"required" description:"The name of this process."`
+	Executable        string            `yaml:"executable" schema:"required" description:"The path to the executable file for this process."`
+	Args              []string          `yaml:"args,omitempty" description:"The arguments which will be passed to the executable of this process."`
+	Env               map[string]string `yaml:"env,omitempty" description:"Any additional environment variables to be included in the environment of this process."`
+	EnvDefaults       *bool             `yaml:"env_defaults,omitempty" description:"Whether or not bpm should add TMPDIR, LANG, PATH, and HOME to the environment of this process. If not specified this is true."`
+	UnsetEnv          []string          `yaml:"unset_env,omitempty" description:"Environment variables which should be removed from the environment of this process."`
+	Secrets           map[string]string `yaml:"secrets,omitempty" description:"Files on the host whose contents are made available to this process in /run/secrets. Each key is the name of a file in /run/secrets."`
+	AdditionalVolumes []Volume          `yaml:"additional_volumes,omitempty" description:"A list of additional volumes to mount inside this process."`
+	Capabilities      []string          `yaml:"capabilities,omitempty" description:"The list of capabilities (without CAP_) which should be granted to this process."`
+	EphemeralDisk     bool              `yaml:"ephemeral_disk,omitempty" description:"Whether or not an ephemeral disk should be mounted into the container at /var/vcap/data/JOB."`
+	Hooks             *Hooks            `yaml:"hooks,omitempty" description:"The hook configuration for this process."`
+	Limits            *Limits           `yaml:"limits,omitempty" description:"The limit configuration for this process."`
+	PersistentDisk    bool              `yaml:"persistent_disk,omitempty" description:"Whether or not a persistent disk should be mounted into the container at /var/vcap/store/JOB."`
+	WorkDir           string            `yaml:"workdir,omitempty" description:"The working directory for this process."`
+	Unsafe            *Unsafe           `yaml:"unsafe,omitempty" description:"The unsafe configuration for this process."`
+	RemoveDefaults    *RemoveDefaults   `yaml:"remove_defaults,omitempty" description:"The entries of the job defaults which this process should not inherit."`
+	DependsOn         []Dependency      `yaml:"depends_on,omitempty" description:"The processes in this job which must be started before this process."`
+	InjectBoshEnv     bool              `yaml:"inject_bosh_env,omitempty" description:"Whether or not variables describing the BOSH instance (e.g. BOSH_DEPLOYMENT) should be included in the environment of this process."`
+	Restart           string            `yaml:"restart,omitempty" description:"When bpm supervise should restart this process after it exits: always, on-failure, or never. If not specified this is never."`
+
+	// origins records where each inherited or merged entry was defined in the
+	// configuration file (see Defaults).
+	origins map[string]string
 }
 
 type Limits struct {
-	Memory    *string `yaml:"memory"`
-	OpenFiles *uint64 `yaml:"open_files"`
-	Processes *int64  `yaml:"processes"`
+	Memory    *string `yaml:"memory,omitempty" description:"The memory limit to apply to this process e.g. 1G, 256M."`
+	OpenFiles *uint64 `yaml:"open_files,omitempty" description:"The number of files this process is allowed to have open at any one time."`
+	Processes *int64  `yaml:"processes,omitempty" description:"The number of processes which this process is allowed to have running at any one moment."`
 }
 
 type Hooks struct {
-	PreStart string `yaml:"pre_start"`
+	PreStart string `yaml:"pre_start,omitempty" description:"The path to an executable to run before starting the main executable of this process."`
 }
 
 type Volume struct {
-	Path            string `yaml:"path"`
-	Writable        bool   `yaml:"writable"`
-	AllowExecutions bool   `yaml:"allow_executions"`
-	MountOnly       bool   `yaml:"mount_only"`
+	Path            string `yaml:"path" schema:"required" description:"The absolute path of the volume inside this process."`
+	Writable        bool   `yaml:"writable,omitempty" description:"Whether or not this volume is writable by the process."`
+	AllowExecutions bool   `yaml:"allow_executions,omitempty" description:"Whether or not executable files can be executed from this volume."`
+	MountOnly       bool   `yaml:"mount_only,omitempty" description:"Whether or not BPM should just mount this directory rather than creating and chowning a backing directory too."`
 }
 
 type Unsafe struct {
-	Privileged          bool     `yaml:"privileged"`
-	UnrestrictedVolumes []Volume `yaml:"unrestricted_volumes"`
+	Privileged          bool     `yaml:"privileged,omitempty" description:"Whether or not this process should execute with increased privileges."`
+	UnrestrictedVolumes []Volume `yaml:"unrestricted_volumes,omitempty" description:"An unrestricted list of additional volumes to mount inside this process."`
 }
 
+// ParseJobConfig reads the job configuration at configPath along with any
+// fragments in the bpm.d directory beside it. Fields which are not part of the
+// configuration format are ignored.
 func ParseJobConfig(configPath string) (*JobConfig, error) {
+	return parseJobConfig(configPath, yaml.Unmarshal)
+}
+
+// ParseJobConfigStrict is like ParseJobConfig except that any fields which
+// are not part of the configuration format are reported as validation errors.
+func ParseJobConfigStrict(configPath string) (*JobConfig, error) {
+	return parseJobConfig(configPath, yaml.UnmarshalStrict)
+}
+
+func parseJobConfig(configPath string, unmarshal unmarshalFunc) (*JobConfig, error) {
+	fragments, err := findFragments(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fragments) > 0 {
+		return parseJobConfigFragments(configPath, fragments, unmarshal)
+	}
+
 	data, err := ioutil.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg := JobConfig{}
+	cfg, err := parseJobConfigData(data, unmarshal)
+	if err != nil {
+		return nil, err
+	}
 
-	err = yaml.Unmarshal(data, &cfg)
+	cfg.applyDefaults()
+
+	return cfg, nil
+}
+
+func parseJobConfigData(data []byte, unmarshal unmarshalFunc) (*JobConfig, error) {
+	lines := indexLines(data)
+
+	version, err := configVersion(data, lines)
 	if err != nil {
 		return nil, err
 	}
 
-	return &cfg, nil
+	cfg, err := versions[version].parse(data, unmarshal)
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		return nil, yamlErrors(typeErr.Errors, lines)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Version = version
+	cfg.lines = lines
+
+	return cfg, nil
 }
 
+// Validate checks every process in the job configuration. If there are any
+// problems then all of them are returned as ValidationErrors.
 func (c *JobConfig) Validate(boshRoot string, defaultVolumes []string) error {
-	for _, v := range c.Processes {
-		if err := v.Validate(boshRoot, defaultVolumes); err != nil {
-			return err
+	return c.validate(boshRoot, defaultVolumes, false).errorOrNil()
+}
+
+// ValidateStrict is like Validate except that it also rejects configuration
+// which bpm is able to run but which is probably a mistake (see Warnings).
+func (c *JobConfig) ValidateStrict(boshRoot string, defaultVolumes []string) error {
+	return c.validate(boshRoot, defaultVolumes, true).errorOrNil()
+}
+
+// Warnings returns the problems which ValidateStrict rejects but which do not
+// stop the job from being run.
+func (c *JobConfig) Warnings() []ValidationError {
+	var verrs ValidationErrors
+
+	for i, v := range c.Processes {
+		if v == nil {
+			continue
+		}
+
+		for _, err := range v.validateStrict() {
+			verrs = append(verrs, c.locate(i, err))
+		}
+	}
+
+	return verrs.unique()
+}
+
+func (c *JobConfig) validate(boshRoot string, defaultVolumes []string, strict bool) ValidationErrors {
+	var verrs ValidationErrors
+
+	for i, v := range c.Processes {
+		src := c.processSource(i)
+		if v == nil {
+			verrs = append(verrs, src.locate(ValidationError{Path: src.path, Message: "process definition is empty"}))
+			continue
 		}
+
+		for _, err := range v.validate(boshRoot, defaultVolumes) {
+			verrs = append(verrs, c.locate(i, err))
+		}
+
+		if strict {
+			for _, err := range v.validateStrict() {
+				verrs = append(verrs, c.locate(i, err))
+			}
+		}
+
+		for _, err := range c.validateDependencies(i) {
+			verrs = append(verrs, c.locate(i, err))
+		}
+
+		for _, err := range v.validateRemoveDefaults(c.Defaults).prefixed(src.path) {
+			verrs = append(verrs, src.locate(err))
+		}
+	}
+
+	// A problem with an inherited entry is found in every process which
+	// inherits it but it only needs to be reported once.
+	return verrs.unique()
+}
+
+// locate fills in where a problem with a field of the i-th process was found
+// in the configuration. Problems with entries which the process inherited
+// from the defaults are found in the defaults.
+func (c *JobConfig) locate(i int, err ValidationError) ValidationError {
+	src := c.processSource(i)
+
+	err.Path = c.Processes[i].origin(src.path, err.Path)
+	if strings.HasPrefix(err.Path, "defaults") {
+		return c.defaultsSource().locate(err)
 	}
 
-	return nil
+	return src.locate(err)
 }
 
+// Validate checks the process configuration. If there are any problems then
+// all of them are returned as ValidationErrors.
 func (c *ProcessConfig) Validate(boshRoot string, defaultVolumes []string) error {
+	return c.validate(boshRoot, defaultVolumes).errorOrNil()
+}
+
+func (c *ProcessConfig) validate(boshRoot string, defaultVolumes []string) ValidationErrors {
+	var verrs ValidationErrors
+
 	if c.Name == "" {
-		return errors.New("invalid config: name")
+		verrs.add("name", "is required")
 	}
 
 	if c.Executable == "" {
-		return errors.New("invalid config: executable")
+		verrs.add("executable", "is required")
+	}
+
+	verrs = append(verrs, c.validateDependsOn()...)
+
+	switch c.Restart {
+	case "", RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		verrs.add("restart", "invalid restart policy %q: must be one of always, on-failure, or never", c.Restart)
+	}
+
+	for i, name := range c.UnsetEnv {
+		if name == "" || strings.Contains(name, "=") {
+			verrs.add(indexPath("unset_env", i), "invalid environment variable name: %q", name)
+		}
+	}
+
+	var secrets []string
+	for name := range c.Secrets {
+		secrets = append(secrets, name)
+	}
+	sort.Strings(secrets)
+
+	for _, name := range secrets {
+		path := joinPath("secrets", name)
+
+		if !secretName.MatchString(name) || name == "." || name == ".." {
+			verrs.add(path, "invalid secret name: %s (names may only contain letters, digits, '.', '-', and '_')", name)
+		}
+
+		if !filepath.IsAbs(c.Secrets[name]) {
+			verrs.add(path, "must be an absolute path: %s", c.Secrets[name])
+		}
 	}
 
 	dataPrefix := filepath.Join(boshRoot, "data")
 	storePrefix := filepath.Join(boshRoot, "store")
 	socketPrefix := filepath.Join(boshRoot, "sys", "run")
 
-	for _, vol := range c.AdditionalVolumes {
+	for i, vol := range c.AdditionalVolumes {
+		path := indexPath("additional_volumes", i) + ".path"
+
 		volCleaned := filepath.Clean(vol.Path)
 		if volCleaned != vol.Path {
-			return fmt.Errorf("volume path must be canonical, expected %s but got %s", volCleaned, vol.Path)
+			verrs.add(path, "volume path must be canonical, expected %s but got %s", volCleaned, vol.Path)
+			continue
 		}
 
 		if contains(defaultVolumes, volCleaned) {
-			return fmt.Errorf(
+			verrs.add(
+				path,
 				"invalid volume path: %s cannot conflict with default job data or store directories",
 				vol.Path,
 			)
+			continue
 		}
 
 		if !pathIsIn(volCleaned, dataPrefix, storePrefix, socketPrefix) {
-			return fmt.Errorf(
+			verrs.add(
+				path,
 				"invalid volume path: %s must be within (%s, %s, %s)",
 				vol.Path,
 				dataPrefix,
@@ -129,7 +317,76 @@ func (c *ProcessConfig) Validate(boshRoot string, defaultVolumes []string) error
 		}
 	}
 
-	return nil
+	return verrs
+}
+
+// validateStrict finds configuration which bpm is able to run but which is
+// probably a mistake. bpm validate rejects it while the other commands only
+// log it.
+func (c *ProcessConfig) validateStrict() ValidationErrors {
+	var verrs ValidationErrors
+
+	for i, capability := range c.Capabilities {
+		if !capabilityName.MatchString(capability) || strings.HasPrefix(capability, "CAP_") {
+			verrs.add(indexPath("capabilities", i), "invalid capability name: %s (capabilities are upper case and should not include the CAP_ prefix)", capability)
+		}
+	}
+
+	if c.Limits != nil {
+		verrs = append(verrs, c.Limits.validate().prefixed("limits")...)
+	}
+
+	if c.Hooks != nil && c.Hooks.PreStart != "" && !filepath.IsAbs(c.Hooks.PreStart) {
+		verrs.add("hooks.pre_start", "must be an absolute path: %s", c.Hooks.PreStart)
+	}
+
+	if c.Unsafe != nil {
+		for i, vol := range c.Unsafe.UnrestrictedVolumes {
+			path := indexPath("unsafe.unrestricted_volumes", i) + ".path"
+
+			if !filepath.IsAbs(vol.Path) {
+				verrs.add(path, "volume path must be absolute: %s", vol.Path)
+				continue
+			}
+
+			if volCleaned := filepath.Clean(vol.Path); volCleaned != vol.Path {
+				verrs.add(path, "volume path must be canonical, expected %s but got %s", volCleaned, vol.Path)
+			}
+		}
+	}
+
+	seenVolumes := map[string]bool{}
+	for i, vol := range c.AdditionalVolumes {
+		if seenVolumes[vol.Path] {
+			verrs.add(indexPath("additional_volumes", i)+".path", "duplicate volume path: %s", vol.Path)
+		}
+		seenVolumes[vol.Path] = true
+	}
+
+	return verrs
+}
+
+func (l *Limits) validate() ValidationErrors {
+	var verrs ValidationErrors
+
+	if l.Memory != nil {
+		memory, err := bytefmt.ToBytes(*l.Memory)
+		if err != nil {
+			verrs.add("memory", "invalid memory limit %q: %s", *l.Memory, err)
+		} else if memory == 0 {
+			verrs.add("memory", "must be greater than zero")
+		}
+	}
+
+	if l.OpenFiles != nil && *l.OpenFiles == 0 {
+		verrs.add("open_files", "must be greater than zero")
+	}
+
+	if l.Processes != nil && *l.Processes <= 0 {
+		verrs.add("processes", "must be greater than zero")
+	}
+
+	return verrs
 }
 
 func (c *ProcessConfig) AddVolumes(
@@ -173,7 +430,8 @@ func (c *ProcessConfig) AddVolumes(
 // AddEnvVars allows additional environment variables to be added to a process
 // configuration after parsing the configuration file. The environment
 // variables take the form of "KEY=VALUE". If a key is specified multiple times
-// then the last valeu wins.
+// then the last valeu wins. The values are often secrets so they are never
+// included in an error.
 func (c *ProcessConfig) AddEnvVars(
 	env []string,
 	boshRoot string,
@@ -182,10 +440,10 @@ func (c *ProcessConfig) AddEnvVars(
 	if c.Env == nil {
 		c.Env = map[string]string{}
 	}
-	for _, e := range env {
+	for i, e := range env {
 		parts := strings.SplitN(e, "=", 2)
 		if len(parts) < 2 {
-			return fmt.Errorf("invalid envionment variable definition (format should be KEY=value): %q", e)
+			return fmt.Errorf("invalid environment variable definition %d (format should be KEY=value)", i+1)
 		}
 		key, value := parts[0], parts[1]
 		c.Env[key] = value
@@ -193,6 +451,14 @@ func (c *ProcessConfig) AddEnvVars(
 	return c.Validate(boshRoot, defaultVolumes)
 }
 
+// secretName matches the names which can be given to secrets. Each secret is
+// a file in /run/secrets so a name cannot contain a path separator.
+var secretName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// capabilityName matches the names of capabilities. The list of capabilities
+// grows with the kernel and so a name is not checked against it.
+var capabilityName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
 func contains(elements []string, s string) bool {
 	for _, elem := range elements {
 		if s == elem {
@@ -206,6 +472,7 @@ func contains(elements []string, s string) bool {
 func pathIsIn(path string, prefixes ...string) bool {
 	volParts := strings.Split(path, "/")
 
+prefixes:
 	for _, prefix := range prefixes {
 		validParts := strings.Split(prefix, "/")
 
@@ -215,7 +482,7 @@ func pathIsIn(path string, prefixes ...string) bool {
 
 		for i, validPart := range validParts {
 			if volParts[i] != validPart {
-				continue
+				continue prefixes
 			}
 		}
 
@@ -95,6 +95,66 @@ var _ = Describe("Config", func() {
 				Expect(err).To(HaveOccurred())
 			})
 		})
+
+		Context("when the config contains unknown fields", func() {
+			BeforeEach(func() {
+				configPath = "testdata/example-unknown-fields.yml"
+			})
+
+			It("ignores them", func() {
+				cfg, err := config.ParseJobConfig(configPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Processes).To(HaveLen(1))
+				Expect(cfg.Processes[0].Env).To(BeEmpty())
+			})
+		})
+
+		Context("when a field has the wrong type", func() {
+			BeforeEach(func() {
+				configPath = "testdata/example-wrong-type.yml"
+			})
+
+			It("returns a validation error with the path and line of the field", func() {
+				_, err := config.ParseJobConfig(configPath)
+				Expect(err).To(BeAssignableToTypeOf(config.ValidationErrors{}))
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(ConsistOf(config.ValidationError{
+					Path:    "processes[0].limits.open_files",
+					Line:    7,
+					Message: "cannot unmarshal !!str `lots` into uint64",
+				}))
+			})
+		})
+	})
+
+	Describe("ParseJobConfigStrict", func() {
+		It("parses a valid config", func() {
+			cfg, err := config.ParseJobConfigStrict("testdata/example.yml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Processes).To(HaveLen(3))
+		})
+
+		Context("when the config contains unknown fields", func() {
+			It("reports every unknown field with its path and line", func() {
+				_, err := config.ParseJobConfigStrict("testdata/example-unknown-fields.yml")
+				Expect(err).To(BeAssignableToTypeOf(config.ValidationErrors{}))
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(ConsistOf(
+					config.ValidationError{
+						Path:    "processes[0].enviroment",
+						Line:    5,
+						Message: `unknown field "enviroment"`,
+					},
+					config.ValidationError{
+						Path:    "processes[0].additional_volumes[0].writeable",
+						Line:    9,
+						Message: `unknown field "writeable"`,
+					},
+				))
+			})
+		})
 	})
 
 	Describe("Validate", func() {
@@ -149,6 +209,18 @@ var _ = Describe("Config", func() {
 					{Path: "//var/vcap/data/valid"},
 				}
 				Expect(jobCfg.Validate("/var/vcap", []string{})).To(HaveOccurred())
+
+				for _, path := range []string{
+					"/etc/x/y/z/w",
+					"/var/vcap/other/valid",
+					"/var/vcap/database/valid",
+					"/var/vcap/sys/log/valid",
+				} {
+					jobCfg.Processes[0].AdditionalVolumes = []config.Volume{
+						{Path: path},
+					}
+					Expect(jobCfg.Validate("/var/vcap", []string{})).To(HaveOccurred(), path)
+				}
 			})
 		})
 
@@ -179,6 +251,192 @@ var _ = Describe("Config", func() {
 				Expect(jobCfg.Validate("", []string{})).To(HaveOccurred())
 			})
 		})
+
+		Context("when the process has an invalid capability name", func() {
+			It("returns a validation error when validating strictly", func() {
+				jobCfg.Processes[0].Capabilities = []string{"NET_BIND_SERVICE", "CAP_SYS_TIME", "sys_time", "PERFMON"}
+
+				err := jobCfg.ValidateStrict("/var/vcap", []string{})
+				Expect(err).To(HaveOccurred())
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(HaveLen(2))
+				Expect(verrs[0].Path).To(Equal("processes[0].capabilities[1]"))
+				Expect(verrs[1].Path).To(Equal("processes[0].capabilities[2]"))
+			})
+		})
+
+		Context("when the process has invalid limits", func() {
+			It("returns a validation error for each limit when validating strictly", func() {
+				memory := "lots"
+				openFiles := uint64(0)
+				processes := int64(-1)
+				jobCfg.Processes[0].Limits = &config.Limits{
+					Memory:    &memory,
+					OpenFiles: &openFiles,
+					Processes: &processes,
+				}
+
+				err := jobCfg.ValidateStrict("/var/vcap", []string{})
+				Expect(err).To(HaveOccurred())
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(HaveLen(3))
+				Expect(verrs[0].Path).To(Equal("processes[0].limits.memory"))
+				Expect(verrs[1].Path).To(Equal("processes[0].limits.open_files"))
+				Expect(verrs[2].Path).To(Equal("processes[0].limits.processes"))
+			})
+		})
+
+		Context("when the process has valid limits", func() {
+			It("does not error", func() {
+				memory := "256M"
+				openFiles := uint64(100)
+				processes := int64(10)
+				jobCfg.Processes[0].Limits = &config.Limits{
+					Memory:    &memory,
+					OpenFiles: &openFiles,
+					Processes: &processes,
+				}
+
+				Expect(jobCfg.ValidateStrict("/var/vcap", []string{})).To(Succeed())
+			})
+		})
+
+		Context("when the pre-start hook is not an absolute path", func() {
+			It("returns a validation error when validating strictly", func() {
+				jobCfg.Processes[0].Hooks = &config.Hooks{PreStart: "bin/pre-start"}
+
+				err := jobCfg.ValidateStrict("/var/vcap", []string{})
+				Expect(err).To(MatchError(ContainSubstring("processes[0].hooks.pre_start: must be an absolute path")))
+			})
+		})
+
+		Context("when the config unsets invalid environment variable names", func() {
+			It("returns a validation error for each name", func() {
+				jobCfg.Processes[0].UnsetEnv = []string{"HOME", "", "A=B"}
+
+				err := jobCfg.Validate("/var/vcap", []string{})
+				Expect(err).To(HaveOccurred())
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(HaveLen(2))
+				Expect(verrs[0].Path).To(Equal("processes[0].unset_env[1]"))
+				Expect(verrs[1].Path).To(Equal("processes[0].unset_env[2]"))
+				Expect(verrs[1].Message).To(Equal(`invalid environment variable name: "A=B"`))
+			})
+		})
+
+		Context("when the process has an unknown restart policy", func() {
+			It("returns a validation error", func() {
+				jobCfg.Processes[0].Restart = "sometimes"
+
+				err := jobCfg.Validate("/var/vcap", []string{})
+				Expect(err).To(MatchError(ContainSubstring(`processes[0].restart: invalid restart policy "sometimes": must be one of always, on-failure, or never`)))
+			})
+		})
+
+		Context("when the config has secrets with invalid names or relative paths", func() {
+			It("returns a validation error for each secret", func() {
+				jobCfg.Processes[0].Secrets = map[string]string{
+					"db-password": "/var/vcap/jobs/example/config/db-password",
+					"../escape":   "/var/vcap/jobs/example/config/escape",
+					"tls.key":     "config/tls.key",
+				}
+
+				err := jobCfg.Validate("/var/vcap", []string{})
+				Expect(err).To(HaveOccurred())
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(HaveLen(2))
+				Expect(verrs[0].Path).To(Equal("processes[0].secrets.../escape"))
+				Expect(verrs[0].Message).To(ContainSubstring("invalid secret name: ../escape"))
+				Expect(verrs[1].Path).To(Equal("processes[0].secrets.tls.key"))
+				Expect(verrs[1].Message).To(Equal("must be an absolute path: config/tls.key"))
+			})
+		})
+
+		Context("when the config has unrestricted volumes which are not absolute and canonical", func() {
+			It("returns a validation error for each volume when validating strictly", func() {
+				jobCfg.Processes[0].Unsafe = &config.Unsafe{
+					UnrestrictedVolumes: []config.Volume{
+						{Path: "/etc"},
+						{Path: "relative"},
+						{Path: "/etc/../root"},
+					},
+				}
+
+				err := jobCfg.ValidateStrict("/var/vcap", []string{})
+				Expect(err).To(HaveOccurred())
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(HaveLen(2))
+				Expect(verrs[0].Path).To(Equal("processes[0].unsafe.unrestricted_volumes[1].path"))
+				Expect(verrs[1].Path).To(Equal("processes[0].unsafe.unrestricted_volumes[2].path"))
+			})
+		})
+
+		Context("when the config has duplicate additional_volumes", func() {
+			BeforeEach(func() {
+				jobCfg.Processes[0].AdditionalVolumes = append(
+					jobCfg.Processes[0].AdditionalVolumes,
+					config.Volume{Path: "/var/vcap/data/valid", Writable: true},
+				)
+			})
+
+			It("returns a validation error when validating strictly", func() {
+				err := jobCfg.ValidateStrict("/var/vcap", []string{})
+				Expect(err).To(MatchError(ContainSubstring("processes[0].additional_volumes[3].path: duplicate volume path")))
+			})
+
+			It("only warns about them otherwise", func() {
+				Expect(jobCfg.Validate("/var/vcap", []string{})).To(Succeed())
+
+				warnings := jobCfg.Warnings()
+				Expect(warnings).To(HaveLen(1))
+				Expect(warnings[0].Path).To(Equal("processes[0].additional_volumes[3].path"))
+				Expect(warnings[0].Message).To(Equal("duplicate volume path: /var/vcap/data/valid"))
+			})
+		})
+
+		Context("when the config has multiple problems", func() {
+			It("returns every problem with its path and line", func() {
+				cfg, err := config.ParseJobConfig("testdata/example-multiple-errors.yml")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = cfg.Validate("/var/vcap", []string{})
+				Expect(err).To(BeAssignableToTypeOf(config.ValidationErrors{}))
+
+				verrs := err.(config.ValidationErrors)
+				Expect(verrs).To(HaveLen(4))
+
+				Expect(verrs[0].Path).To(Equal("processes[1].executable"))
+				Expect(verrs[0].Line).To(Equal(7))
+				Expect(verrs[0].Message).To(Equal("is required"))
+
+				Expect(verrs[1].Path).To(Equal("processes[1].additional_volumes[1].path"))
+				Expect(verrs[1].Line).To(Equal(10))
+				Expect(verrs[1].Message).To(ContainSubstring("must be canonical"))
+
+				Expect(verrs[2].Path).To(Equal("processes[1].additional_volumes[2].path"))
+				Expect(verrs[2].Line).To(Equal(12))
+				Expect(verrs[2].Message).To(ContainSubstring("invalid volume path: /etc"))
+
+				Expect(verrs[3].Path).To(Equal("processes[2].name"))
+				Expect(verrs[3].Line).To(Equal(13))
+				Expect(verrs[3].Message).To(Equal("is required"))
+
+				Expect(err.Error()).To(HavePrefix("invalid config (4 errors):\n"))
+				Expect(err.Error()).To(ContainSubstring("  - processes[1].executable (line 7): is required\n"))
+			})
+		})
+
+		Context("when a config without line information has a problem", func() {
+			It("returns the path without a line", func() {
+				jobCfg.Processes[0].Name = ""
+				Expect(jobCfg.Validate("/var/vcap", []string{})).To(MatchError("invalid config: processes[0].name: is required"))
+			})
+		})
 	})
 
 	Describe("AddVolumes", func() {
@@ -305,11 +563,21 @@ var _ = Describe("Config", func() {
 		Context("when the environment definition contains an invalid option", func() {
 			It("returns an error", func() {
 				err := cfg.AddEnvVars(
-					[]string{"INVALID"},
+					[]string{"VALID=value", "INVALID"},
+					"/bosh/root",
+					[]string{},
+				)
+				Expect(err).To(MatchError("invalid environment variable definition 2 (format should be KEY=value)"))
+			})
+
+			It("does not include the definition in the error", func() {
+				err := cfg.AddEnvVars(
+					[]string{"s3cr3t-token"},
 					"/bosh/root",
 					[]string{},
 				)
 				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).NotTo(ContainSubstring("s3cr3t-token"))
 			})
 		})
 
@@ -325,4 +593,25 @@ var _ = Describe("Config", func() {
 			})
 		})
 	})
+
+	Describe("ShouldRestart", func() {
+		It("never restarts a process without a restart policy", func() {
+			cfg := &config.ProcessConfig{}
+			Expect(cfg.RestartPolicy()).To(Equal(config.RestartNever))
+			Expect(cfg.ShouldRestart(true)).To(BeFalse())
+			Expect(cfg.ShouldRestart(false)).To(BeFalse())
+		})
+
+		It("only restarts a failed process with the on-failure policy", func() {
+			cfg := &config.ProcessConfig{Restart: config.RestartOnFailure}
+			Expect(cfg.ShouldRestart(true)).To(BeTrue())
+			Expect(cfg.ShouldRestart(false)).To(BeFalse())
+		})
+
+		It("always restarts a process with the always policy", func() {
+			cfg := &config.ProcessConfig{Restart: config.RestartAlways}
+			Expect(cfg.ShouldRestart(true)).To(BeTrue())
+			Expect(cfg.ShouldRestart(false)).To(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,47 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+// The restart policies which tell bpm supervise what to do when a process
+// exits.
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// RestartPolicy returns the restart policy of the process.
+func (c *ProcessConfig) RestartPolicy() string {
+	if c.Restart == "" {
+		return RestartNever
+	}
+
+	return c.Restart
+}
+
+// ShouldRestart reports whether bpm supervise should restart the process
+// after it has exited. A process has failed if it exited with a non-zero
+// status, was killed by a signal, or exited in a way that could not be seen.
+func (c *ProcessConfig) ShouldRestart(failed bool) bool {
+	switch c.RestartPolicy() {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
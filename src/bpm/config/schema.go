@@ -0,0 +1,156 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON schema document. Only the parts of JSON schema which are
+// needed to describe job configuration are included.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// JobConfigSchema generates the JSON schema for job configuration files from
+// the configuration types. Each nested configuration type (e.g. process,
+// volume) is described once in the definitions of the schema.
+func JobConfigSchema() *Schema {
+	definitions := map[string]*Schema{}
+
+	schema := structSchema(reflect.TypeOf(JobConfig{}), definitions)
+	schema.Schema = jsonSchemaDraft
+	schema.Title = "bpm job configuration"
+	schema.Definitions = definitions
+
+	return schema
+}
+
+// SchemaName returns the name used for the configuration type in the schema
+// definitions and in the documentation (e.g. ProcessConfig is "process" and
+// RemoveDefaults is "remove_defaults").
+func SchemaName(t reflect.Type) string {
+	name := strings.TrimSuffix(t.Name(), "Config")
+
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func structSchema(t reflect.Type, definitions map[string]*Schema) *Schema {
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := yamlFieldName(field)
+		if name == "" {
+			continue
+		}
+
+		property := typeSchema(field.Type, definitions)
+		property.Description = field.Tag.Get("description")
+		schema.Properties[name] = property
+
+		if field.Tag.Get("schema") == "required" {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+func typeSchema(t reflect.Type, definitions map[string]*Schema) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), definitions)
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Slice:
+		return &Schema{
+			Type:  "array",
+			Items: typeSchema(t.Elem(), definitions),
+		}
+	case reflect.Map:
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: typeSchema(t.Elem(), definitions),
+		}
+	case reflect.Struct:
+		name := SchemaName(t)
+		if _, ok := definitions[name]; !ok {
+			// Reserve the name before recursing in case the type refers to
+			// itself.
+			definitions[name] = nil
+			definitions[name] = structSchema(t, definitions)
+		}
+
+		return &Schema{Ref: fmt.Sprintf("#/definitions/%s", name)}
+	default:
+		panic(fmt.Sprintf("job configuration type %s cannot be described by the schema", t))
+	}
+}
+
+func yamlFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		// unexported
+		return ""
+	}
+
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+
+	return name
+}
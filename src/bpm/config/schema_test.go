@@ -0,0 +1,195 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+)
+
+type documentedProperty struct {
+	Type     string
+	Required bool
+}
+
+var _ = Describe("JobConfigSchema", func() {
+	var schema *config.Schema
+
+	BeforeEach(func() {
+		schema = config.JobConfigSchema()
+	})
+
+	It("describes the job configuration", func() {
+		Expect(schema.Schema).To(Equal("http://json-schema.org/draft-07/schema#"))
+		Expect(schema.Type).To(Equal("object"))
+		Expect(schema.Required).To(ConsistOf("processes"))
+		Expect(schema.Properties["processes"].Type).To(Equal("array"))
+		Expect(schema.Properties["processes"].Items.Ref).To(Equal("#/definitions/process"))
+	})
+
+	It("describes each nested configuration type once", func() {
+		Expect(schema.Definitions).To(HaveLen(9))
+		Expect(schema.Definitions).To(HaveKey(config.SchemaName(reflect.TypeOf(config.ProcessConfig{}))))
+		Expect(schema.Definitions).To(HaveKey(config.SchemaName(reflect.TypeOf(config.Limits{}))))
+		Expect(schema.Definitions).To(HaveKey(config.SchemaName(reflect.TypeOf(config.Volume{}))))
+		Expect(schema.Definitions).To(HaveKey(config.SchemaName(reflect.TypeOf(config.Hooks{}))))
+		Expect(schema.Definitions).To(HaveKey(config.SchemaName(reflect.TypeOf(config.Unsafe{}))))
+		Expect(schema.Definitions).To(HaveKey("defaults"))
+		Expect(schema.Definitions).To(HaveKey("remove_defaults"))
+		Expect(schema.Definitions).To(HaveKey("dependency"))
+		Expect(schema.Definitions).To(HaveKey("readiness"))
+	})
+
+	It("rejects unknown properties", func() {
+		data, err := json.Marshal(schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"additionalProperties":false`))
+
+		for _, definition := range schema.Definitions {
+			Expect(definition.AdditionalProperties).To(Equal(false))
+		}
+	})
+
+	It("describes environment variables as a map of strings", func() {
+		env := schema.Definitions["process"].Properties["env"]
+		Expect(env.Type).To(Equal("object"))
+		Expect(env.AdditionalProperties).To(Equal(&config.Schema{Type: "string"}))
+	})
+
+	It("describes every property", func() {
+		for name, definition := range schema.Definitions {
+			for property, propertySchema := range definition.Properties {
+				Expect(propertySchema.Description).NotTo(BeEmpty(), "%s.%s has no description", name, property)
+			}
+		}
+	})
+
+	It("is in sync with the documentation", func() {
+		documented := documentedSchema("../../../docs/config.md")
+
+		generated := map[string]map[string]documentedProperty{
+			"job": documentedProperties(schema),
+		}
+		for name, definition := range schema.Definitions {
+			generated[name] = documentedProperties(definition)
+		}
+
+		Expect(documented).To(Equal(generated))
+	})
+})
+
+// documentedProperties describes the properties of a schema in the same
+// terms as the tables in the documentation.
+func documentedProperties(schema *config.Schema) map[string]documentedProperty {
+	properties := map[string]documentedProperty{}
+
+	for name, property := range schema.Properties {
+		required := false
+		for _, r := range schema.Required {
+			if r == name {
+				required = true
+			}
+		}
+
+		properties[name] = documentedProperty{
+			Type:     documentedType(property),
+			Required: required,
+		}
+	}
+
+	return properties
+}
+
+func documentedType(schema *config.Schema) string {
+	switch {
+	case schema.Ref != "":
+		return strings.TrimPrefix(schema.Ref, "#/definitions/")
+	case schema.Type == "array":
+		return documentedType(schema.Items) + "[]"
+	case schema.Type == "object":
+		return "string => " + documentedType(schema.AdditionalProperties.(*config.Schema))
+	case schema.Type == "integer":
+		return "int"
+	default:
+		return schema.Type
+	}
+}
+
+var (
+	schemaHeading = regexp.MustCompile("^#+ (?:`([a-z_]+)` )?Schema$")
+	tableRow      = regexp.MustCompile(`^\|(.*)\|$`)
+)
+
+// documentedSchema reads the schema tables from the configuration
+// documentation. The table under the "Schema" heading describes the job and
+// the tables under the "`name` Schema" headings describe each definition.
+func documentedSchema(path string) map[string]map[string]documentedProperty {
+	data, err := ioutil.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+
+	tables := map[string]map[string]documentedProperty{}
+
+	var current map[string]documentedProperty
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if matches := schemaHeading.FindStringSubmatch(line); matches != nil {
+			name := matches[1]
+			if name == "" {
+				name = "job"
+			}
+
+			current = map[string]documentedProperty{}
+			tables[name] = current
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			current = nil
+			continue
+		}
+
+		matches := tableRow.FindStringSubmatch(line)
+		if current == nil || matches == nil {
+			continue
+		}
+
+		cells := strings.Split(matches[1], "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+
+		if !strings.HasPrefix(cells[0], "`") {
+			// header or separator row
+			continue
+		}
+
+		current[strings.Trim(cells[0], "`")] = documentedProperty{
+			Type:     cells[1],
+			Required: cells[2] == "Yes",
+		}
+	}
+
+	return tables
+}
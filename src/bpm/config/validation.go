@@ -0,0 +1,318 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is a single problem found in a job configuration. Path is
+// the location of the offending field (e.g.
+// processes[2].additional_volumes[0].path) and Line is the line of the
+// configuration file it was found on, or 0 if the line is not known. File is
+// the configuration file the field is in (e.g. bpm.d/worker.yml) when the
+// job configuration is made up of fragments, and is empty otherwise.
+type ValidationError struct {
+	File    string
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	msg := e.message()
+	if e.File == "" {
+		return msg
+	}
+
+	return fmt.Sprintf("%s: %s", e.File, msg)
+}
+
+func (e ValidationError) message() string {
+	location := e.Path
+	if e.Line > 0 {
+		if location == "" {
+			location = fmt.Sprintf("line %d", e.Line)
+		} else {
+			location = fmt.Sprintf("%s (line %d)", location, e.Line)
+		}
+	}
+
+	if location == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", location, e.Message)
+}
+
+// ValidationErrors is every problem which was found in a job configuration.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return fmt.Sprintf("invalid config: %s", e[0].Error())
+	}
+
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = fmt.Sprintf("  - %s", err.Error())
+	}
+
+	return fmt.Sprintf("invalid config (%d errors):\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// errorOrNil makes sure that an empty set of errors is returned as a nil
+// error rather than a non-nil interface holding an empty slice.
+func (e ValidationErrors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *ValidationErrors) add(path, format string, args ...interface{}) {
+	*e = append(*e, ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// prefixed returns a copy of the errors with each path nested inside prefix.
+func (e ValidationErrors) prefixed(prefix string) ValidationErrors {
+	verrs := make(ValidationErrors, len(e))
+	for i, err := range e {
+		err.Path = joinPath(prefix, err.Path)
+		verrs[i] = err
+	}
+
+	return verrs
+}
+
+// unique returns the errors with any repeated errors removed.
+func (e ValidationErrors) unique() ValidationErrors {
+	var verrs ValidationErrors
+
+	seen := map[ValidationError]bool{}
+	for _, err := range e {
+		if seen[err] {
+			continue
+		}
+		seen[err] = true
+		verrs = append(verrs, err)
+	}
+
+	return verrs
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	default:
+		return prefix + "." + path
+	}
+}
+
+func indexPath(prefix string, i int) string {
+	return fmt.Sprintf("%s[%d]", prefix, i)
+}
+
+var (
+	yamlErrorLine    = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlUnknownField = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// yamlErrors converts the messages from a yaml.TypeError into validation
+// errors, using the line index to find the path of each problem.
+func yamlErrors(msgs []string, lines lineIndex) ValidationErrors {
+	var verrs ValidationErrors
+
+	for _, msg := range msgs {
+		matches := yamlErrorLine.FindStringSubmatch(msg)
+		if matches == nil {
+			verrs = append(verrs, ValidationError{Message: msg})
+			continue
+		}
+
+		line, _ := strconv.Atoi(matches[1])
+		message := matches[2]
+		if field := yamlUnknownField.FindStringSubmatch(message); field != nil {
+			message = fmt.Sprintf("unknown field %q", field[1])
+		}
+
+		verrs = append(verrs, ValidationError{
+			Path:    lines.pathFor(line),
+			Line:    line,
+			Message: message,
+		})
+	}
+
+	return verrs
+}
+
+// lineIndex maps field paths (e.g. processes[0].additional_volumes[1].path)
+// to the line on which they appear in a YAML document. It understands the
+// block style which job configuration is written in. Anything that it cannot
+// follow (e.g. flow style collections) is not indexed and errors inside them
+// are reported against the closest enclosing field instead.
+type lineIndex map[string]int
+
+type lineIndexEntry struct {
+	indent int
+	path   string
+	item   bool
+}
+
+func indexLines(data []byte) lineIndex {
+	index := lineIndex{}
+	counts := map[string]int{}
+
+	var (
+		stack       []lineIndexEntry
+		blockIndent = -1
+	)
+
+	for n, raw := range strings.Split(string(data), "\n") {
+		line := n + 1
+
+		trimmed := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(trimmed)
+
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+
+		isItem := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.indent < indent || (isItem && top.indent == indent && !top.item) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		for trimmed != "" {
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].path
+			}
+
+			if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+				path := indexPath(parent, counts[parent])
+				counts[parent]++
+				index[path] = line
+				stack = append(stack, lineIndexEntry{indent: indent, path: path, item: true})
+
+				rest := strings.TrimPrefix(trimmed, "-")
+				trimmed = strings.TrimLeft(rest, " ")
+				indent += 1 + len(rest) - len(trimmed)
+				continue
+			}
+
+			key, value, ok := splitYAMLKey(trimmed)
+			if !ok {
+				break
+			}
+
+			path := joinPath(parent, key)
+			index[path] = line
+			stack = append(stack, lineIndexEntry{indent: indent, path: path})
+
+			if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+				blockIndent = indent
+			}
+			break
+		}
+	}
+
+	return index
+}
+
+// splitYAMLKey splits a line of the form `key: value` into its key and value.
+func splitYAMLKey(s string) (string, string, bool) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, `'`) {
+		end := strings.Index(s[1:], s[:1])
+		if end < 0 {
+			return "", "", false
+		}
+
+		rest := s[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+
+		return s[1 : end+1], strings.TrimSpace(rest[1:]), true
+	}
+
+	if strings.HasSuffix(s, ":") {
+		return s[:len(s)-1], "", true
+	}
+
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		return "", "", false
+	}
+
+	return s[:i], strings.TrimSpace(s[i+2:]), true
+}
+
+// lineFor returns the line of the field at path. If that field does not
+// appear in the document (e.g. because it is missing) then the line of the
+// closest enclosing field is used.
+func (l lineIndex) lineFor(path string) int {
+	for path != "" {
+		if line, ok := l[path]; ok {
+			return line
+		}
+
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+
+	return 0
+}
+
+// pathFor returns the path of the field which starts on the given line.
+func (l lineIndex) pathFor(line int) string {
+	var found string
+	for path, l := range l {
+		// Prefer the deepest path as a sequence item and its first key share
+		// a line.
+		if l == line && len(path) > len(found) {
+			found = path
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,275 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LatestVersion is the newest version of the job configuration format. New
+// job configuration should be written in this version.
+const LatestVersion = 2
+
+type unmarshalFunc func([]byte, interface{}) error
+
+// versionFormat knows how to read one version of the job configuration format
+// and how to rewrite it in the next version.
+type versionFormat struct {
+	// parse reads configuration written in this version and normalizes it
+	// into the internal model.
+	parse func(data []byte, unmarshal unmarshalFunc) (*JobConfig, error)
+
+	// migrate rewrites configuration written in this version in the next
+	// version. It is nil for the latest version.
+	migrate func(data []byte, lines lineIndex) ([]byte, error)
+}
+
+var versions = map[int]versionFormat{
+	1: {parse: parseV1, migrate: migrateV1},
+	2: {parse: parseV2},
+}
+
+// configVersion finds the version of the format which the configuration is
+// written in. Configuration without a version was written before versions
+// were introduced and so is version 1.
+func configVersion(data []byte, lines lineIndex) (int, error) {
+	var header struct {
+		Version *int `yaml:"version"`
+	}
+
+	err := yaml.Unmarshal(data, &header)
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		return 0, yamlErrors(typeErr.Errors, lines)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if header.Version == nil {
+		return 1, nil
+	}
+
+	if _, ok := versions[*header.Version]; !ok {
+		return 0, ValidationErrors{{
+			Path:    "version",
+			Line:    lines.lineFor("version"),
+			Message: fmt.Sprintf("unsupported version %d (supported versions are 1 to %d)", *header.Version, LatestVersion),
+		}}
+	}
+
+	return *header.Version, nil
+}
+
+// MigrateJobConfig rewrites job configuration in the latest version of the
+// format. Only the parts of the configuration which differ between versions
+// are changed so that comments and formatting are kept.
+func MigrateJobConfig(data []byte) ([]byte, error) {
+	for {
+		lines := indexLines(data)
+
+		version, err := configVersion(data, lines)
+		if err != nil {
+			return nil, err
+		}
+
+		migrate := versions[version].migrate
+		if migrate == nil {
+			break
+		}
+
+		data, err = migrate(data, lines)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := parseJobConfigData(data, yaml.Unmarshal); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// setVersion replaces the version of the configuration or adds one before
+// the first top-level field if there is not one already.
+func setVersion(doc []string, lines lineIndex, version int) []string {
+	versionLine := fmt.Sprintf("version: %d", version)
+
+	if line, ok := lines["version"]; ok {
+		doc[line-1] = versionLine
+		return doc
+	}
+
+	for i, line := range doc {
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "---") {
+			continue
+		}
+
+		return append(doc[:i], append([]string{versionLine}, doc[i:]...)...)
+	}
+
+	return append([]string{versionLine}, doc...)
+}
+
+// Version 1 is the format which was used before the version field was
+// introduced.
+func parseV1(data []byte, unmarshal unmarshalFunc) (*JobConfig, error) {
+	cfg := &JobConfig{}
+	err := unmarshal(data, cfg)
+	return cfg, err
+}
+
+// Version 2 expands references to variables (e.g. ${DATA_DIR}) in the
+// configuration. A literal ${ is written as $${.
+func parseV2(data []byte, unmarshal unmarshalFunc) (*JobConfig, error) {
+	return parseV1(data, unmarshal)
+}
+
+// migrateV1 escapes the fields which can refer to variables in version 2 (see
+// Interpolate) so that they keep their meaning. Every ${ in them is written
+// as $${, including one which is already preceded by a $. Nothing else in the
+// configuration is changed.
+func migrateV1(data []byte, lines lineIndex) ([]byte, error) {
+	cfg, err := parseV1(data, yaml.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+
+	escape := map[int]bool{}
+	visit := func(path, value string) {
+		if !strings.Contains(value, "${") {
+			return
+		}
+
+		// A field inside a flow collection does not have a line of its own
+		// and so the line of the collection is escaped instead.
+		if line := lines.lineFor(path); line > 0 {
+			escape[line] = true
+		}
+	}
+
+	if d := cfg.Defaults; d != nil {
+		for name, value := range d.Env {
+			visit(joinPath("defaults.env", name), value)
+		}
+
+		for i, vol := range d.AdditionalVolumes {
+			visit(indexPath("defaults.additional_volumes", i)+".path", vol.Path)
+		}
+	}
+
+	for i, p := range cfg.Processes {
+		if p == nil {
+			continue
+		}
+
+		prefix := indexPath("processes", i)
+		for name, value := range p.Env {
+			visit(joinPath(prefix, joinPath("env", name)), value)
+		}
+
+		p.eachInterpolated(func(path string, s *string) {
+			visit(joinPath(prefix, path), *s)
+		})
+	}
+
+	doc := strings.Split(string(data), "\n")
+	for line := range escape {
+		escapeValue(doc, line-1)
+	}
+
+	return []byte(strings.Join(setVersion(doc, lines, 2), "\n")), nil
+}
+
+// escapeValue escapes the value on a line of the document without touching
+// its key or a comment after it. If the value is a block scalar then the
+// lines of the block are escaped instead.
+func escapeValue(doc []string, i int) {
+	line := doc[i]
+	keyIndent, start := valueOffset(line)
+	end := start + commentOffset(line[start:])
+	value := line[start:end]
+
+	if v := strings.TrimSpace(value); !strings.HasPrefix(v, "|") && !strings.HasPrefix(v, ">") {
+		doc[i] = line[:start] + strings.Replace(value, "${", "$${", -1) + line[end:]
+		return
+	}
+
+	for j := i + 1; j < len(doc); j++ {
+		content := strings.TrimLeft(doc[j], " ")
+		if content != "" && len(doc[j])-len(content) <= keyIndent {
+			return
+		}
+
+		doc[j] = strings.Replace(doc[j], "${", "$${", -1)
+	}
+}
+
+// valueOffset finds the column of the key on a line of the form
+// `- key: value` and the offset at which its value starts. A line which is
+// only a sequence item has its value start where the key would be.
+func valueOffset(line string) (int, int) {
+	i := len(line) - len(strings.TrimLeft(line, " "))
+	for strings.HasPrefix(line[i:], "- ") {
+		i++
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+	}
+
+	rest := line[i:]
+	if strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "'") {
+		end := strings.Index(rest[1:], rest[:1])
+		if end >= 0 && strings.HasPrefix(rest[end+2:], ":") {
+			return i, i + end + 3
+		}
+		return i, i
+	}
+
+	if j := strings.Index(rest, ": "); j >= 0 {
+		return i, i + j + 2
+	}
+
+	return i, i
+}
+
+// commentOffset finds where the comment after a value starts. A # only starts
+// a comment if it follows whitespace outside of a quoted string.
+func commentOffset(value string) int {
+	var quote byte
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,", value[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || value[i-1] == ' '):
+			return i
+		}
+	}
+
+	return len(value)
+}
@@ -0,0 +1,246 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+)
+
+var _ = Describe("Versions", func() {
+	Context("when the configuration does not have a version", func() {
+		It("is read as version 1", func() {
+			cfg, err := config.ParseJobConfig("testdata/example.yml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Version).To(Equal(1))
+		})
+
+		It("does not accept fields which are not part of version 1", func() {
+			path := writeTempConfig(`processes:
+- name: example
+  executable: /bin/example
+  work_dir: /tmp
+`)
+			defer os.Remove(path)
+
+			_, err := config.ParseJobConfigStrict(path)
+			Expect(err).To(MatchError(`invalid config: processes[0].work_dir (line 4): unknown field "work_dir"`))
+		})
+	})
+
+	Context("when the configuration is version 2", func() {
+		It("reads the same fields as version 1", func() {
+			cfg, err := config.ParseJobConfigStrict("testdata/example-v2.yml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Version).To(Equal(2))
+			Expect(cfg.Processes[0].WorkDir).To(Equal("${STORE_DIR}"))
+		})
+	})
+
+	Context("when the version is not supported", func() {
+		It("returns an error", func() {
+			_, err := config.ParseJobConfig("testdata/example-unsupported-version.yml")
+			Expect(err).To(MatchError("invalid config: version (line 2): unsupported version 99 (supported versions are 1 to 2)"))
+		})
+	})
+
+	Context("when the version is not a number", func() {
+		It("returns an error", func() {
+			path := writeTempConfig("version: two\nprocesses: []\n")
+			defer os.Remove(path)
+
+			_, err := config.ParseJobConfig(path)
+			Expect(err).To(MatchError(ContainSubstring("version (line 1): cannot unmarshal")))
+		})
+	})
+
+	Describe("MigrateJobConfig", func() {
+		It("rewrites the configuration in the latest version", func() {
+			migrated, err := config.MigrateJobConfig([]byte(`---
+# The example job.
+processes:
+- name: example
+  executable: /bin/example
+  workdir: /tmp # where it runs
+- workdir: /other
+  name: other
+  executable: /bin/other
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(migrated)).To(Equal(`---
+# The example job.
+version: 2
+processes:
+- name: example
+  executable: /bin/example
+  workdir: /tmp # where it runs
+- workdir: /other
+  name: other
+  executable: /bin/other
+`))
+		})
+
+		It("replaces an existing version", func() {
+			migrated, err := config.MigrateJobConfig([]byte("processes:\n- name: example\n  executable: /bin/example\nversion: 1\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(migrated)).To(Equal("processes:\n- name: example\n  executable: /bin/example\nversion: 2\n"))
+		})
+
+		It("escapes anything which would be read as a variable", func() {
+			migrated, err := config.MigrateJobConfig([]byte(`version: 1
+processes:
+# runs echo ${HOME}
+- name: example
+  executable: /bin/sh
+  args: [-c, "echo ${HOME} $${PRICE}"]
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(migrated)).To(Equal(`version: 2
+processes:
+# runs echo ${HOME}
+- name: example
+  executable: /bin/sh
+  args: [-c, "echo $${HOME} $$${PRICE}"]
+`))
+
+			path := writeTempConfig(string(migrated))
+			defer os.Remove(path)
+
+			cfg, err := config.ParseJobConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Interpolate(map[string]string{})).To(Succeed())
+			Expect(cfg.Processes[0].Args).To(Equal([]string{"-c", "echo ${HOME} $${PRICE}"}))
+		})
+
+		It("only escapes the values of fields which can refer to variables", func() {
+			migrated, err := config.MigrateJobConfig([]byte(`version: 1
+defaults:
+  env:
+    SHARED: ${SHARED}
+processes:
+- name: example
+  executable: /bin/sh
+  args:
+  - -c
+  - echo ${HOME} # prints ${HOME}
+  env:
+    ${KEY}: "${VALUE}"
+    SCRIPT: |
+      echo ${HOME}
+    LITERAL: plain
+  hooks:
+    pre_start: /bin/${HOOK}
+  secrets:
+    token: /var/vcap/jobs/${JOB}/token
+  additional_volumes:
+  - path: /var/vcap/data/${DIR}
+    writable: true
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(migrated)).To(Equal(`version: 2
+defaults:
+  env:
+    SHARED: $${SHARED}
+processes:
+- name: example
+  executable: /bin/sh
+  args:
+  - -c
+  - echo $${HOME} # prints ${HOME}
+  env:
+    ${KEY}: "$${VALUE}"
+    SCRIPT: |
+      echo $${HOME}
+    LITERAL: plain
+  hooks:
+    pre_start: /bin/${HOOK}
+  secrets:
+    token: /var/vcap/jobs/${JOB}/token
+  additional_volumes:
+  - path: /var/vcap/data/$${DIR}
+    writable: true
+`))
+
+			path := writeTempConfig(string(migrated))
+			defer os.Remove(path)
+
+			cfg, err := config.ParseJobConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Interpolate(map[string]string{})).To(Succeed())
+			Expect(cfg.Processes[0].Args).To(Equal([]string{"-c", "echo ${HOME}"}))
+			Expect(cfg.Processes[0].Env).To(Equal(map[string]string{
+				"${KEY}":  "${VALUE}",
+				"SCRIPT":  "echo ${HOME}\n",
+				"LITERAL": "plain",
+				"SHARED":  "${SHARED}",
+			}))
+			Expect(cfg.Processes[0].Hooks.PreStart).To(Equal("/bin/${HOOK}"))
+			Expect(cfg.Processes[0].Secrets).To(Equal(map[string]string{"token": "/var/vcap/jobs/${JOB}/token"}))
+			Expect(cfg.Processes[0].AdditionalVolumes[0].Path).To(Equal("/var/vcap/data/${DIR}"))
+		})
+
+		It("does not change configuration which is already the latest version", func() {
+			data, err := ioutil.ReadFile("testdata/example-v2.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			migrated, err := config.MigrateJobConfig(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migrated).To(Equal(data))
+		})
+
+		It("migrates to configuration with the same meaning", func() {
+			data, err := ioutil.ReadFile("testdata/example.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			migrated, err := config.MigrateJobConfig(data)
+			Expect(err).NotTo(HaveOccurred())
+
+			path := writeTempConfig(string(migrated))
+			defer os.Remove(path)
+
+			original, err := config.ParseJobConfig("testdata/example.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			cfg, err := config.ParseJobConfigStrict(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Version).To(Equal(config.LatestVersion))
+			Expect(cfg.Processes).To(Equal(original.Processes))
+		})
+
+		Context("when the configuration is invalid YAML", func() {
+			It("returns an error", func() {
+				_, err := config.MigrateJobConfig([]byte("{{"))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
+
+func writeTempConfig(contents string) string {
+	f, err := ioutil.TempFile("", "bpm-config")
+	Expect(err).NotTo(HaveOccurred())
+	defer f.Close()
+
+	_, err = f.WriteString(contents)
+	Expect(err).NotTo(HaveOccurred())
+
+	return f.Name()
+}
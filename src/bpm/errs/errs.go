@@ -0,0 +1,192 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package errs classifies the failures which bpm reports to its callers. Each
+// kind of failure has a distinct exit status so that scripts (e.g. monit
+// programs) can tell them apart without parsing error messages.
+//
+// Errors of a particular kind can be matched with errors.Is:
+//
+//	if errors.Is(err, errs.ConfigInvalid) { ... }
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind is a category of failure. It implements error so that it can be used
+// as the target of errors.Is.
+type Kind int
+
+const (
+	ConfigInvalid Kind = iota + 1
+	ProcessNotFound
+	AlreadyRunning
+	StopTimeout
+	RuntimeFailure
+	HookFailure
+	PermissionDenied
+	LockFailure
+	NotReady
+)
+
+// ExitStatus returns the documented exit status for the kind of failure.
+// These values are part of the public interface of bpm and must not change.
+func (k Kind) ExitStatus() int {
+	switch k {
+	case ConfigInvalid:
+		return 10
+	case ProcessNotFound:
+		return 11
+	case AlreadyRunning:
+		return 12
+	case StopTimeout:
+		return 13
+	case RuntimeFailure:
+		return 14
+	case HookFailure:
+		return 15
+	case PermissionDenied:
+		return 16
+	case LockFailure:
+		return 17
+	case NotReady:
+		return 18
+	default:
+		return 1
+	}
+}
+
+func (k Kind) Error() string {
+	switch k {
+	case ConfigInvalid:
+		return "invalid configuration"
+	case ProcessNotFound:
+		return "process not found"
+	case AlreadyRunning:
+		return "process already running"
+	case StopTimeout:
+		return "timed out stopping process"
+	case RuntimeFailure:
+		return "container runtime failure"
+	case HookFailure:
+		return "hook failure"
+	case PermissionDenied:
+		return "permission denied"
+	case LockFailure:
+		return "failed to acquire lock"
+	case NotReady:
+		return "dependency not ready"
+	default:
+		return "unknown error"
+	}
+}
+
+// Error is a failure of a particular kind. The message is taken entirely from
+// the underlying error.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// New creates an error of the given kind. The format string is passed to
+// fmt.Errorf and so may wrap another error with the %w verb.
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{
+		Kind: kind,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the target kind.
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(Kind)
+	return ok && kind == e.Kind
+}
+
+// ExitStatus returns the exit status which should be used when bpm exits
+// because of this error.
+func (e *Error) ExitStatus() int {
+	return e.Kind.ExitStatus()
+}
+
+// KindOf returns the kind of the first classified error in err's chain. If
+// there is none then fallback is returned.
+func KindOf(err error, fallback Kind) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return fallback
+}
+
+// Multiple is the combined failure of acting on several processes at once.
+type Multiple struct {
+	Errors []error
+}
+
+// Combine joins the failures of acting on several processes at once. It
+// returns nil if there are none.
+func Combine(failures []error) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &Multiple{Errors: failures}
+}
+
+func (m *Multiple) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		msgs[i] = fmt.Sprintf("  - %s", err)
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether every failure is of the target kind.
+func (m *Multiple) Is(target error) bool {
+	for _, err := range m.Errors {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+
+	return len(m.Errors) > 0
+}
+
+// ExitStatus returns the exit status of the failures if they are all of the
+// same kind. Otherwise it is the status used for any other failure.
+func (m *Multiple) ExitStatus() int {
+	kind := KindOf(m.Errors[0], 0)
+	for _, err := range m.Errors[1:] {
+		if KindOf(err, 0) != kind {
+			return Kind(0).ExitStatus()
+		}
+	}
+
+	return kind.ExitStatus()
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package errs_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/errs"
+)
+
+func TestErrs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Errs Suite")
+}
+
+var _ = Describe("errs", func() {
+	Describe("New", func() {
+		It("uses the formatted message", func() {
+			err := errs.New(errs.RuntimeFailure, "failed to start: %s", "disaster")
+			Expect(err.Error()).To(Equal("failed to start: disaster"))
+		})
+
+		It("can be matched by kind", func() {
+			err := errs.New(errs.HookFailure, "prestart hook failed")
+			Expect(errors.Is(err, errs.HookFailure)).To(BeTrue())
+			Expect(errors.Is(err, errs.RuntimeFailure)).To(BeFalse())
+		})
+
+		It("can be matched by kind when wrapped", func() {
+			err := fmt.Errorf("context: %w", errs.New(errs.StopTimeout, "too slow"))
+			Expect(errors.Is(err, errs.StopTimeout)).To(BeTrue())
+
+			var classified *errs.Error
+			Expect(errors.As(err, &classified)).To(BeTrue())
+			Expect(classified.Kind).To(Equal(errs.StopTimeout))
+		})
+
+		It("wraps errors passed with %w", func() {
+			cause := errors.New("cause")
+			err := errs.New(errs.ConfigInvalid, "bad config: %w", cause)
+			Expect(errors.Is(err, cause)).To(BeTrue())
+		})
+	})
+
+	Describe("ExitStatus", func() {
+		It("has a distinct exit status for each kind", func() {
+			kinds := []errs.Kind{
+				errs.ConfigInvalid,
+				errs.ProcessNotFound,
+				errs.AlreadyRunning,
+				errs.StopTimeout,
+				errs.RuntimeFailure,
+				errs.HookFailure,
+				errs.PermissionDenied,
+				errs.LockFailure,
+				errs.NotReady,
+			}
+
+			seen := map[int]bool{}
+			for _, kind := range kinds {
+				status := kind.ExitStatus()
+				Expect(status).To(BeNumerically(">", 1))
+				Expect(seen).NotTo(HaveKey(status))
+				seen[status] = true
+
+				Expect(errs.New(kind, "oops").(*errs.Error).ExitStatus()).To(Equal(status))
+			}
+		})
+	})
+
+	Describe("KindOf", func() {
+		It("returns the kind of the first classified error", func() {
+			err := fmt.Errorf("context: %w", errs.New(errs.HookFailure, "oops"))
+			Expect(errs.KindOf(err, errs.RuntimeFailure)).To(Equal(errs.HookFailure))
+		})
+
+		It("returns the fallback for unclassified errors", func() {
+			err := errors.New("oops")
+			Expect(errs.KindOf(err, errs.RuntimeFailure)).To(Equal(errs.RuntimeFailure))
+		})
+	})
+
+	Describe("Combine", func() {
+		It("returns nil when there are no failures", func() {
+			Expect(errs.Combine(nil)).To(BeNil())
+		})
+
+		It("keeps the kind of a single failure", func() {
+			err := errs.Combine([]error{errs.New(errs.HookFailure, "server: oops")})
+			Expect(err).To(MatchError("  - server: oops"))
+			Expect(errors.Is(err, errs.HookFailure)).To(BeTrue())
+			Expect(err.(*errs.Multiple).ExitStatus()).To(Equal(errs.HookFailure.ExitStatus()))
+		})
+
+		It("lists every failure", func() {
+			err := errs.Combine([]error{
+				errs.New(errs.StopTimeout, "server: too slow"),
+				errs.New(errs.StopTimeout, "worker: too slow"),
+			})
+			Expect(err).To(MatchError("  - server: too slow\n  - worker: too slow"))
+		})
+
+		Context("when the failures are all of the same kind", func() {
+			It("uses the exit status of that kind", func() {
+				err := fmt.Errorf("failed to stop 2 of 3 processes:\n%w", errs.Combine([]error{
+					errs.New(errs.StopTimeout, "server: too slow"),
+					fmt.Errorf("worker: %w", errs.New(errs.StopTimeout, "too slow")),
+				}))
+				Expect(errors.Is(err, errs.StopTimeout)).To(BeTrue())
+
+				var multiple *errs.Multiple
+				Expect(errors.As(err, &multiple)).To(BeTrue())
+				Expect(multiple.ExitStatus()).To(Equal(errs.StopTimeout.ExitStatus()))
+			})
+		})
+
+		Context("when the failures are of different kinds", func() {
+			It("uses the exit status for any other failure", func() {
+				err := errs.Combine([]error{
+					errs.New(errs.StopTimeout, "server: too slow"),
+					errs.New(errs.RuntimeFailure, "worker: runc failed"),
+				})
+				Expect(errors.Is(err, errs.StopTimeout)).To(BeFalse())
+				Expect(err.(*errs.Multiple).ExitStatus()).To(Equal(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,268 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package events records the lifecycle of the processes on a VM in an
+// append-only journal so that their history can be inspected later.
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"golang.org/x/sys/unix"
+)
+
+// FollowInterval is how often a followed journal is checked for new events.
+const FollowInterval = 250 * time.Millisecond
+
+type Type string
+
+const (
+	StartRequested Type = "start-requested"
+	Started        Type = "started"
+	StopRequested  Type = "stop-requested"
+	SignalSent     Type = "signal-sent"
+	Paused         Type = "paused"
+	Resumed        Type = "resumed"
+	Exited         Type = "exited"
+	Removed        Type = "removed"
+	Repaired       Type = "repaired"
+	HookRan        Type = "hook-ran"
+	LockWaited     Type = "lock-waited"
+)
+
+// Event is a single record in the journal. Only the fields which are
+// relevant to the type of the event are set.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    Type      `json:"type"`
+	Job     string    `json:"job"`
+	Process string    `json:"process"`
+	Pid     int       `json:"pid,omitempty"`
+
+	// Signal is the signal which was sent to the process or which it was
+	// killed by (e.g. TERM).
+	Signal     string `json:"signal,omitempty"`
+	ExitStatus *int   `json:"exit_status,omitempty"`
+	OOMKilled  bool   `json:"oom_killed,omitempty"`
+
+	Hook   string `json:"hook,omitempty"`
+	Waited string `json:"waited,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Filter selects events from the journal. Fields which are not set match
+// every event.
+type Filter struct {
+	Job     string
+	Process string
+	Since   time.Time
+}
+
+func (f Filter) Match(e Event) bool {
+	if f.Job != "" && e.Job != f.Job {
+		return false
+	}
+
+	if f.Process != "" && e.Process != f.Process {
+		return false
+	}
+
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
+
+// Journal is a file of events with one JSON object per line. Every bpm
+// command on a VM appends to the same journal.
+type Journal struct {
+	clock clock.Clock
+	path  string
+}
+
+func NewJournal(path string, clock clock.Clock) *Journal {
+	return &Journal{
+		clock: clock,
+		path:  path,
+	}
+}
+
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record appends an event to the journal. The time of the event is set if it
+// is missing. Recording to a nil journal does nothing so that the journal
+// can be left out (e.g. in tests).
+func (j *Journal) Record(e Event) error {
+	if j == nil {
+		return nil
+	}
+
+	if e.Time.IsZero() {
+		e.Time = j.clock.Now().UTC()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The lock stops the lines written by concurrent bpm commands from being
+	// interleaved.
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns the events in the journal which match the filter in the order
+// they were recorded. A journal which does not exist has no events.
+func (j *Journal) Read(filter Filter) ([]Event, error) {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		if e, ok := parse(s.Bytes()); ok && filter.Match(e) {
+			events = append(events, e)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Follow calls fn with every event in the journal which matches the filter
+// and then with each matching event as it is recorded until stop is closed.
+// A journal which is truncated (e.g. by log rotation) is read again from the
+// start.
+func (j *Journal) Follow(filter Filter, stop <-chan struct{}, fn func(Event)) error {
+	var (
+		offset  int64
+		partial []byte
+	)
+
+	for {
+		data, size, err := readFrom(j.path, offset)
+		if err != nil {
+			return err
+		}
+
+		if size < offset {
+			offset = 0
+			partial = nil
+			continue
+		}
+
+		offset += int64(len(data))
+		partial = append(partial, data...)
+
+		for {
+			i := bytes.IndexByte(partial, '\n')
+			if i < 0 {
+				break
+			}
+
+			if e, ok := parse(partial[:i]); ok && filter.Match(e) {
+				fn(e)
+			}
+			partial = partial[i+1:]
+		}
+
+		select {
+		case <-j.clock.After(FollowInterval):
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// readFrom reads a file from an offset to its end. It also returns the size
+// of the file so that the caller can tell if it has been truncated. A file
+// which does not exist is empty.
+func readFrom(path string, offset int64) ([]byte, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if info.Size() < offset {
+		return nil, info.Size(), nil
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data, info.Size(), nil
+}
+
+// parse decodes a line of the journal. Lines which cannot be decoded (e.g.
+// because a write was interrupted) are skipped.
+func parse(line []byte) (Event, bool) {
+	var e Event
+	if len(bytes.TrimSpace(line)) == 0 {
+		return e, false
+	}
+
+	if err := json.Unmarshal(line, &e); err != nil {
+		return e, false
+	}
+
+	return e, true
+}
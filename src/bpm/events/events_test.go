@@ -0,0 +1,207 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/events"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		tempDir   string
+		fakeClock *fakeclock.FakeClock
+		journal   *events.Journal
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "bpm-events")
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeClock = fakeclock.NewFakeClock(time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC))
+		journal = events.NewJournal(filepath.Join(tempDir, "bpm", "events.log"), fakeClock)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("appends events with the time they were recorded", func() {
+		Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "server", Pid: 42})).To(Succeed())
+		fakeClock.Increment(time.Minute)
+		Expect(journal.Record(events.Event{Type: events.SignalSent, Job: "web", Process: "server", Signal: "TERM"})).To(Succeed())
+
+		recorded, err := journal.Read(events.Filter{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded).To(Equal([]events.Event{
+			{Time: fakeClock.Now().Add(-time.Minute), Type: events.Started, Job: "web", Process: "server", Pid: 42},
+			{Time: fakeClock.Now(), Type: events.SignalSent, Job: "web", Process: "server", Signal: "TERM"},
+		}))
+	})
+
+	It("writes one JSON object per line", func() {
+		status := 0
+		Expect(journal.Record(events.Event{Type: events.Exited, Job: "web", Process: "server", ExitStatus: &status})).To(Succeed())
+
+		data, err := ioutil.ReadFile(journal.Path())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(`{"time":"2018-03-04T05:06:07Z","type":"exited","job":"web","process":"server","exit_status":0}` + "\n"))
+	})
+
+	It("does not interleave events recorded at the same time", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "server", Pid: i})).To(Succeed())
+			}(i)
+		}
+		wg.Wait()
+
+		recorded, err := journal.Read(events.Filter{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded).To(HaveLen(20))
+	})
+
+	It("does nothing when the journal is nil", func() {
+		var nilJournal *events.Journal
+		Expect(nilJournal.Record(events.Event{Type: events.Started})).To(Succeed())
+	})
+
+	Describe("Read", func() {
+		It("returns the events which match the filter", func() {
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "server"})).To(Succeed())
+			fakeClock.Increment(time.Hour)
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "worker"})).To(Succeed())
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "db", Process: "db"})).To(Succeed())
+
+			recorded, err := journal.Read(events.Filter{Job: "web"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(HaveLen(2))
+
+			recorded, err = journal.Read(events.Filter{Job: "web", Process: "worker"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(HaveLen(1))
+
+			recorded, err = journal.Read(events.Filter{Since: fakeClock.Now().Add(-time.Minute)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(HaveLen(2))
+			Expect(recorded[0].Process).To(Equal("worker"))
+		})
+
+		It("skips lines which cannot be parsed", func() {
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "server"})).To(Succeed())
+
+			f, err := os.OpenFile(journal.Path(), os.O_WRONLY|os.O_APPEND, 0600)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = f.WriteString("{\"time\":\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.Close()).To(Succeed())
+
+			Expect(journal.Record(events.Event{Type: events.Removed, Job: "web", Process: "server"})).To(Succeed())
+
+			recorded, err := journal.Read(events.Filter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(HaveLen(2))
+		})
+
+		It("returns no events when the journal does not exist", func() {
+			recorded, err := journal.Read(events.Filter{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(BeEmpty())
+		})
+	})
+
+	Describe("Follow", func() {
+		var (
+			stop     chan struct{}
+			done     chan struct{}
+			mu       sync.Mutex
+			followed []events.Event
+		)
+
+		followedTypes := func() []events.Type {
+			mu.Lock()
+			defer mu.Unlock()
+
+			var types []events.Type
+			for _, e := range followed {
+				types = append(types, e.Type)
+			}
+			return types
+		}
+
+		BeforeEach(func() {
+			stop = make(chan struct{})
+			done = make(chan struct{})
+			followed = nil
+		})
+
+		follow := func(filter events.Filter) {
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(journal.Follow(filter, stop, func(e events.Event) {
+					mu.Lock()
+					defer mu.Unlock()
+					followed = append(followed, e)
+				})).To(Succeed())
+			}()
+		}
+
+		AfterEach(func() {
+			close(stop)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("returns the existing events and then new events as they are recorded", func() {
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "server"})).To(Succeed())
+			follow(events.Filter{Job: "web"})
+			Eventually(followedTypes).Should(Equal([]events.Type{events.Started}))
+
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "db", Process: "db"})).To(Succeed())
+			Expect(journal.Record(events.Event{Type: events.StopRequested, Job: "web", Process: "server"})).To(Succeed())
+			fakeClock.WaitForWatcherAndIncrement(events.FollowInterval)
+
+			Eventually(followedTypes).Should(Equal([]events.Type{events.Started, events.StopRequested}))
+		})
+
+		It("reads a journal which has been truncated from the start", func() {
+			Expect(journal.Record(events.Event{Type: events.Started, Job: "web", Process: "server"})).To(Succeed())
+			follow(events.Filter{})
+			Eventually(followedTypes).Should(HaveLen(1))
+
+			Expect(os.Remove(journal.Path())).To(Succeed())
+			Expect(journal.Record(events.Event{Type: events.Exited, Job: "web", Process: "server"})).To(Succeed())
+			fakeClock.WaitForWatcherAndIncrement(events.FollowInterval)
+
+			Eventually(followedTypes).Should(Equal([]events.Type{events.Started, events.Exited}))
+		})
+	})
+})
@@ -0,0 +1,93 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package events
+
+import "time"
+
+// History is a summary of the events of a single process.
+type History struct {
+	Job     string
+	Process string
+
+	Starts    int
+	Stops     int
+	Crashes   int
+	OOMKills  int
+	LastStart time.Time
+	LastExit  time.Time
+}
+
+// Summarize returns the history of every process which appears in the
+// events in the order that they first appear. An unsuccessful exit which was
+// not preceded by a request to stop the process is counted as a crash. An
+// exit whose status is not known is assumed to be unsuccessful.
+//
+// The same exit can be recorded more than once (e.g. by bpm stop and by bpm
+// supervise) and so the exits of a pid are only counted once.
+func Summarize(events []Event) []*History {
+	var histories []*History
+	index := map[[2]string]*History{}
+	stopping := map[*History]bool{}
+	exits := map[*History]map[int]exit{}
+
+	for _, e := range events {
+		key := [2]string{e.Job, e.Process}
+		h, ok := index[key]
+		if !ok {
+			h = &History{Job: e.Job, Process: e.Process}
+			index[key] = h
+			histories = append(histories, h)
+			exits[h] = map[int]exit{}
+		}
+
+		switch e.Type {
+		case Started:
+			h.Starts++
+			h.LastStart = e.Time
+			stopping[h] = false
+		case StopRequested:
+			h.Stops++
+			stopping[h] = true
+		case Exited:
+			seen := exits[h][e.Pid]
+			if e.Pid == 0 || !seen.counted {
+				h.LastExit = e.Time
+				if !stopping[h] && failed(e) {
+					h.Crashes++
+				}
+				seen.counted = true
+			}
+
+			if e.OOMKilled && !seen.oomKilled {
+				h.OOMKills++
+				seen.oomKilled = e.Pid != 0
+			}
+			exits[h][e.Pid] = seen
+		}
+	}
+
+	return histories
+}
+
+// exit is what has been counted of the exit of a pid.
+type exit struct {
+	counted   bool
+	oomKilled bool
+}
+
+func failed(e Event) bool {
+	return e.ExitStatus == nil || *e.ExitStatus != 0 || e.Signal != ""
+}
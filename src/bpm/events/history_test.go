@@ -0,0 +1,90 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package events_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/events"
+)
+
+var _ = Describe("Summarize", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	event := func(t events.Type, process string, pid int) events.Event {
+		now = now.Add(time.Second)
+		return events.Event{Time: now, Type: t, Job: "web", Process: process, Pid: pid}
+	}
+
+	It("counts the starts, stops, and crashes of each process", func() {
+		histories := events.Summarize([]events.Event{
+			event(events.Started, "server", 1),
+			event(events.Started, "worker", 2),
+			event(events.Exited, "server", 1),
+			event(events.Started, "server", 3),
+			event(events.StopRequested, "server", 0),
+			event(events.Exited, "server", 3),
+		})
+
+		Expect(histories).To(HaveLen(2))
+		Expect(histories[0].Process).To(Equal("server"))
+		Expect(histories[0].Starts).To(Equal(2))
+		Expect(histories[0].Stops).To(Equal(1))
+		Expect(histories[0].Crashes).To(Equal(1))
+		Expect(histories[0].LastExit).To(Equal(now))
+
+		Expect(histories[1].Process).To(Equal("worker"))
+		Expect(histories[1].Starts).To(Equal(1))
+		Expect(histories[1].Crashes).To(Equal(0))
+		Expect(histories[1].LastExit).To(BeZero())
+	})
+
+	It("counts an exit which was recorded more than once only once", func() {
+		oomKilled := event(events.Exited, "server", 1)
+		oomKilled.Signal = "KILL"
+		oomKilled.OOMKilled = true
+
+		histories := events.Summarize([]events.Event{
+			event(events.Started, "server", 1),
+			event(events.Exited, "server", 1),
+			oomKilled,
+		})
+
+		Expect(histories).To(HaveLen(1))
+		Expect(histories[0].Crashes).To(Equal(1))
+		Expect(histories[0].OOMKills).To(Equal(1))
+	})
+
+	It("does not count a successful exit as a crash", func() {
+		status := 0
+		exited := event(events.Exited, "server", 1)
+		exited.ExitStatus = &status
+
+		histories := events.Summarize([]events.Event{
+			event(events.Started, "server", 1),
+			exited,
+		})
+
+		Expect(histories[0].Crashes).To(Equal(0))
+	})
+})
@@ -14,11 +14,14 @@
 // under the License.
 
 // Package exitstatus allows an exit status to be pushed through an error
-// shaped hole. It should not be used to propogate an exit status through a
-// deep callstack as a single error wrapping will cause this to break.
+// shaped hole. The exit status survives being wrapped with the %w verb of
+// fmt.Errorf.
 package exitstatus
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // Error represents an error and an associated exit status to propogate.
 type Error struct {
@@ -30,6 +33,22 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("%s (exit status %d)", e.Err, e.Status)
 }
 
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitStatus returns the exit status carried by the error.
+func (e *Error) ExitStatus() int {
+	return e.Status
+}
+
+// statusError is implemented by any error which knows the exit status it
+// should cause (e.g. the errors in the errs package).
+type statusError interface {
+	error
+	ExitStatus() int
+}
+
 // FromError collects the exit status from the passed error if it exists. If it
 // finds an error without status code information then it returns 1 for
 // backwards compatibility.
@@ -37,10 +56,11 @@ func FromError(err error) int {
 	if err == nil {
 		return 0
 	}
-	switch serr := err.(type) {
-	case *Error:
-		return serr.Status
-	default:
-		return 1
+
+	var serr statusError
+	if errors.As(err, &serr) {
+		return serr.ExitStatus()
 	}
+
+	return 1
 }
@@ -17,11 +17,13 @@ package exitstatus_test
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"bpm/errs"
 	"bpm/exitstatus"
 )
 
@@ -59,6 +61,22 @@ var _ = Describe("exit status", func() {
 			})
 		})
 
+		Context("with a wrapped exit status error", func() {
+			It("returns the exit status from the wrapped error", func() {
+				err := fmt.Errorf("context: %w", &exitstatus.Error{Status: 41, Err: errors.New("oops")})
+				status := exitstatus.FromError(err)
+				Expect(status).To(Equal(41))
+			})
+		})
+
+		Context("with a classified error", func() {
+			It("returns the exit status for the kind of error", func() {
+				err := errs.New(errs.ConfigInvalid, "bad config")
+				status := exitstatus.FromError(err)
+				Expect(status).To(Equal(10))
+			})
+		})
+
 		Context("with an other error", func() {
 			It("returns a generic 1", func() {
 				err := errors.New("other")
@@ -0,0 +1,382 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package gc finds the containers, bundles, pidfiles, and lock files on a VM
+// which no longer belong to a process and removes them. They are left behind
+// when a job is removed from the VM or when bpm is interrupted part way
+// through starting or stopping a process.
+package gc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/sys/unix"
+
+	"bpm/config"
+	"bpm/models"
+)
+
+//go:generate counterfeiter . Lifecycle
+
+type Lifecycle interface {
+	ListProcesses() ([]*models.Process, error)
+	StatProcess(cfg *config.BPMConfig) (*models.Process, error)
+	RemoveProcess(logger lager.Logger, cfg *config.BPMConfig) error
+}
+
+type Kind string
+
+const (
+	Container Kind = "container"
+	Bundle    Kind = "bundle"
+	PidFile   Kind = "pidfile"
+	LockFile  Kind = "lockfile"
+)
+
+// ErrInUse is returned when a stray cannot be removed because another bpm
+// command holds the lifecycle lock of its process.
+var ErrInUse = errors.New("the process is locked by another command")
+
+// Stray is something on the VM which no longer belongs to a process.
+type Stray struct {
+	Kind    Kind
+	Job     string
+	Process string
+
+	// Path is the file or directory of the stray. It is empty for
+	// containers.
+	Path   string
+	Reason string
+}
+
+// Result is the outcome of collecting a stray. Err is set if the stray could
+// not be removed.
+type Result struct {
+	Stray
+	Removed bool
+	Err     error
+}
+
+// Collector finds and removes the strays of the jobs on a VM.
+type Collector struct {
+	bosh      *config.Bosh
+	lifecycle Lifecycle
+}
+
+func NewCollector(bosh *config.Bosh, lifecycle Lifecycle) *Collector {
+	return &Collector{
+		bosh:      bosh,
+		lifecycle: lifecycle,
+	}
+}
+
+// Collect finds every stray on the VM and removes them unless dryRun is set.
+// A stray which cannot be removed does not stop the others from being
+// removed.
+func (c *Collector) Collect(logger lager.Logger, dryRun bool) ([]Result, error) {
+	strays, err := c.Find(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(strays))
+	for _, s := range strays {
+		if dryRun {
+			results = append(results, Result{Stray: s})
+			continue
+		}
+
+		err := c.Remove(logger, s)
+		if err != nil {
+			logger.Error("failed-to-remove", err, lager.Data{"kind": s.Kind, "job": s.Job, "process": s.Process})
+		}
+
+		results = append(results, Result{Stray: s, Removed: err == nil, Err: err})
+	}
+
+	return results, nil
+}
+
+// Find returns every stray on the VM. The containers are returned first so
+// that removing them (which also removes their bundles and pidfiles) happens
+// before the files are removed.
+func (c *Collector) Find(logger lager.Logger) ([]Stray, error) {
+	jobs := c.configuredJobs(logger)
+
+	processes, err := c.lifecycle.ListProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	var strays []Stray
+	containers := map[string]bool{}
+
+	for _, p := range processes {
+		name, err := config.Decode(p.Name)
+		if err != nil {
+			logger.Error("failed-to-decode-container-id", err, lager.Data{"id": p.Name})
+			continue
+		}
+
+		job, proc := splitContainerName(name)
+		containers[config.NewBPMConfig(c.bosh.Root(), job, proc).ContainerID()] = true
+
+		if reason := jobs.reason(job, proc); reason != "" {
+			strays = append(strays, Stray{Kind: Container, Job: job, Process: proc, Reason: reason})
+		}
+	}
+
+	bundles, err := c.findBundles(jobs, containers)
+	if err != nil {
+		return nil, err
+	}
+	strays = append(strays, bundles...)
+
+	files, err := c.findPidDirFiles(jobs, containers)
+	if err != nil {
+		return nil, err
+	}
+	strays = append(strays, files...)
+
+	return strays, nil
+}
+
+// findBundles returns the bundles which do not have a container. Bundles are
+// created before their containers are run and so a process is only missing
+// its container if its lifecycle lock is not held.
+func (c *Collector) findBundles(jobs configuredJobs, containers map[string]bool) ([]Stray, error) {
+	var strays []Stray
+
+	err := forEachEntry(config.BundlesRoot(c.bosh.Root()), func(job, proc, path string, isDir bool) {
+		cfg := config.NewBPMConfig(c.bosh.Root(), job, proc)
+		if !isDir || containers[cfg.ContainerID()] {
+			return
+		}
+
+		reason := jobs.reason(job, proc)
+		if reason == "" {
+			reason = "process has no container"
+		}
+
+		strays = append(strays, Stray{Kind: Bundle, Job: job, Process: proc, Path: path, Reason: reason})
+	})
+
+	return strays, err
+}
+
+// findPidDirFiles returns the pidfiles of processes which do not have a
+// container and the lock files which are not held.
+func (c *Collector) findPidDirFiles(jobs configuredJobs, containers map[string]bool) ([]Stray, error) {
+	var strays []Stray
+
+	err := forEachEntry(config.SupervisorDir(c.bosh.Root()), func(job, file, path string, isDir bool) {
+		if isDir {
+			return
+		}
+
+		switch filepath.Ext(file) {
+		case ".pid":
+			proc := strings.TrimSuffix(file, ".pid")
+			cfg := config.NewBPMConfig(c.bosh.Root(), job, proc)
+			if containers[cfg.ContainerID()] {
+				return
+			}
+
+			strays = append(strays, Stray{Kind: PidFile, Job: job, Process: proc, Path: path, Reason: "process has no container"})
+		case ".lock":
+			proc := strings.TrimSuffix(file, ".lock")
+			if held(path) {
+				return
+			}
+
+			strays = append(strays, Stray{Kind: LockFile, Job: job, Process: proc, Path: path, Reason: "lock is not held"})
+		}
+	})
+
+	return strays, err
+}
+
+// Remove removes a stray. A container is killed and removed along with its
+// bundle, secrets, and pidfile. The lifecycle lock of the process is taken
+// first so that nothing is removed while another command is using it.
+func (c *Collector) Remove(logger lager.Logger, s Stray) error {
+	cfg := config.NewBPMConfig(c.bosh.Root(), s.Job, s.Process)
+	logger = logger.Session("remove", lager.Data{"kind": s.Kind, "job": s.Job, "process": s.Process})
+
+	unlock, err := tryLock(cfg)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	switch s.Kind {
+	case Container:
+		err := c.lifecycle.RemoveProcess(logger, cfg)
+		if os.IsNotExist(err) {
+			// The pidfile is removed last and is often already gone.
+			err = nil
+		}
+		return err
+	case LockFile:
+		// The lock file is removed when the lock is released.
+		return nil
+	}
+
+	// The container may have been started since the strays were found.
+	if _, err := c.lifecycle.StatProcess(cfg); err == nil {
+		return ErrInUse
+	}
+
+	if err := os.RemoveAll(s.Path); err != nil {
+		return err
+	}
+
+	// The directory of a job which has been removed is left empty.
+	os.Remove(filepath.Dir(s.Path))
+	return nil
+}
+
+// configuredJobs is the processes which are defined by each job on the VM.
+// The processes of a job whose configuration cannot be read are not known.
+type configuredJobs map[string]map[string]bool
+
+func (c *Collector) configuredJobs(logger lager.Logger) configuredJobs {
+	jobs := configuredJobs{}
+
+	for _, job := range c.bosh.JobNames() {
+		jobCfg, err := config.NewBPMConfig(c.bosh.Root(), job, "").ParseJobConfig()
+		if os.IsNotExist(err) {
+			jobs[job] = map[string]bool{}
+			continue
+		}
+
+		if err != nil {
+			logger.Error("invalid-config", err, lager.Data{"job": job})
+			jobs[job] = nil
+			continue
+		}
+
+		jobs[job] = map[string]bool{}
+		for _, procCfg := range jobCfg.Processes {
+			jobs[job][procCfg.Name] = true
+		}
+	}
+
+	return jobs
+}
+
+// reason returns why a process does not belong on the VM or an empty string
+// if it does.
+func (jobs configuredJobs) reason(job, proc string) string {
+	procs, ok := jobs[job]
+	switch {
+	case !ok:
+		return "job is not present"
+	case procs == nil:
+		// Nothing of a job with invalid configuration is removed.
+		return ""
+	case !procs[proc]:
+		return "process is not defined by the job"
+	default:
+		return ""
+	}
+}
+
+// splitContainerName splits the decoded ID of a container into the name of
+// its job and process (see config.BPMConfig.ContainerID).
+func splitContainerName(name string) (string, string) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) == 1 {
+		return name, name
+	}
+
+	return parts[0], parts[1]
+}
+
+// forEachEntry calls fn with each entry in the job directories of a
+// directory. A directory which does not exist has no entries.
+func forEachEntry(root string, fn func(job, name, path string, isDir bool)) error {
+	jobDirs, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, jobDir := range jobDirs {
+		if !jobDir.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(root, jobDir.Name())
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			fn(jobDir.Name(), entry.Name(), filepath.Join(dir, entry.Name()), entry.IsDir())
+		}
+	}
+
+	return nil
+}
+
+// held reports whether another command holds a lock file.
+func held(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		return true
+	}
+
+	unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	return false
+}
+
+// tryLock takes the lifecycle lock of a process without waiting. It returns
+// ErrInUse if another command holds it. The lock file is only created if the
+// directory for it exists so that nothing is left behind for jobs which have
+// been removed.
+func tryLock(cfg *config.BPMConfig) (func(), error) {
+	f, err := os.OpenFile(cfg.LockFile(), os.O_CREATE|os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		f.Close()
+		return nil, ErrInUse
+	}
+
+	return func() {
+		os.Remove(cfg.LockFile())
+		os.Remove(filepath.Dir(cfg.LockFile()))
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,285 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gc_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/config"
+	"bpm/gc"
+	"bpm/gc/gcfakes"
+	"bpm/models"
+)
+
+var _ = Describe("Collector", func() {
+	var (
+		boshRoot      string
+		bosh          *config.Bosh
+		fakeLifecycle *gcfakes.FakeLifecycle
+		logger        *lagertest.TestLogger
+		collector     *gc.Collector
+	)
+
+	writeFile := func(path, contents string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	addJob := func(job string, procs ...string) {
+		contents := "processes:\n"
+		for _, proc := range procs {
+			contents += "- name: " + proc + "\n  executable: /bin/sleep\n"
+		}
+		writeFile(config.NewBPMConfig(boshRoot, job, "").JobConfig(), contents)
+	}
+
+	container := func(job, proc string) *models.Process {
+		return &models.Process{
+			Name:   config.NewBPMConfig(boshRoot, job, proc).ContainerID(),
+			Pid:    42,
+			Status: models.ProcessStateRunning,
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		boshRoot, err = ioutil.TempDir("", "gc")
+		Expect(err).NotTo(HaveOccurred())
+
+		bosh = config.NewBosh(boshRoot)
+		fakeLifecycle = &gcfakes.FakeLifecycle{}
+		fakeLifecycle.StatProcessReturns(nil, errors.New("container does not exist"))
+		logger = lagertest.NewTestLogger("gc")
+		collector = gc.NewCollector(bosh, fakeLifecycle)
+
+		addJob("web", "server", "worker")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(boshRoot)).To(Succeed())
+	})
+
+	Describe("Find", func() {
+		It("finds nothing when every container belongs to a job", func() {
+			fakeLifecycle.ListProcessesReturns([]*models.Process{
+				container("web", "server"),
+				container("web", "worker"),
+			}, nil)
+
+			cfg := config.NewBPMConfig(boshRoot, "web", "server")
+			writeFile(cfg.PidFile(), "42")
+			Expect(os.MkdirAll(cfg.BundlePath(), 0700)).To(Succeed())
+
+			strays, err := collector.Find(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strays).To(BeEmpty())
+		})
+
+		It("finds the containers of jobs and processes which are not present", func() {
+			fakeLifecycle.ListProcessesReturns([]*models.Process{
+				container("web", "server"),
+				container("web", "cron"),
+				container("db", "db"),
+			}, nil)
+
+			strays, err := collector.Find(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strays).To(ConsistOf(
+				gc.Stray{Kind: gc.Container, Job: "web", Process: "cron", Reason: "process is not defined by the job"},
+				gc.Stray{Kind: gc.Container, Job: "db", Process: "db", Reason: "job is not present"},
+			))
+		})
+
+		It("finds the bundles and pidfiles of processes without a container", func() {
+			fakeLifecycle.ListProcessesReturns([]*models.Process{container("web", "server")}, nil)
+
+			server := config.NewBPMConfig(boshRoot, "web", "server")
+			worker := config.NewBPMConfig(boshRoot, "web", "worker")
+			db := config.NewBPMConfig(boshRoot, "db", "db")
+
+			for _, cfg := range []*config.BPMConfig{server, worker, db} {
+				writeFile(cfg.PidFile(), "42")
+				Expect(os.MkdirAll(cfg.BundlePath(), 0700)).To(Succeed())
+			}
+
+			strays, err := collector.Find(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strays).To(ConsistOf(
+				gc.Stray{Kind: gc.Bundle, Job: "web", Process: "worker", Path: worker.BundlePath(), Reason: "process has no container"},
+				gc.Stray{Kind: gc.Bundle, Job: "db", Process: "db", Path: db.BundlePath(), Reason: "job is not present"},
+				gc.Stray{Kind: gc.PidFile, Job: "web", Process: "worker", Path: worker.PidFile(), Reason: "process has no container"},
+				gc.Stray{Kind: gc.PidFile, Job: "db", Process: "db", Path: db.PidFile(), Reason: "process has no container"},
+			))
+		})
+
+		It("finds the lock files which are not held", func() {
+			free := config.NewBPMConfig(boshRoot, "web", "server")
+			held := config.NewBPMConfig(boshRoot, "web", "worker")
+			writeFile(free.LockFile(), "")
+			writeFile(held.LockFile(), "")
+
+			f, err := os.Open(held.LockFile())
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+			Expect(unix.Flock(int(f.Fd()), unix.LOCK_EX)).To(Succeed())
+
+			strays, err := collector.Find(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strays).To(ConsistOf(
+				gc.Stray{Kind: gc.LockFile, Job: "web", Process: "server", Path: free.LockFile(), Reason: "lock is not held"},
+			))
+		})
+
+		It("does not touch the processes of a job with invalid configuration", func() {
+			writeFile(config.NewBPMConfig(boshRoot, "broken", "").JobConfig(), "processes: {")
+			fakeLifecycle.ListProcessesReturns([]*models.Process{container("broken", "server")}, nil)
+
+			cfg := config.NewBPMConfig(boshRoot, "broken", "worker")
+			Expect(os.MkdirAll(cfg.BundlePath(), 0700)).To(Succeed())
+
+			strays, err := collector.Find(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strays).To(ConsistOf(
+				gc.Stray{Kind: gc.Bundle, Job: "broken", Process: "worker", Path: cfg.BundlePath(), Reason: "process has no container"},
+			))
+		})
+
+		Context("when the containers cannot be listed", func() {
+			It("returns an error", func() {
+				fakeLifecycle.ListProcessesReturns(nil, errors.New("boom"))
+
+				_, err := collector.Find(logger)
+				Expect(err).To(MatchError("boom"))
+			})
+		})
+	})
+
+	Describe("Collect", func() {
+		var old *config.BPMConfig
+
+		BeforeEach(func() {
+			old = config.NewBPMConfig(boshRoot, "old", "old")
+			Expect(os.MkdirAll(old.BundlePath(), 0700)).To(Succeed())
+			fakeLifecycle.ListProcessesReturns([]*models.Process{container("db", "db")}, nil)
+		})
+
+		It("removes every stray", func() {
+			fakeLifecycle.RemoveProcessReturns(errors.New("boom"))
+
+			results, err := collector.Collect(logger, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+
+			Expect(results[0].Kind).To(Equal(gc.Container))
+			Expect(results[0].Removed).To(BeFalse())
+			Expect(results[0].Err).To(MatchError("boom"))
+
+			Expect(results[1].Kind).To(Equal(gc.Bundle))
+			Expect(results[1].Removed).To(BeTrue())
+			Expect(results[1].Err).NotTo(HaveOccurred())
+			Expect(old.BundlePath()).NotTo(BeADirectory())
+		})
+
+		Context("when it is a dry run", func() {
+			It("does not remove anything", func() {
+				results, err := collector.Collect(logger, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+
+				for _, r := range results {
+					Expect(r.Removed).To(BeFalse())
+					Expect(r.Err).NotTo(HaveOccurred())
+				}
+
+				Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(0))
+				Expect(old.BundlePath()).To(BeADirectory())
+			})
+		})
+	})
+
+	Describe("Remove", func() {
+		It("removes a container", func() {
+			stray := gc.Stray{Kind: gc.Container, Job: "db", Process: "db"}
+			Expect(collector.Remove(logger, stray)).To(Succeed())
+
+			Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(1))
+			_, cfg := fakeLifecycle.RemoveProcessArgsForCall(0)
+			Expect(cfg.JobName()).To(Equal("db"))
+			Expect(cfg.ProcName()).To(Equal("db"))
+		})
+
+		It("removes a bundle and the directory of its job once it is empty", func() {
+			cfg := config.NewBPMConfig(boshRoot, "db", "db")
+			Expect(os.MkdirAll(cfg.BundlePath(), 0700)).To(Succeed())
+
+			stray := gc.Stray{Kind: gc.Bundle, Job: "db", Process: "db", Path: cfg.BundlePath()}
+			Expect(collector.Remove(logger, stray)).To(Succeed())
+
+			Expect(filepath.Dir(cfg.BundlePath())).NotTo(BeADirectory())
+			Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(0))
+		})
+
+		It("removes a lock file", func() {
+			cfg := config.NewBPMConfig(boshRoot, "web", "server")
+			writeFile(cfg.LockFile(), "")
+
+			stray := gc.Stray{Kind: gc.LockFile, Job: "web", Process: "server", Path: cfg.LockFile()}
+			Expect(collector.Remove(logger, stray)).To(Succeed())
+
+			Expect(cfg.LockFile()).NotTo(BeAnExistingFile())
+		})
+
+		Context("when the container has been started since the stray was found", func() {
+			It("does not remove the pidfile", func() {
+				fakeLifecycle.StatProcessReturns(container("web", "worker"), nil)
+
+				cfg := config.NewBPMConfig(boshRoot, "web", "worker")
+				writeFile(cfg.PidFile(), "42")
+
+				stray := gc.Stray{Kind: gc.PidFile, Job: "web", Process: "worker", Path: cfg.PidFile()}
+				Expect(collector.Remove(logger, stray)).To(Equal(gc.ErrInUse))
+
+				Expect(cfg.PidFile()).To(BeAnExistingFile())
+			})
+		})
+
+		Context("when another command holds the lock of the process", func() {
+			It("does not remove anything", func() {
+				cfg := config.NewBPMConfig(boshRoot, "web", "worker")
+				writeFile(cfg.LockFile(), "")
+
+				f, err := os.Open(cfg.LockFile())
+				Expect(err).NotTo(HaveOccurred())
+				defer f.Close()
+				Expect(unix.Flock(int(f.Fd()), unix.LOCK_EX)).To(Succeed())
+
+				stray := gc.Stray{Kind: gc.Container, Job: "web", Process: "worker"}
+				Expect(collector.Remove(logger, stray)).To(Equal(gc.ErrInUse))
+
+				Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,147 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("render", func() {
+	var (
+		boshRoot   string
+		configPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		boshRoot, err = ioutil.TempDir(bpmTmpDir, "render-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		configDir := filepath.Join(boshRoot, "jobs", "example", "config")
+		Expect(os.MkdirAll(configDir, 0755)).To(Succeed())
+		configPath = filepath.Join(configDir, "bpm.yml")
+
+		Expect(ioutil.WriteFile(configPath, []byte(`---
+processes:
+- name: example
+  executable: /var/vcap/packages/example/bin/example
+  env:
+    FOO: bar
+  capabilities:
+  - NET_BIND_SERVICE
+  limits:
+    open_files: 100
+  additional_volumes:
+  - path: /var/vcap/data/example-shared
+`), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(boshRoot)).To(Succeed())
+	})
+
+	renderCommand := func(args ...string) *exec.Cmd {
+		args = append([]string{"render", "example", "--bosh-root", boshRoot}, args...)
+		return exec.Command(bpmPath, args...)
+	}
+
+	It("prints the spec the process would be started with", func() {
+		session, err := gexec.Start(renderCommand("-e", "EXTRA=value"), GinkgoWriter, GinkgoWriter)
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		var spec specs.Spec
+		Expect(json.Unmarshal(session.Out.Contents(), &spec)).To(Succeed())
+
+		Expect(spec.Process.Args).To(Equal([]string{"/var/vcap/packages/example/bin/example"}))
+		Expect(spec.Process.Env).To(ContainElement("FOO=bar"))
+		Expect(spec.Process.Env).To(ContainElement("EXTRA=value"))
+		Expect(spec.Process.Capabilities.Bounding).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+		Expect(spec.Process.Rlimits).To(ConsistOf(specs.POSIXRlimit{Type: "RLIMIT_NOFILE", Hard: 100, Soft: 100}))
+		Expect(spec.Root.Path).To(Equal(filepath.Join(boshRoot, "data", "bpm", "bundles", "example", "example", "rootfs")))
+
+		var destinations []string
+		for _, m := range spec.Mounts {
+			destinations = append(destinations, m.Destination)
+		}
+		Expect(destinations).To(ContainElement("/var/vcap/data/example-shared"))
+		Expect(destinations).To(ContainElement(filepath.Join(boshRoot, "jobs", "example")))
+	})
+
+	It("prints the same spec every time", func() {
+		first, err := gexec.Start(renderCommand(), GinkgoWriter, GinkgoWriter)
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(first).Should(gexec.Exit(0))
+
+		second, err := gexec.Start(renderCommand(), GinkgoWriter, GinkgoWriter)
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(second).Should(gexec.Exit(0))
+
+		Expect(second.Out.Contents()).To(Equal(first.Out.Contents()))
+	})
+
+	Context("when the process does not exist", func() {
+		It("fails with a process not found exit status", func() {
+			session, err := gexec.Start(renderCommand("-p", "missing"), GinkgoWriter, GinkgoWriter)
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(11))
+		})
+	})
+
+	Context("when comparing with another configuration", func() {
+		var otherPath string
+
+		BeforeEach(func() {
+			otherPath = filepath.Join(boshRoot, "other.yml")
+			Expect(ioutil.WriteFile(otherPath, []byte(`---
+processes:
+- name: example
+  executable: /var/vcap/packages/example/bin/example
+  env:
+    FOO: baz
+  capabilities:
+  - NET_BIND_SERVICE
+  limits:
+    open_files: 100
+  additional_volumes:
+  - path: /var/vcap/data/example-shared
+`), 0644)).To(Succeed())
+		})
+
+		It("prints the differences between the rendered specs", func() {
+			session, err := gexec.Start(renderCommand("--diff", otherPath), GinkgoWriter, GinkgoWriter)
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`--- .*bpm.yml`))
+			Expect(session.Out).To(gbytes.Say(`\+\+\+ .*other.yml`))
+			Expect(session.Out).To(gbytes.Say(`-\s+"FOO=bar",`))
+			Expect(session.Out).To(gbytes.Say(`\+\s+"FOO=baz",`))
+			Expect(session.Out).NotTo(gbytes.Say("CAP_NET_BIND_SERVICE"))
+		})
+	})
+})
@@ -261,18 +261,49 @@ var _ = Describe("start", func() {
 			existingPid = state.Pid
 		})
 
-		It("should not restart the container and logs", func() {
+		It("should not restart the container and exits with the already running status", func() {
 			command = exec.Command(bpmPath, "start", job)
 			command.Env = append(command.Env, fmt.Sprintf("BPM_BOSH_ROOT=%s", boshRoot))
 
 			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 			Expect(err).ShouldNot(HaveOccurred())
-			Eventually(session).Should(gexec.Exit(0))
+			Eventually(session).Should(gexec.Exit(12))
+			Expect(session.Err).To(gbytes.Say("process is already running"))
 			Expect(fileContents(bpmLog)()).To(ContainSubstring("process-already-running"))
 
 			state := runcState(runcRoot, containerID)
 			Expect(state.Pid).To(Equal(existingPid))
 		})
+
+		Context("when restarting if the configuration changed", func() {
+			restartIfChanged := func() {
+				command = exec.Command(bpmPath, "start", job, "--restart-if-changed")
+				command.Env = append(command.Env, fmt.Sprintf("BPM_BOSH_ROOT=%s", boshRoot))
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).ShouldNot(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+			}
+
+			It("does not restart the container if the configuration is unchanged", func() {
+				restartIfChanged()
+
+				state := runcState(runcRoot, containerID)
+				Expect(state.Pid).To(Equal(existingPid))
+			})
+
+			It("restarts the container if the configuration has changed", func() {
+				cfg.Processes[0].Env = map[string]string{"CHANGED": "true"}
+				writeConfig(boshRoot, job, cfg)
+
+				restartIfChanged()
+
+				state := runcState(runcRoot, containerID)
+				Expect(state.Status).To(Equal("running"))
+				Expect(state.Pid).NotTo(Equal(existingPid))
+				Expect(fileContents(bpmLog)()).To(ContainSubstring("stopping-changed-process"))
+			})
+		})
 	})
 
 	Context("when a stopped container exists with the same name", func() {
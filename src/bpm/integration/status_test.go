@@ -0,0 +1,127 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package integration_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	uuid "github.com/satori/go.uuid"
+
+	"bpm/config"
+)
+
+var _ = Describe("status and diff", func() {
+	var (
+		cfg config.JobConfig
+
+		boshRoot    string
+		containerID string
+		job         string
+		runcRoot    string
+	)
+
+	bpmCommand := func(args ...string) *exec.Cmd {
+		command := exec.Command(bpmPath, args...)
+		command.Env = append(command.Env, fmt.Sprintf("BPM_BOSH_ROOT=%s", boshRoot))
+		return command
+	}
+
+	runBPM := func(exitCode int, args ...string) *gexec.Session {
+		session, err := gexec.Start(bpmCommand(args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).ShouldNot(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(exitCode))
+		return session
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		job = uuid.NewV4().String()
+		containerID = config.Encode(job)
+		boshRoot, err = ioutil.TempDir(bpmTmpDir, "status-test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chmod(boshRoot, 0755)).To(Succeed())
+		runcRoot = setupBoshDirectories(boshRoot, job)
+
+		cfg = newJobConfig(job, defaultBash(filepath.Join(boshRoot, "sys", "log", job, "foo.log")))
+		writeConfig(boshRoot, job, cfg)
+	})
+
+	AfterEach(func() {
+		err := runcCommand(runcRoot, "delete", "--force", containerID).Run()
+		if err != nil {
+			fmt.Fprintf(GinkgoWriter, "WARNING: Failed to cleanup container: %s\n", err.Error())
+		}
+		Expect(os.RemoveAll(boshRoot)).To(Succeed())
+	})
+
+	Context("when the process has not been started", func() {
+		It("shows that it is stopped", func() {
+			session := runBPM(0, "status", job)
+			Expect(session.Out).To(gbytes.Say(`Name\s+Pid\s+Status\s+Config`))
+			Expect(session.Out).To(gbytes.Say(`%s\s+-\s+stopped\s+-`, job))
+		})
+
+		It("cannot show a diff", func() {
+			runBPM(11, "diff", job)
+		})
+	})
+
+	Context("when the process is running", func() {
+		BeforeEach(func() {
+			startJob(boshRoot, bpmPath, job)
+		})
+
+		Context("and the configuration has not changed", func() {
+			It("shows that the configuration is unchanged", func() {
+				session := runBPM(0, "status", job)
+				Expect(session.Out).To(gbytes.Say(`%s\s+\d+\s+running\s+unchanged`, job))
+			})
+
+			It("shows no differences", func() {
+				session := runBPM(0, "diff", job)
+				Expect(session.Out.Contents()).To(BeEmpty())
+			})
+		})
+
+		Context("and the configuration has changed", func() {
+			BeforeEach(func() {
+				cfg.Processes[0].Env = map[string]string{"CHANGED": "true"}
+				writeConfig(boshRoot, job, cfg)
+			})
+
+			It("shows that the configuration has changed", func() {
+				session := runBPM(0, "status", job)
+				Expect(session.Out).To(gbytes.Say(`%s\s+\d+\s+running\s+changed`, job))
+			})
+
+			It("shows the differences between the specs", func() {
+				session := runBPM(0, "diff", job)
+				Expect(session.Out).To(gbytes.Say(`--- running`))
+				Expect(session.Out).To(gbytes.Say(`\+\+\+ configured`))
+				Expect(session.Out).To(gbytes.Say(`\+\s+"CHANGED=true",`))
+			})
+		})
+	})
+})
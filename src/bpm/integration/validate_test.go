@@ -0,0 +1,123 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package integration_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("validate", func() {
+	var (
+		configPath string
+		tempDir    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir(bpmTmpDir, "validate-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		configDir := filepath.Join(tempDir, "jobs", "example", "config")
+		Expect(os.MkdirAll(configDir, 0755)).To(Succeed())
+		configPath = filepath.Join(configDir, "bpm.yml")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	writeFile := func(contents string) {
+		Expect(ioutil.WriteFile(configPath, []byte(contents), 0644)).To(Succeed())
+	}
+
+	Context("when the configuration is valid", func() {
+		BeforeEach(func() {
+			writeFile(`---
+processes:
+- name: example
+  executable: /var/vcap/packages/example/bin/example
+  additional_volumes:
+  - path: /var/vcap/data/example-shared
+`)
+		})
+
+		It("succeeds", func() {
+			session, err := gexec.Start(exec.Command(bpmPath, "validate", configPath), GinkgoWriter, GinkgoWriter)
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("valid configuration for job example"))
+		})
+	})
+
+	Context("when the configuration is invalid", func() {
+		BeforeEach(func() {
+			writeFile(`---
+processes:
+- name: example
+  capabilities:
+  - CAP_MAKE_COFFEE
+  additional_volumes:
+  - path: /var/vcap/data/example
+`)
+		})
+
+		It("reports every problem with a config invalid exit status", func() {
+			session, err := gexec.Start(exec.Command(bpmPath, "validate", configPath), GinkgoWriter, GinkgoWriter)
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(10))
+			Expect(session.Err).To(gbytes.Say(`processes\[0\].executable \(line 3\): is required`))
+			Expect(session.Err).To(gbytes.Say(`processes\[0\].capabilities\[0\] \(line 5\): invalid capability name: CAP_MAKE_COFFEE`))
+			Expect(session.Err).To(gbytes.Say(`processes\[0\].additional_volumes\[0\].path \(line 7\): invalid volume path`))
+		})
+
+		Context("when the job name is given", func() {
+			It("uses it to find the default volumes", func() {
+				command := exec.Command(bpmPath, "validate", configPath, "--job", "other", "--bosh-root", "/var/vcap")
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).ShouldNot(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(10))
+				Expect(session.Err).NotTo(gbytes.Say("additional_volumes"))
+			})
+		})
+	})
+
+	Context("when the configuration contains unknown fields", func() {
+		BeforeEach(func() {
+			writeFile(`---
+processes:
+- name: example
+  executable: /bin/example
+  enviroment:
+    FOO: bar
+`)
+		})
+
+		It("reports them", func() {
+			session, err := gexec.Start(exec.Command(bpmPath, "validate", configPath), GinkgoWriter, GinkgoWriter)
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(10))
+			Expect(session.Err).To(gbytes.Say(`processes\[0\].enviroment \(line 5\): unknown field "enviroment"`))
+		})
+	})
+})
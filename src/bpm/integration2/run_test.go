@@ -108,6 +108,40 @@ func TestRunWithEnvFlags(t *testing.T) {
 	}
 }
 
+func TestRunWithInvalidEnvFile(t *testing.T) {
+	t.Parallel()
+	s := NewSandbox(t)
+	defer s.Cleanup()
+
+	s.LoadFixture("errand", "testdata/env-flag.yml")
+	sentinel := "s3cr3t-token"
+
+	envFile := filepath.Join(s.root, "errand.env")
+	if err := ioutil.WriteFile(envFile, []byte(fmt.Sprintf("ENVKEY=value\n%s\n", sentinel)), 0600); err != nil {
+		t.Fatalf("failed to write environment file: %v", err)
+	}
+
+	cmd := s.BPMCmd("run", "errand", "--env-file", envFile)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatal("expected command to fail but it did not")
+	}
+	if contents, want := string(output), "line 2"; !strings.Contains(contents, want) {
+		t.Errorf("output did not contain %q; contents: %q", want, contents)
+	}
+	if contents := string(output); strings.Contains(contents, sentinel) {
+		t.Errorf("output contained the contents of the environment file: %q", contents)
+	}
+
+	log, err := ioutil.ReadFile(s.Path("sys", "log", "errand", "bpm.log"))
+	if err != nil {
+		t.Fatalf("failed to read bpm log: %v", err)
+	}
+	if contents := string(log); strings.Contains(contents, sentinel) {
+		t.Errorf("bpm log contained the contents of the environment file: %q", contents)
+	}
+}
+
 func TestRunWithVolumeFlags(t *testing.T) {
 	t.Parallel()
 	s := NewSandbox(t)
@@ -201,6 +235,31 @@ func TestRunUnusualExitStatus(t *testing.T) {
 	}
 }
 
+func TestRunAlreadyRunning(t *testing.T) {
+	t.Parallel()
+	s := NewSandbox(t)
+	defer s.Cleanup()
+
+	s.LoadFixture("sleeper", "testdata/sleeper.yml")
+
+	if output, err := s.BPMCmd("start", "sleeper").CombinedOutput(); err != nil {
+		t.Fatalf("failed to start bpm: %s", output)
+	}
+	defer s.BPMCmd("stop", "sleeper").Run()
+
+	for _, command := range []string{"run", "start"} {
+		cmd := s.BPMCmd(command, "sleeper")
+		if err := cmd.Run(); err == nil {
+			t.Fatalf("expected %s to fail but it did not", command)
+		}
+
+		status := cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+		if status != 12 {
+			t.Errorf("expected %s to exit with status %d; got: %d", command, 12, status)
+		}
+	}
+}
+
 type Sandbox struct {
 	t *testing.T
 
@@ -17,10 +17,20 @@ package models
 
 const (
 	ProcessStateFailed  = "failed"
+	ProcessStatePaused  = "paused"
 	ProcessStateRunning = "running"
 	ProcessStateStopped = "stopped"
 )
 
+// The state of the configuration of a process compared to the configuration
+// it was started with.
+const (
+	ConfigStateChanged   = "changed"
+	ConfigStateInvalid   = "invalid"
+	ConfigStateUnchanged = "unchanged"
+	ConfigStateUnknown   = "-"
+)
+
 type Process struct {
 	Name   string
 	Pid    int
@@ -21,8 +21,11 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"bpm/config"
+	"bpm/events"
+	"bpm/gc"
 	"bpm/models"
 )
 
@@ -47,6 +50,133 @@ func PrintJobs(processes []*models.Process, stdout io.Writer) error {
 	return tw.Flush()
 }
 
+// PrintStatus prints the state of a single process along with the state of
+// its configuration (see models.ConfigStateChanged).
+func PrintStatus(process *models.Process, configState string, stdout io.Writer) error {
+	tw := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+
+	name, err := config.Decode(process.Name)
+	if err != nil {
+		return err
+	}
+
+	pid := "-"
+	if process.Pid > 0 {
+		pid = strconv.Itoa(process.Pid)
+	}
+
+	printRow(tw, "Name", "Pid", "Status", "Config")
+	printRow(tw, name, pid, process.Status, configState)
+
+	return tw.Flush()
+}
+
+// PrintEvents prints the events from the journal with the details which are
+// relevant to the type of each event.
+func PrintEvents(recorded []events.Event, stdout io.Writer) error {
+	tw := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+
+	printRow(tw, "Time", "Process", "Event", "Details")
+	for _, e := range recorded {
+		printRow(tw, e.Time.Local().Format(time.RFC3339), e.Process, string(e.Type), eventDetails(e))
+	}
+
+	return tw.Flush()
+}
+
+// PrintHistory prints a summary of the events of each process.
+func PrintHistory(histories []*events.History, stdout io.Writer) error {
+	tw := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+
+	printRow(tw, "Process", "Starts", "Stops", "Crashes", "OOM Kills", "Last Start", "Last Exit")
+	for _, h := range histories {
+		printRow(tw,
+			h.Process,
+			strconv.Itoa(h.Starts),
+			strconv.Itoa(h.Stops),
+			strconv.Itoa(h.Crashes),
+			strconv.Itoa(h.OOMKills),
+			formatTime(h.LastStart),
+			formatTime(h.LastExit),
+		)
+	}
+
+	return tw.Flush()
+}
+
+// PrintStrays prints what bpm gc found and what it did with each stray.
+func PrintStrays(results []gc.Result, stdout io.Writer) error {
+	tw := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+
+	printRow(tw, "Kind", "Job", "Process", "Path", "Reason", "Result")
+	for _, r := range results {
+		path := r.Path
+		if path == "" {
+			path = "-"
+		}
+
+		printRow(tw, string(r.Kind), r.Job, r.Process, path, r.Reason, strayResult(r))
+	}
+
+	return tw.Flush()
+}
+
+func strayResult(r gc.Result) string {
+	switch {
+	case r.Removed:
+		return "removed"
+	case r.Err == gc.ErrInUse:
+		return "in use"
+	case r.Err != nil:
+		return fmt.Sprintf("failed: %s", r.Err)
+	default:
+		return "would remove"
+	}
+}
+
+func eventDetails(e events.Event) string {
+	var details []string
+
+	if e.Pid > 0 {
+		details = append(details, fmt.Sprintf("pid=%d", e.Pid))
+	}
+	if e.ExitStatus != nil {
+		details = append(details, fmt.Sprintf("status=%d", *e.ExitStatus))
+	}
+	if e.Signal != "" {
+		details = append(details, fmt.Sprintf("signal=%s", e.Signal))
+	}
+	if e.OOMKilled {
+		details = append(details, "oom-killed")
+	}
+	if e.Hook != "" {
+		details = append(details, fmt.Sprintf("hook=%s", e.Hook))
+	}
+	if e.Waited != "" {
+		details = append(details, fmt.Sprintf("waited=%s", e.Waited))
+	}
+	if e.Reason != "" {
+		details = append(details, fmt.Sprintf("reason=%q", e.Reason))
+	}
+	if e.Error != "" {
+		details = append(details, fmt.Sprintf("error=%q", e.Error))
+	}
+
+	if len(details) == 0 {
+		return "-"
+	}
+
+	return strings.Join(details, " ")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
 func printRow(w io.Writer, args ...string) {
 	row := strings.Join(args, "\t")
 	fmt.Fprintf(w, "%s\n", row)
@@ -16,13 +16,17 @@
 package presenters_test
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 
 	"bpm/config"
+	"bpm/events"
+	"bpm/gc"
 	"bpm/models"
 	"bpm/presenters"
 )
@@ -39,6 +43,7 @@ var _ = Describe("Presenters", func() {
 				{Name: config.Encode("job-process-2"), Pid: 23456, Status: "created"},
 				{Name: config.Encode("job-process-1"), Pid: 34567, Status: "running"},
 				{Name: config.Encode("job-process-3"), Pid: 0, Status: "failed"},
+				{Name: config.Encode("job-process-4"), Pid: 45678, Status: models.ProcessStatePaused},
 			}
 
 			output = gbytes.NewBuffer()
@@ -50,6 +55,75 @@ var _ = Describe("Presenters", func() {
 			Expect(output).Should(gbytes.Say(fmt.Sprintf("%s\\s+%d\\s+%s", "job-process-2", 23456, "created")))
 			Expect(output).Should(gbytes.Say(fmt.Sprintf("%s\\s+%d\\s+%s", "job-process-1", 34567, "running")))
 			Expect(output).Should(gbytes.Say(fmt.Sprintf("%s\\s+%s\\s+%s", "job-process-3", "-", "failed")))
+			Expect(output).Should(gbytes.Say("job-process-4\\s+45678\\s+paused"))
+		})
+	})
+
+	Describe("PrintStatus", func() {
+		var output *gbytes.Buffer
+
+		BeforeEach(func() {
+			output = gbytes.NewBuffer()
+		})
+
+		It("prints the process and the state of its configuration", func() {
+			process := &models.Process{Name: config.Encode("job-process-1"), Pid: 34567, Status: "running"}
+			Expect(presenters.PrintStatus(process, models.ConfigStateChanged, output)).To(Succeed())
+			Expect(output).Should(gbytes.Say("Name\\s+Pid\\s+Status\\s+Config"))
+			Expect(output).Should(gbytes.Say("job-process-1\\s+34567\\s+running\\s+changed"))
+		})
+
+		It("does not print a pid for processes which are not running", func() {
+			process := &models.Process{Name: config.Encode("job-process-1"), Status: "stopped"}
+			Expect(presenters.PrintStatus(process, models.ConfigStateUnknown, output)).To(Succeed())
+			Expect(output).Should(gbytes.Say("job-process-1\\s+-\\s+stopped\\s+-"))
+		})
+	})
+
+	Describe("PrintEvents", func() {
+		It("prints the details of each event", func() {
+			status := 1
+			output := gbytes.NewBuffer()
+			Expect(presenters.PrintEvents([]events.Event{
+				{Time: time.Now(), Type: events.Exited, Process: "server", Pid: 42, ExitStatus: &status},
+				{Time: time.Now(), Type: events.SignalSent, Process: "worker", Signal: "TERM"},
+				{Time: time.Now(), Type: events.Removed, Process: "server"},
+			}, output)).To(Succeed())
+
+			Expect(output).Should(gbytes.Say("Time\\s+Process\\s+Event\\s+Details"))
+			Expect(output).Should(gbytes.Say("server\\s+exited\\s+pid=42 status=1"))
+			Expect(output).Should(gbytes.Say("worker\\s+signal-sent\\s+signal=TERM"))
+			Expect(output).Should(gbytes.Say("server\\s+removed\\s+-"))
+		})
+	})
+
+	Describe("PrintHistory", func() {
+		It("prints a summary of each process", func() {
+			output := gbytes.NewBuffer()
+			Expect(presenters.PrintHistory([]*events.History{
+				{Process: "server", Starts: 4, Stops: 1, Crashes: 3, OOMKills: 2},
+			}, output)).To(Succeed())
+
+			Expect(output).Should(gbytes.Say("Process\\s+Starts\\s+Stops\\s+Crashes\\s+OOM Kills\\s+Last Start\\s+Last Exit"))
+			Expect(output).Should(gbytes.Say("server\\s+4\\s+1\\s+3\\s+2\\s+-\\s+-"))
+		})
+	})
+
+	Describe("PrintStrays", func() {
+		It("prints what was done with each stray", func() {
+			output := gbytes.NewBuffer()
+			Expect(presenters.PrintStrays([]gc.Result{
+				{Stray: gc.Stray{Kind: gc.Container, Job: "db", Process: "db", Reason: "job is not present"}, Removed: true},
+				{Stray: gc.Stray{Kind: gc.Bundle, Job: "web", Process: "worker", Path: "/bundles/web/worker", Reason: "process has no container"}, Err: gc.ErrInUse},
+				{Stray: gc.Stray{Kind: gc.PidFile, Job: "web", Process: "worker", Path: "/run/web/worker.pid", Reason: "process has no container"}, Err: errors.New("boom")},
+				{Stray: gc.Stray{Kind: gc.LockFile, Job: "web", Process: "server", Path: "/run/web/server.lock", Reason: "lock is not held"}},
+			}, output)).To(Succeed())
+
+			Expect(output).Should(gbytes.Say("Kind\\s+Job\\s+Process\\s+Path\\s+Reason\\s+Result"))
+			Expect(output).Should(gbytes.Say("container\\s+db\\s+db\\s+-\\s+job is not present\\s+removed"))
+			Expect(output).Should(gbytes.Say("bundle\\s+web\\s+worker\\s+/bundles/web/worker\\s+process has no container\\s+in use"))
+			Expect(output).Should(gbytes.Say("pidfile\\s+web\\s+worker\\s+/run/web/worker.pid\\s+process has no container\\s+failed: boom"))
+			Expect(output).Should(gbytes.Say("lockfile\\s+web\\s+server\\s+/run/web/server.lock\\s+lock is not held\\s+would remove"))
 		})
 	})
 })
@@ -0,0 +1,194 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package procwatch notifies when a process which is not a child of bpm
+// exits. The init process of a container is a child of runc rather than bpm
+// and so it cannot be waited for.
+package procwatch
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+
+	"bpm/cgroups"
+)
+
+// sysPidfdOpen is the number of the pidfd_open system call which is the same
+// on every architecture. It was added in Linux 5.3.
+const sysPidfdOpen = 434
+
+// ErrUnsupported is returned when the kernel offers no way to watch a
+// process. The caller has to poll the process instead.
+var ErrUnsupported = errors.New("watching processes is not supported by the kernel")
+
+type Watcher struct{}
+
+func NewWatcher() *Watcher {
+	return &Watcher{}
+}
+
+// Watch returns a channel which is closed when a process exits or when the
+// cgroup it is in becomes empty, whichever happens first. The process is
+// watched with a pidfd and the cgroup is watched through its cgroup.events
+// file, which only exists with cgroups v2. Watching stops when stop is
+// closed and the channel is not closed after that.
+func (w *Watcher) Watch(pid int, stop <-chan struct{}) (<-chan struct{}, error) {
+	exited := make(chan struct{})
+
+	pidfd, err := pidfdOpen(pid)
+	if err == unix.ESRCH {
+		close(exited)
+		return exited, nil
+	}
+	if err != nil {
+		pidfd = -1
+	}
+
+	eventsFd := openCgroupEvents(pid)
+	if pidfd < 0 && eventsFd < 0 {
+		return nil, ErrUnsupported
+	}
+
+	if eventsFd >= 0 && !populated(eventsFd) {
+		closeFds(pidfd, eventsFd)
+		close(exited)
+		return exited, nil
+	}
+
+	var wake [2]int
+	if err := unix.Pipe2(wake[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		closeFds(pidfd, eventsFd)
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+			unix.Write(wake[1], []byte{0})
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer closeFds(pidfd, eventsFd, wake[0], wake[1])
+		defer close(done)
+
+		if !wait(pidfd, eventsFd, wake[0]) {
+			return
+		}
+
+		// The exit is not reported once watching has been stopped even if
+		// it was seen first.
+		select {
+		case <-stop:
+		default:
+			close(exited)
+		}
+	}()
+
+	return exited, nil
+}
+
+// wait blocks until the process exits or its cgroup becomes empty, in which
+// case it returns true, or until something is written to the wake pipe.
+func wait(pidfd, eventsFd, wakeFd int) bool {
+	fds := []unix.PollFd{{Fd: int32(wakeFd), Events: unix.POLLIN}}
+	if pidfd >= 0 {
+		fds = append(fds, unix.PollFd{Fd: int32(pidfd), Events: unix.POLLIN})
+	}
+	if eventsFd >= 0 {
+		// A change to cgroup.events is reported as an error and priority
+		// data rather than as data to read.
+		fds = append(fds, unix.PollFd{Fd: int32(eventsFd), Events: unix.POLLPRI})
+	}
+
+	for {
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return false
+		}
+
+		for _, fd := range fds {
+			switch {
+			case fd.Revents == 0:
+				continue
+			case int(fd.Fd) == wakeFd:
+				return false
+			case int(fd.Fd) == pidfd:
+				return true
+			case !populated(eventsFd):
+				return true
+			}
+		}
+	}
+}
+
+func pidfdOpen(pid int) (int, error) {
+	fd, _, errno := unix.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+
+	return int(fd), nil
+}
+
+// openCgroupEvents opens the cgroup.events file of the cgroups v2 cgroup of
+// a process. It returns -1 if the process is not in one.
+func openCgroupEvents(pid int) int {
+	cgroup, err := cgroups.ProcessCgroup(pid, "")
+	if err != nil {
+		return -1
+	}
+
+	fd, err := unix.Open(filepath.Join(cgroup, "cgroup.events"), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1
+	}
+
+	return fd
+}
+
+// populated reports whether there are any processes left in a cgroup. The
+// file is read from the start each time so that the next change to it is
+// reported by poll.
+func populated(eventsFd int) bool {
+	buf := make([]byte, 256)
+	n, err := unix.Pread(eventsFd, buf, 0)
+	if err != nil {
+		return true
+	}
+
+	for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+		if bytes.Equal(bytes.TrimSpace(line), []byte("populated 0")) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func closeFds(fds ...int) {
+	for _, fd := range fds {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package procwatch_test
+
+import (
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/procwatch"
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		watcher *procwatch.Watcher
+		cmd     *exec.Cmd
+		stop    chan struct{}
+	)
+
+	BeforeEach(func() {
+		watcher = procwatch.NewWatcher()
+		stop = make(chan struct{})
+
+		cmd = exec.Command("sleep", "60")
+		Expect(cmd.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		close(stop)
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	watch := func(pid int) <-chan struct{} {
+		exited, err := watcher.Watch(pid, stop)
+		if err == procwatch.ErrUnsupported {
+			Skip("the kernel cannot watch processes")
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return exited
+	}
+
+	It("notifies when the process exits", func() {
+		exited := watch(cmd.Process.Pid)
+		Consistently(exited).ShouldNot(BeClosed())
+
+		Expect(cmd.Process.Kill()).To(Succeed())
+		Eventually(exited).Should(BeClosed())
+	})
+
+	It("notifies when the process has already exited", func() {
+		Expect(cmd.Process.Kill()).To(Succeed())
+		cmd.Wait()
+
+		exited := watch(cmd.Process.Pid)
+		Eventually(exited).Should(BeClosed())
+	})
+
+	It("stops watching when it is told to", func() {
+		exited := watch(cmd.Process.Pid)
+
+		close(stop)
+		stop = make(chan struct{})
+
+		Expect(cmd.Process.Kill()).To(Succeed())
+		Consistently(exited).ShouldNot(BeClosed())
+	})
+})
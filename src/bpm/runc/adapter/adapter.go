@@ -18,22 +18,27 @@ package adapter
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"code.cloudfoundry.org/bytefmt"
 	"code.cloudfoundry.org/lager"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
 
 	"bpm/config"
+	"bpm/mount"
 	"bpm/runc/specbuilder"
 	"bpm/sysfeat"
 )
 
 const (
 	resolvConfDir = "/run/resolvconf"
+	secretsDir    = "/run/secrets"
 	defaultLang   = "en_US.UTF-8"
 )
 
@@ -111,9 +116,98 @@ func (a *RuncAdapter) CreateJobPrerequisites(
 		return nil, nil, err
 	}
 
+	if len(procCfg.Secrets) > 0 {
+		err = createSecrets(bpmCfg.SecretsDir(), procCfg.Secrets, user)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return createLogFiles(bpmCfg, user)
 }
 
+// RemoveSecrets unmounts and removes the directory holding the secrets of the
+// process. It does nothing if the process has no secrets.
+func (a *RuncAdapter) RemoveSecrets(bpmCfg *config.BPMConfig) error {
+	if err := unmountSecrets(bpmCfg.SecretsDir()); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(bpmCfg.SecretsDir())
+}
+
+// createSecrets copies the secrets of the process into a new tmpfs which only
+// the process user can read. This keeps the secrets out of the container spec
+// and off the disk. Any secrets left over from a previous run are discarded.
+func createSecrets(dir string, secrets map[string]string, user specs.User) error {
+	err := unmountSecrets(dir)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	options := fmt.Sprintf("mode=0500,uid=%d,gid=%d", user.UID, user.GID)
+	err = mount.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC|unix.MS_NODEV, options)
+	if err != nil {
+		return err
+	}
+
+	err = writeSecrets(dir, secrets, user)
+	if err != nil {
+		// The process is not started and so nothing would otherwise remove
+		// the secrets which were already written.
+		unmountSecrets(dir)
+		return err
+	}
+
+	return nil
+}
+
+func writeSecrets(dir string, secrets map[string]string, user specs.User) error {
+	for name, path := range secrets {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+
+		f, err := createFileFor(filepath.Join(dir, name), int(user.UID), int(user.GID))
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		err = os.Chmod(f.Name(), 0400)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unmountSecrets(dir string) error {
+	err := mount.Unmount(dir, 0)
+	switch err {
+	// EINVAL is returned if nothing is mounted on the directory and ENOENT if
+	// the directory does not exist
+	case unix.EINVAL, unix.ENOENT, nil:
+		return nil
+	default:
+		return err
+	}
+}
+
 func createDirs(dirs []string, user specs.User) error {
 	for _, dir := range dirs {
 		err := createDirFor(dir, int(user.UID), int(user.GID))
@@ -189,6 +283,14 @@ func (a *RuncAdapter) BuildSpec(
 		return specs.Spec{}, err
 	}
 
+	var boshEnv map[string]string
+	if procCfg.InjectBoshEnv {
+		boshEnv, err = boshEnvironment(bpmCfg)
+		if err != nil {
+			return specs.Spec{}, err
+		}
+	}
+
 	ms := newMountDedup(logger)
 	ms.addMounts(systemIdentityMounts(mountResolvConf))
 	ms.addMounts(boshMounts(bpmCfg, procCfg.EphemeralDisk, procCfg.PersistentDisk))
@@ -196,6 +298,11 @@ func (a *RuncAdapter) BuildSpec(
 	if procCfg.Unsafe != nil && len(procCfg.Unsafe.UnrestrictedVolumes) > 0 {
 		ms.addMounts(userProvidedIdentityMounts(bpmCfg, procCfg.Unsafe.UnrestrictedVolumes))
 	}
+	if len(procCfg.Secrets) > 0 {
+		ms.addMounts([]specs.Mount{
+			bindMountWithOptions(secretsDir, bpmCfg.SecretsDir(), "nodev", "nosuid", "noexec", "bind", "ro"),
+		})
+	}
 
 	spec := specbuilder.Build(
 		specbuilder.WithRootFilesystem(bpmCfg.RootFSPath()),
@@ -203,7 +310,7 @@ func (a *RuncAdapter) BuildSpec(
 		specbuilder.WithProcess(
 			procCfg.Executable,
 			procCfg.Args,
-			processEnvironment(procCfg.Env, bpmCfg),
+			processEnvironment(procCfg, bpmCfg, boshEnv),
 			cwd,
 		),
 		specbuilder.WithCapabilities(processCapabilities(procCfg.Capabilities)),
@@ -342,38 +449,77 @@ func (d *dedupMounts) mounts() []specs.Mount {
 		ms = append(ms, mount)
 	}
 
+	// Parent directories must be mounted before their children. Mounts at the
+	// same depth are ordered by destination so that the same configuration
+	// always produces the same spec.
 	sort.Slice(ms, func(i, j int) bool {
 		iElems := strings.Split(ms[i].Destination, "/")
 		jElems := strings.Split(ms[j].Destination, "/")
-		return len(iElems) < len(jElems)
+		if len(iElems) != len(jElems) {
+			return len(iElems) < len(jElems)
+		}
+		return ms[i].Destination < ms[j].Destination
 	})
 
 	return ms
 }
 
-func processEnvironment(env map[string]string, cfg *config.BPMConfig) []string {
-	var environ []string
+// boshEnvironment describes the BOSH instance which the process is running on
+// using the instance spec written by the BOSH agent.
+func boshEnvironment(cfg *config.BPMConfig) (map[string]string, error) {
+	instance, err := cfg.Bosh().InstanceSpec()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BOSH instance spec: %w", err)
+	}
+
+	return map[string]string{
+		"BOSH_DEPLOYMENT":     instance.Deployment,
+		"BOSH_INSTANCE_NAME":  instance.Name,
+		"BOSH_INSTANCE_INDEX": strconv.Itoa(instance.Index),
+		"BOSH_INSTANCE_ID":    instance.ID,
+		"BOSH_AZ":             instance.AZ,
+		"BOSH_ADDRESS":        instance.Address,
+		"BOSH_IP":             instance.IP(),
+		"BOSH_BOOTSTRAP":      strconv.FormatBool(instance.Bootstrap),
+		"BOSH_JOB":            cfg.JobName(),
+		"BPM_PROCESS":         cfg.ProcName(),
+	}, nil
+}
 
-	for k, v := range env {
-		environ = append(environ, fmt.Sprintf("%s=%s", k, v))
+// processEnvironment builds the environment of the process. The variables in
+// the configuration of the process take precedence over the BOSH variables and
+// the defaults which bpm provides. Any variables which the process unsets are
+// removed last. The environment is sorted so that the same configuration
+// always produces the same spec.
+func processEnvironment(procCfg *config.ProcessConfig, cfg *config.BPMConfig, boshEnv map[string]string) []string {
+	env := map[string]string{}
+
+	if procCfg.EnvDefaults == nil || *procCfg.EnvDefaults {
+		env["TMPDIR"] = cfg.TempDir()
+		env["LANG"] = defaultLang
+		env["PATH"] = defaultPath(cfg)
+		env["HOME"] = cfg.DataDir()
 	}
 
-	if _, ok := env["TMPDIR"]; !ok {
-		environ = append(environ, fmt.Sprintf("TMPDIR=%s", cfg.TempDir()))
+	for k, v := range boshEnv {
+		env[k] = v
 	}
 
-	if _, ok := env["LANG"]; !ok {
-		environ = append(environ, fmt.Sprintf("LANG=%s", defaultLang))
+	for k, v := range procCfg.Env {
+		env[k] = v
 	}
 
-	if _, ok := env["PATH"]; !ok {
-		environ = append(environ, fmt.Sprintf("PATH=%s", defaultPath(cfg)))
+	for _, k := range procCfg.UnsetEnv {
+		delete(env, k)
 	}
 
-	if _, ok := env["HOME"]; !ok {
-		environ = append(environ, fmt.Sprintf("HOME=%s", cfg.DataDir()))
+	environ := make([]string, 0, len(env))
+	for k, v := range env {
+		environ = append(environ, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	sort.Strings(environ)
+
 	return environ
 }
 
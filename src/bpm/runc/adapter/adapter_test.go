@@ -16,6 +16,7 @@
 package adapter
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -31,6 +32,8 @@ import (
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 
 	"bpm/config"
+	"bpm/mount"
+	"bpm/runc/client"
 	"bpm/runc/specbuilder"
 	"bpm/sysfeat"
 )
@@ -248,6 +251,93 @@ var _ = Describe("RuncAdapter", func() {
 				Expect(dataDirInfo.Sys().(*syscall.Stat_t).Gid).To(Equal(uint32(300)))
 			})
 		})
+
+		Context("when the process has secrets", func() {
+			var secretPath string
+
+			BeforeEach(func() {
+				secretPath = filepath.Join(systemRoot, "db-password")
+				Expect(ioutil.WriteFile(secretPath, []byte("hunter2"), 0600)).To(Succeed())
+
+				procCfg.Secrets = map[string]string{"db-password": secretPath}
+			})
+
+			AfterEach(func() {
+				Expect(runcAdapter.RemoveSecrets(bpmCfg)).To(Succeed())
+				Expect(bpmCfg.SecretsDir()).NotTo(BeADirectory())
+			})
+
+			It("copies the secrets into a tmpfs which only the process user can read", func() {
+				_, _, err := runcAdapter.CreateJobPrerequisites(bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				mnts, err := mount.Mounts()
+				Expect(err).NotTo(HaveOccurred())
+
+				var filesystem string
+				for _, mnt := range mnts {
+					if mnt.MountPoint == bpmCfg.SecretsDir() {
+						filesystem = mnt.Filesystem
+					}
+				}
+				Expect(filesystem).To(Equal("tmpfs"))
+
+				secretsDirInfo, err := os.Stat(bpmCfg.SecretsDir())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(secretsDirInfo.Mode() & os.ModePerm).To(Equal(os.FileMode(0500)))
+				Expect(secretsDirInfo.Sys().(*syscall.Stat_t).Uid).To(Equal(uint32(200)))
+				Expect(secretsDirInfo.Sys().(*syscall.Stat_t).Gid).To(Equal(uint32(300)))
+
+				secret := filepath.Join(bpmCfg.SecretsDir(), "db-password")
+				Expect(ioutil.ReadFile(secret)).To(Equal([]byte("hunter2")))
+
+				secretInfo, err := os.Stat(secret)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(secretInfo.Mode() & os.ModePerm).To(Equal(os.FileMode(0400)))
+				Expect(secretInfo.Sys().(*syscall.Stat_t).Uid).To(Equal(uint32(200)))
+				Expect(secretInfo.Sys().(*syscall.Stat_t).Gid).To(Equal(uint32(300)))
+			})
+
+			It("replaces the secrets from a previous run", func() {
+				_, _, err := runcAdapter.CreateJobPrerequisites(bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ioutil.WriteFile(secretPath, []byte("correct horse"), 0600)).To(Succeed())
+				_, _, err = runcAdapter.CreateJobPrerequisites(bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ioutil.ReadFile(filepath.Join(bpmCfg.SecretsDir(), "db-password"))).To(Equal([]byte("correct horse")))
+			})
+
+			Context("when a secret cannot be read", func() {
+				BeforeEach(func() {
+					procCfg.Secrets["missing"] = filepath.Join(systemRoot, "missing")
+				})
+
+				It("returns an error naming the secret", func() {
+					_, _, err := runcAdapter.CreateJobPrerequisites(bpmCfg, procCfg, user)
+					Expect(err).To(MatchError(ContainSubstring("failed to read secret missing")))
+				})
+
+				It("does not leave the secrets mounted", func() {
+					_, _, err := runcAdapter.CreateJobPrerequisites(bpmCfg, procCfg, user)
+					Expect(err).To(HaveOccurred())
+
+					mnts, err := mount.Mounts()
+					Expect(err).NotTo(HaveOccurred())
+
+					for _, mnt := range mnts {
+						Expect(mnt.MountPoint).NotTo(Equal(bpmCfg.SecretsDir()))
+					}
+				})
+			})
+		})
+	})
+
+	Describe("RemoveSecrets", func() {
+		It("does nothing when the process has no secrets", func() {
+			Expect(runcAdapter.RemoveSecrets(bpmCfg)).To(Succeed())
+		})
 	})
 
 	Describe("BuildSpec", func() {
@@ -307,6 +397,7 @@ var _ = Describe("RuncAdapter", func() {
 			Expect(spec.Process.User).To(Equal(user))
 			Expect(spec.Process.Args).To(Equal(expectedProcessArgs))
 			Expect(spec.Process.Env).To(ConsistOf(expectedEnv))
+			Expect(sort.StringsAreSorted(spec.Process.Env)).To(BeTrue())
 			Expect(spec.Process.Cwd).To(Equal(bpmCfg.JobDir()))
 			Expect(spec.Process.Rlimits).To(BeNil())
 			Expect(spec.Process.NoNewPrivileges).To(Equal(true))
@@ -522,6 +613,33 @@ var _ = Describe("RuncAdapter", func() {
 			Expect(os.RemoveAll(resolvConfDir)).To(Succeed())
 		})
 
+		It("builds the same spec from the same configuration", func() {
+			spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				again, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(again).To(Equal(spec))
+			}
+		})
+
+		It("hashes a spec with several environment variables the same every time", func() {
+			procCfg.Env["THREE"] = "four"
+			procCfg.Env["FIVE"] = "six"
+
+			spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+			Expect(err).NotTo(HaveOccurred())
+			hash, err := client.SpecHash(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				again, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(client.SpecHash(again)).To(Equal(hash))
+			}
+		})
+
 		Context("when a user provides TMPDIR, LANG and PATH, and HOME environment variables", func() {
 			BeforeEach(func() {
 				procCfg.Env["TMPDIR"] = "/I/AM/A/TMPDIR"
@@ -544,6 +662,104 @@ var _ = Describe("RuncAdapter", func() {
 			})
 		})
 
+		Context("when the process disables the default environment", func() {
+			BeforeEach(func() {
+				envDefaults := false
+				procCfg.EnvDefaults = &envDefaults
+				procCfg.Env["LANG"] = "esperanto"
+			})
+
+			It("does not add TMPDIR, LANG, PATH, or HOME", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spec.Process.Env).NotTo(ContainElement(HavePrefix("TMPDIR=")))
+				Expect(spec.Process.Env).NotTo(ContainElement(HavePrefix("PATH=")))
+				Expect(spec.Process.Env).NotTo(ContainElement(HavePrefix("HOME=")))
+				Expect(spec.Process.Env).To(ContainElement("LANG=esperanto"))
+			})
+		})
+
+		Context("when the process unsets environment variables", func() {
+			BeforeEach(func() {
+				procCfg.UnsetEnv = []string{"HOME", "RAVE"}
+			})
+
+			It("removes them from the environment", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spec.Process.Env).NotTo(ContainElement(HavePrefix("HOME=")))
+				Expect(spec.Process.Env).NotTo(ContainElement(HavePrefix("RAVE=")))
+				Expect(spec.Process.Env).To(ContainElement(fmt.Sprintf("TMPDIR=%s", bpmCfg.TempDir())))
+			})
+		})
+
+		Context("when the process asks for the BOSH environment", func() {
+			BeforeEach(func() {
+				procCfg.InjectBoshEnv = true
+				procCfg.Env["BOSH_AZ"] = "overridden"
+
+				Expect(os.MkdirAll(filepath.Join(systemRoot, "bosh"), 0700)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(systemRoot, "bosh", "spec.json"), []byte(`{
+					"deployment": "cf",
+					"name": "router",
+					"index": 2,
+					"id": "instance-id",
+					"az": "z1",
+					"address": "instance-id.router.default.cf.bosh",
+					"bootstrap": false,
+					"networks": {"default": {"ip": "10.0.0.5", "default": ["dns", "gateway"]}}
+				}`), 0600)).To(Succeed())
+			})
+
+			It("describes the BOSH instance in the environment", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spec.Process.Env).To(ContainElement("BOSH_DEPLOYMENT=cf"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_INSTANCE_NAME=router"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_INSTANCE_INDEX=2"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_INSTANCE_ID=instance-id"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_ADDRESS=instance-id.router.default.cf.bosh"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_IP=10.0.0.5"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_BOOTSTRAP=false"))
+				Expect(spec.Process.Env).To(ContainElement("BOSH_JOB=example"))
+				Expect(spec.Process.Env).To(ContainElement("BPM_PROCESS=server"))
+				Expect(sort.StringsAreSorted(spec.Process.Env)).To(BeTrue())
+			})
+
+			It("lets the process configuration override the BOSH environment", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spec.Process.Env).To(ContainElement("BOSH_AZ=overridden"))
+				Expect(spec.Process.Env).NotTo(ContainElement("BOSH_AZ=z1"))
+			})
+
+			Context("when the instance spec cannot be read", func() {
+				BeforeEach(func() {
+					Expect(os.Remove(filepath.Join(systemRoot, "bosh", "spec.json"))).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+					Expect(err).To(MatchError(ContainSubstring("failed to read BOSH instance spec")))
+				})
+			})
+		})
+
+		Context("when the process does not ask for the BOSH environment", func() {
+			It("does not describe the BOSH instance in the environment", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, e := range spec.Process.Env {
+					Expect(e).NotTo(HavePrefix("BOSH_"))
+				}
+			})
+		})
+
 		Context("when a workdir is provided", func() {
 			BeforeEach(func() {
 				procCfg.WorkDir = "/I/AM/A/WORKDIR"
@@ -800,5 +1016,32 @@ var _ = Describe("RuncAdapter", func() {
 				}))
 			})
 		})
+
+		Context("when the process has secrets", func() {
+			BeforeEach(func() {
+				procCfg.Secrets = map[string]string{"db-password": "/var/vcap/jobs/example/config/db-password"}
+			})
+
+			It("mounts the secrets directory read-only at /run/secrets", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spec.Mounts).To(ContainElement(specs.Mount{
+					Destination: "/run/secrets",
+					Type:        "bind",
+					Source:      bpmCfg.SecretsDir(),
+					Options:     []string{"nodev", "nosuid", "noexec", "bind", "ro"},
+				}))
+			})
+
+			It("does not include the secrets in the spec", func() {
+				spec, err := runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err := json.Marshal(spec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(data)).NotTo(ContainSubstring("db-password"))
+			})
+		})
 	})
 })
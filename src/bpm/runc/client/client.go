@@ -16,34 +16,71 @@
 package client
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
+// specHashFile is stored alongside the spec in each bundle so that the spec a
+// container was started with can be compared to the spec its configuration
+// would produce now.
+const specHashFile = "config.sha256"
+
 type Signal int
 
 const (
 	Term Signal = iota
 	Quit
+	Hup
+	Int
+	Kill
+	Usr1
+	Usr2
 )
 
+var signalNames = map[Signal]string{
+	Term: "TERM",
+	Quit: "QUIT",
+	Hup:  "HUP",
+	Int:  "INT",
+	Kill: "KILL",
+	Usr1: "USR1",
+	Usr2: "USR2",
+}
+
 func (s Signal) String() string {
-	switch s {
-	case Term:
-		return "TERM"
-	case Quit:
-		return "QUIT"
-	default:
-		return "unknown"
+	if name, ok := signalNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// ParseSignal returns the signal with a name such as TERM or SIGTERM.
+func ParseSignal(name string) (Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	for signal, signalName := range signalNames {
+		if signalName == name {
+			return signal, nil
+		}
 	}
+
+	return 0, fmt.Errorf("unsupported signal: %s", name)
 }
 
 // https://github.com/opencontainers/runc/blob/master/list.go#L24-L45
@@ -56,6 +93,48 @@ type ContainerState struct {
 	Status string `json:"status"`
 }
 
+// InitProcess is what runc recorded about the init process of a container
+// when the container was created.
+type InitProcess struct {
+	Pid int
+
+	// StartTime is when the process started in clock ticks since boot (see
+	// the starttime field of proc(5)).
+	StartTime uint64
+
+	// Cgroups is the directory of each cgroup of the container by the
+	// subsystem it belongs to.
+	Cgroups map[string]string
+}
+
+// ErrInvalidState is returned when the state which runc keeps for a container
+// cannot be decoded (e.g. because it was truncated by an unclean reboot).
+var ErrInvalidState = errors.New("container state is invalid")
+
+// https://github.com/opencontainers/runc/blob/master/libcontainer/container_linux.go
+type runcState struct {
+	InitProcessPid   int               `json:"init_process_pid"`
+	InitProcessStart json.RawMessage   `json:"init_process_start"`
+	CgroupPaths      map[string]string `json:"cgroup_paths"`
+}
+
+// RuncError is returned when runc itself fails to run a container. Message is
+// the last error runc wrote to its log file so that the cause is not lost
+// among the job's own output.
+type RuncError struct {
+	Message string
+	Err     error
+}
+
+func (e *RuncError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Err)
+}
+
+type runcLogEntry struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
 type RuncClient struct {
 	runcPath string
 	runcRoot string
@@ -93,11 +172,78 @@ func (*RuncClient) CreateBundle(
 
 	enc := json.NewEncoder(f)
 	enc.SetIndent("", "\t")
-	return enc.Encode(&jobSpec)
+	if err := enc.Encode(&jobSpec); err != nil {
+		return err
+	}
+
+	hash, err := SpecHash(jobSpec)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(bundlePath, specHashFile), []byte(hash+"\n"), 0600)
 }
 
-func (c *RuncClient) RunContainer(pidFilePath, bundlePath, containerID string, detach bool, stdout, stderr io.Writer) (int, error) {
-	args := []string{"--root", c.runcRoot, "run", "--bundle", bundlePath, "--pid-file", pidFilePath}
+// BundleSpec returns the spec stored in a bundle along with its hash. Bundles
+// created by older versions of bpm do not have a stored hash so it is
+// calculated from the spec instead.
+func (*RuncClient) BundleSpec(bundlePath string) (specs.Spec, string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(bundlePath, "config.json"))
+	if err != nil {
+		return specs.Spec{}, "", err
+	}
+
+	var spec specs.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return specs.Spec{}, "", err
+	}
+
+	hash, err := ioutil.ReadFile(filepath.Join(bundlePath, specHashFile))
+	if os.IsNotExist(err) {
+		h, err := SpecHash(spec)
+		return spec, h, err
+	} else if err != nil {
+		return specs.Spec{}, "", err
+	}
+
+	return spec, strings.TrimSpace(string(hash)), nil
+}
+
+// SpecHash returns a digest of the spec which changes whenever anything in the
+// spec changes. The order of the environment variables does not matter as
+// they are built from a map.
+func SpecHash(spec specs.Spec) (string, error) {
+	if spec.Process != nil {
+		process := *spec.Process
+		process.Env = append([]string(nil), process.Env...)
+		sort.Strings(process.Env)
+		spec.Process = &process
+	}
+
+	data, err := json.Marshal(&spec)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *RuncClient) RunContainer(pidFilePath, logFilePath, bundlePath, containerID string, detach bool, stdout, stderr io.Writer) (int, error) {
+	// runc appends to its log file so we remove anything left behind by a
+	// previous attempt before we need to read it back.
+	if err := os.Remove(logFilePath); err != nil && !os.IsNotExist(err) {
+		return 1, err
+	}
+
+	args := []string{
+		"--root", c.runcRoot,
+		"--log", logFilePath,
+		"--log-format", "json",
+		"run",
+		"--bundle", bundlePath,
+		"--pid-file", pidFilePath,
+	}
 	if detach {
 		args = append(args, "--detach")
 	}
@@ -108,8 +254,14 @@ func (c *RuncClient) RunContainer(pidFilePath, bundlePath, containerID string, d
 	runcCmd.Stderr = stderr
 
 	if err := runcCmd.Run(); err != nil {
-		if status, ok := runcCmd.ProcessState.Sys().(syscall.WaitStatus); ok {
-			return status.ExitStatus(), err
+		if msg := lastRuncLogError(logFilePath); msg != "" {
+			err = &RuncError{Message: msg, Err: err}
+		}
+
+		if runcCmd.ProcessState != nil {
+			if status, ok := runcCmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+				return status.ExitStatus(), err
+			}
 		}
 
 		// If we can't get the exit status for some reason then make
@@ -120,6 +272,32 @@ func (c *RuncClient) RunContainer(pidFilePath, bundlePath, containerID string, d
 	return 0, nil
 }
 
+// lastRuncLogError returns the message of the last error that runc wrote to
+// its JSON log file. It returns an empty string if the log cannot be read or
+// does not contain any errors.
+func lastRuncLogError(logFilePath string) string {
+	f, err := os.Open(logFilePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var msg string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry runcLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+
+		if entry.Level == "error" || entry.Level == "fatal" {
+			msg = entry.Msg
+		}
+	}
+
+	return msg
+}
+
 // Exec assumes you are launching an interactive shell.
 // We should improve the interface to mirror `runc exec` more generally.
 func (c *RuncClient) Exec(containerID, command string, stdin io.Reader, stdout, stderr io.Writer) error {
@@ -175,6 +353,42 @@ func decodeContainerStateErr(b []byte, err error) error {
 	return err
 }
 
+// InitProcess reads the state which runc keeps for a container. It returns
+// nil if runc has no state for the container.
+func (c *RuncClient) InitProcess(containerID string) (*InitProcess, error) {
+	data, err := ioutil.ReadFile(filepath.Join(c.runcRoot, containerID, "state.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state runcState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidState, err)
+	}
+
+	// Older versions of runc record the start time as a string.
+	start, err := strconv.ParseUint(strings.Trim(string(state.InitProcessStart), `"`), 10, 64)
+	if err != nil || state.InitProcessPid <= 0 {
+		return nil, fmt.Errorf("%w: missing init process", ErrInvalidState)
+	}
+
+	return &InitProcess{
+		Pid:       state.InitProcessPid,
+		StartTime: start,
+		Cgroups:   state.CgroupPaths,
+	}, nil
+}
+
+// DestroyContainerState removes the state which runc keeps for a container
+// without touching any of its processes. It is only safe to use when the
+// processes of the container are known to be gone.
+func (c *RuncClient) DestroyContainerState(containerID string) error {
+	return os.RemoveAll(filepath.Join(c.runcRoot, containerID))
+}
+
 func (c *RuncClient) ListContainers() ([]ContainerState, error) {
 	runcCmd := exec.Command(
 		c.runcPath,
@@ -209,6 +423,31 @@ func (c *RuncClient) SignalContainer(containerID string, signal Signal) error {
 	return runcCmd.Run()
 }
 
+// PauseContainer freezes every process in a container using the freezer
+// cgroup.
+func (c *RuncClient) PauseContainer(containerID string) error {
+	runcCmd := exec.Command(
+		c.runcPath,
+		"--root", c.runcRoot,
+		"pause",
+		containerID,
+	)
+
+	return runcCmd.Run()
+}
+
+// ResumeContainer thaws every process in a container which has been paused.
+func (c *RuncClient) ResumeContainer(containerID string) error {
+	runcCmd := exec.Command(
+		c.runcPath,
+		"--root", c.runcRoot,
+		"resume",
+		containerID,
+	)
+
+	return runcCmd.Run()
+}
+
 func (c *RuncClient) DeleteContainer(containerID string) error {
 	runcCmd := exec.Command(
 		c.runcPath,
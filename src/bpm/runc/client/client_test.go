@@ -16,7 +16,10 @@
 package client_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -108,6 +111,18 @@ var _ = Describe("RuncClient", func() {
 			Expect(configData).To(MatchJSON(expectedConfigData))
 		})
 
+		It("stores the hash of the spec in the root bundle directory", func() {
+			err := runcClient.CreateBundle(bundlePath, jobSpec, user)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedHash, err := client.SpecHash(jobSpec)
+			Expect(err).NotTo(HaveOccurred())
+
+			hashData, err := ioutil.ReadFile(filepath.Join(bundlePath, "config.sha256"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(hashData)).To(Equal(expectedHash + "\n"))
+		})
+
 		Context("when creating the bundle directory fails", func() {
 			BeforeEach(func() {
 				_, err := os.Create(bundlePath)
@@ -211,6 +226,262 @@ exit 0
 		})
 	})
 
+	Describe("InitProcess", func() {
+		var runcRoot string
+
+		writeState := func(id, state string) {
+			Expect(os.MkdirAll(filepath.Join(runcRoot, id), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(runcRoot, id, "state.json"), []byte(state), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			runcRoot, err = ioutil.TempDir("", "runc-root")
+			Expect(err).NotTo(HaveOccurred())
+
+			runcClient = client.NewRuncClient("/path/to/runc", runcRoot)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(runcRoot)).To(Succeed())
+		})
+
+		It("reads the init process from the state of the container", func() {
+			writeState("foo", `{"id":"foo","init_process_pid":42,"init_process_start":1234,"cgroup_paths":{"memory":"/sys/fs/cgroup/memory/foo"}}`)
+
+			init, err := runcClient.InitProcess("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(init).To(Equal(&client.InitProcess{
+				Pid:       42,
+				StartTime: 1234,
+				Cgroups:   map[string]string{"memory": "/sys/fs/cgroup/memory/foo"},
+			}))
+		})
+
+		It("reads a start time which was recorded as a string", func() {
+			writeState("foo", `{"id":"foo","init_process_pid":42,"init_process_start":"1234"}`)
+
+			init, err := runcClient.InitProcess("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(init.StartTime).To(Equal(uint64(1234)))
+		})
+
+		It("returns nil when there is no state for the container", func() {
+			init, err := runcClient.InitProcess("foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(init).To(BeNil())
+		})
+
+		It("returns an error when the state cannot be decoded", func() {
+			writeState("foo", `{"id":"foo","init_pro`)
+
+			_, err := runcClient.InitProcess("foo")
+			Expect(errors.Is(err, client.ErrInvalidState)).To(BeTrue())
+		})
+
+		It("returns an error when the state has no init process", func() {
+			writeState("foo", `{"id":"foo"}`)
+
+			_, err := runcClient.InitProcess("foo")
+			Expect(errors.Is(err, client.ErrInvalidState)).To(BeTrue())
+		})
+
+		Describe("DestroyContainerState", func() {
+			It("removes the state of the container", func() {
+				writeState("foo", `{}`)
+
+				Expect(runcClient.DestroyContainerState("foo")).To(Succeed())
+				Expect(filepath.Join(runcRoot, "foo")).NotTo(BeADirectory())
+			})
+		})
+	})
+
+	Describe("BundleSpec", func() {
+		var bundlesRoot string
+
+		BeforeEach(func() {
+			jobSpec = specs.Spec{
+				Version:  "example-version",
+				Hostname: "example",
+			}
+
+			var err error
+			bundlesRoot, err = ioutil.TempDir("", "bundle-spec")
+			Expect(err).ToNot(HaveOccurred())
+
+			bundlePath = filepath.Join(bundlesRoot, "bundle")
+			Expect(runcClient.CreateBundle(bundlePath, jobSpec, user)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(bundlesRoot)).To(Succeed())
+		})
+
+		It("returns the spec and its hash", func() {
+			spec, hash, err := runcClient.BundleSpec(bundlePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec).To(Equal(jobSpec))
+
+			expectedHash, err := client.SpecHash(jobSpec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal(expectedHash))
+		})
+
+		It("returns a different hash for a different spec", func() {
+			_, hash, err := runcClient.BundleSpec(bundlePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			jobSpec.Hostname = "other"
+			otherHash, err := client.SpecHash(jobSpec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).NotTo(Equal(otherHash))
+		})
+
+		It("returns the same hash whatever the order of the environment", func() {
+			jobSpec.Process = &specs.Process{Env: []string{"A=1", "B=2", "C=3"}}
+			hash, err := client.SpecHash(jobSpec)
+			Expect(err).NotTo(HaveOccurred())
+
+			jobSpec.Process.Env = []string{"C=3", "A=1", "B=2"}
+			again, err := client.SpecHash(jobSpec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again).To(Equal(hash))
+			Expect(jobSpec.Process.Env).To(Equal([]string{"C=3", "A=1", "B=2"}))
+		})
+
+		Context("when the bundle has no stored hash", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(bundlePath, "config.sha256"))).To(Succeed())
+			})
+
+			It("calculates the hash from the spec", func() {
+				_, hash, err := runcClient.BundleSpec(bundlePath)
+				Expect(err).NotTo(HaveOccurred())
+
+				expectedHash, err := client.SpecHash(jobSpec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hash).To(Equal(expectedHash))
+			})
+		})
+
+		Context("when the bundle does not exist", func() {
+			It("returns a not exist error", func() {
+				_, _, err := runcClient.BundleSpec(filepath.Join(bundlesRoot, "missing"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("RunContainer", func() {
+		var (
+			tempDir      string
+			fakeRuncPath string
+			logFilePath  string
+			stdout       *bytes.Buffer
+			stderr       *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeRuncPath = filepath.Join(tempDir, "fakeRunc")
+			logFilePath = filepath.Join(tempDir, "runc.log")
+			stdout = &bytes.Buffer{}
+			stderr = &bytes.Buffer{}
+
+			runcClient = client.NewRuncClient(fakeRuncPath, "/path/to/things")
+		})
+
+		AfterEach(func() {
+			err := os.RemoveAll(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when runc succeeds", func() {
+			BeforeEach(func() {
+				contents := []byte(`#!/bin/sh
+echo "$@"
+`)
+
+				err := ioutil.WriteFile(fakeRuncPath, contents, 0700)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("asks runc to log to the log file in json", func() {
+				status, err := runcClient.RunContainer("/pid/file", logFilePath, "/bundle", "container-id", true, stdout, stderr)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(status).To(Equal(0))
+
+				Expect(stdout.String()).To(Equal(fmt.Sprintf(
+					"--root /path/to/things --log %s --log-format json run --bundle /bundle --pid-file /pid/file --detach container-id\n",
+					logFilePath,
+				)))
+			})
+
+			It("removes any log file left behind by a previous run", func() {
+				err := ioutil.WriteFile(logFilePath, []byte(`{"level":"error","msg":"old"}`), 0600)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = runcClient.RunContainer("/pid/file", logFilePath, "/bundle", "container-id", true, stdout, stderr)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = os.Stat(logFilePath)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when runc fails and logs an error", func() {
+			BeforeEach(func() {
+				contents := []byte(`#!/bin/sh
+echo '{"level":"warning","msg":"something odd","time":"2018-04-09T17:23:01Z"}' >> "$4"
+echo '{"level":"error","msg":"container_linux.go:348: starting container process caused: exec: /bad: no such file or directory","time":"2018-04-09T17:23:01Z"}' >> "$4"
+echo 'not json' >> "$4"
+echo 'application output' >&2
+exit 1
+`)
+
+				err := ioutil.WriteFile(fakeRuncPath, contents, 0700)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns a runc error containing the logged message", func() {
+				status, err := runcClient.RunContainer("/pid/file", logFilePath, "/bundle", "container-id", true, stdout, stderr)
+				Expect(status).To(Equal(1))
+				Expect(err).To(BeAssignableToTypeOf(&client.RuncError{}))
+
+				runcErr := err.(*client.RuncError)
+				Expect(runcErr.Message).To(Equal("container_linux.go:348: starting container process caused: exec: /bad: no such file or directory"))
+				Expect(runcErr.Err.Error()).To(Equal("exit status 1"))
+				Expect(err.Error()).To(ContainSubstring("no such file or directory"))
+			})
+
+			It("does not write the runc log to stderr", func() {
+				runcClient.RunContainer("/pid/file", logFilePath, "/bundle", "container-id", true, stdout, stderr)
+				Expect(stderr.String()).To(Equal("application output\n"))
+			})
+		})
+
+		Context("when the process fails without runc logging an error", func() {
+			BeforeEach(func() {
+				contents := []byte(`#!/bin/sh
+exit 12
+`)
+
+				err := ioutil.WriteFile(fakeRuncPath, contents, 0700)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns the exit status and the original error", func() {
+				status, err := runcClient.RunContainer("/pid/file", logFilePath, "/bundle", "container-id", false, stdout, stderr)
+				Expect(status).To(Equal(12))
+				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(BeAssignableToTypeOf(&client.RuncError{}))
+			})
+		})
+	})
+
 	Describe("ContainerState", func() {
 		var (
 			tempDir      string
@@ -292,3 +563,21 @@ exit 1
 		})
 	})
 })
+
+var _ = Describe("ParseSignal", func() {
+	It("accepts signal names with or without the SIG prefix", func() {
+		signal, err := client.ParseSignal("HUP")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signal).To(Equal(client.Hup))
+
+		signal, err = client.ParseSignal("sigusr1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signal).To(Equal(client.Usr1))
+		Expect(signal.String()).To(Equal("USR1"))
+	})
+
+	It("returns an error for an unsupported signal", func() {
+		_, err := client.ParseSignal("WINCH")
+		Expect(err).To(MatchError("unsupported signal: WINCH"))
+	})
+})
@@ -17,18 +17,26 @@ package lifecycle
 
 import (
 	"errors"
-	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
 
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 
+	"bpm/cgroups"
 	"bpm/config"
+	"bpm/errs"
+	"bpm/events"
 	"bpm/models"
 	"bpm/runc/client"
 	"bpm/usertools"
@@ -37,6 +45,8 @@ import (
 const (
 	ContainerSigQuitGracePeriod = 2 * time.Second
 	ContainerStatePollInterval  = 1 * time.Second
+	ReadinessPollInterval       = 250 * time.Millisecond
+	StragglerExitTimeout        = 5 * time.Second
 
 	ContainerStateRunning = "running"
 	ContainerStatePaused  = "paused"
@@ -44,12 +54,14 @@ const (
 )
 
 var (
-	timeoutError    = errors.New("failed to stop job within timeout")
-	isNotExistError = errors.New("process is not running or could not be found")
+	timeoutError       = errs.New(errs.StopTimeout, "failed to stop job within timeout")
+	notReadyError      = errs.New(errs.NotReady, "process did not become ready within timeout")
+	cgroupTimeoutError = errs.New(errs.StopTimeout, "processes in the cgroup did not exit within timeout")
+	isNotExistError    = errs.New(errs.ProcessNotFound, "process is not running or could not be found")
 )
 
 func IsNotExist(err error) bool {
-	return err == isNotExistError
+	return errors.Is(err, isNotExistError)
 }
 
 //go:generate counterfeiter . UserFinder
@@ -64,33 +76,47 @@ type CommandRunner interface {
 	Run(*exec.Cmd) error
 }
 
+//go:generate counterfeiter . ExitWatcher
+
+type ExitWatcher interface {
+	Watch(pid int, stop <-chan struct{}) (<-chan struct{}, error)
+}
+
 //go:generate counterfeiter . RuncAdapter
 
 type RuncAdapter interface {
 	CreateJobPrerequisites(bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig, user specs.User) (*os.File, *os.File, error)
 	BuildSpec(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig, user specs.User) (specs.Spec, error)
+	RemoveSecrets(bpmCfg *config.BPMConfig) error
 }
 
 //go:generate counterfeiter . RuncClient
 
 type RuncClient interface {
 	CreateBundle(bundlePath string, jobSpec specs.Spec, user specs.User) error
-	RunContainer(pidFilePath, bundlePath, containerID string, detach bool, stdout, stderr io.Writer) (int, error)
+	BundleSpec(bundlePath string) (specs.Spec, string, error)
+	RunContainer(pidFilePath, logFilePath, bundlePath, containerID string, detach bool, stdout, stderr io.Writer) (int, error)
 	Exec(containerID, command string, stdin io.Reader, stdout, stderr io.Writer) error
 	ContainerState(containerID string) (*specs.State, error)
+	InitProcess(containerID string) (*client.InitProcess, error)
 	ListContainers() ([]client.ContainerState, error)
 	SignalContainer(containerID string, signal client.Signal) error
+	PauseContainer(containerID string) error
+	ResumeContainer(containerID string) error
 	DeleteContainer(containerID string) error
+	DestroyContainerState(containerID string) error
 	DestroyBundle(bundlePath string) error
 }
 
 type RuncLifecycle struct {
 	clock         clock.Clock
 	commandRunner CommandRunner
+	exitWatcher   ExitWatcher
 	runcAdapter   RuncAdapter
 	runcClient    RuncClient
 	userFinder    UserFinder
 	deleteFile    func(string) error
+	journal       *events.Journal
 }
 
 func NewRuncLifecycle(
@@ -98,8 +124,10 @@ func NewRuncLifecycle(
 	runcAdapter RuncAdapter,
 	userFinder UserFinder,
 	commandRunner CommandRunner,
+	exitWatcher ExitWatcher,
 	clock clock.Clock,
 	deleteFile func(string) error,
+	journal *events.Journal,
 ) *RuncLifecycle {
 	return &RuncLifecycle{
 		clock:         clock,
@@ -107,7 +135,9 @@ func NewRuncLifecycle(
 		runcAdapter:   runcAdapter,
 		userFinder:    userFinder,
 		commandRunner: commandRunner,
+		exitWatcher:   exitWatcher,
 		deleteFile:    deleteFile,
+		journal:       journal,
 	}
 }
 
@@ -126,14 +156,21 @@ func (j *RuncLifecycle) StartProcess(logger lager.Logger, bpmCfg *config.BPMConf
 	logger.Info("running-container")
 	_, err = j.runcClient.RunContainer(
 		bpmCfg.PidFile(),
+		bpmCfg.RuncLog(),
 		bpmCfg.BundlePath(),
 		bpmCfg.ContainerID(),
 		true,
 		stdout,
 		stderr,
 	)
+	logRuncError(logger, err)
+	if err != nil {
+		return errs.New(errs.RuntimeFailure, "%w", err)
+	}
+
+	j.record(logger, bpmCfg, events.Event{Type: events.Started, Pid: readPid(bpmCfg.PidFile())})
 
-	return err
+	return nil
 }
 
 func (j *RuncLifecycle) RunProcess(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) (int, error) {
@@ -149,14 +186,39 @@ func (j *RuncLifecycle) RunProcess(logger lager.Logger, bpmCfg *config.BPMConfig
 	defer stderr.Close()
 
 	logger.Info("running-container")
-	return j.runcClient.RunContainer(
+	status, err := j.runcClient.RunContainer(
 		bpmCfg.PidFile(),
+		bpmCfg.RuncLog(),
 		bpmCfg.BundlePath(),
 		bpmCfg.ContainerID(),
 		false,
 		io.MultiWriter(stdout, os.Stdout),
 		io.MultiWriter(stderr, os.Stderr),
 	)
+	if runcErr := logRuncError(logger, err); runcErr != nil {
+		return status, errs.New(errs.RuntimeFailure, "%w", runcErr)
+	}
+
+	// The container ran unless runc or bpm failed and so its exit status is
+	// recorded whether or not it succeeded.
+	var exitErr *exec.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		j.record(logger, bpmCfg, events.Event{Type: events.Exited, ExitStatus: &status})
+	}
+
+	return status, err
+}
+
+// logRuncError records the reason runc gave for failing (if any) in the bpm
+// log. It returns the runc error so that callers can classify it.
+func logRuncError(logger lager.Logger, err error) *client.RuncError {
+	var runcErr *client.RuncError
+	if !errors.As(err, &runcErr) {
+		return nil
+	}
+
+	logger.Error("runc-failed", runcErr.Err, lager.Data{"runc-error": runcErr.Message})
+	return runcErr
 }
 
 func (j *RuncLifecycle) setupProcess(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) (io.WriteCloser, io.WriteCloser, error) {
@@ -168,19 +230,19 @@ func (j *RuncLifecycle) setupProcess(logger lager.Logger, bpmCfg *config.BPMConf
 	logger.Info("creating-job-prerequisites")
 	stdout, stderr, err := j.runcAdapter.CreateJobPrerequisites(bpmCfg, procCfg, user)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create system files: %s", err.Error())
+		return nil, nil, errs.New(errs.RuntimeFailure, "failed to create system files: %w", err)
 	}
 
 	logger.Info("building-spec")
 	spec, err := j.runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, errs.New(errs.ConfigInvalid, "%w", err)
 	}
 
 	logger.Info("creating-bundle")
 	err = j.runcClient.CreateBundle(bpmCfg.BundlePath(), spec, user)
 	if err != nil {
-		return nil, nil, fmt.Errorf("bundle build failure: %s", err.Error())
+		return nil, nil, errs.New(errs.RuntimeFailure, "bundle build failure: %w", err)
 	}
 
 	if procCfg.Hooks != nil {
@@ -190,14 +252,72 @@ func (j *RuncLifecycle) setupProcess(logger lager.Logger, bpmCfg *config.BPMConf
 		preStartCmd.Stderr = stderr
 
 		err := j.commandRunner.Run(preStartCmd)
+
+		hookRan := events.Event{Type: events.HookRan, Hook: procCfg.Hooks.PreStart}
 		if err != nil {
-			return nil, nil, fmt.Errorf("prestart hook failed: %s", err.Error())
+			hookRan.Error = err.Error()
+		}
+		j.record(logger, bpmCfg, hookRan)
+
+		if err != nil {
+			return nil, nil, errs.New(errs.HookFailure, "prestart hook failed: %w", err)
 		}
 	}
 
 	return stdout, stderr, nil
 }
 
+// DesiredSpec builds the spec which the process would be started with using
+// its current configuration. It does not change anything on the system.
+func (j *RuncLifecycle) DesiredSpec(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) (specs.Spec, error) {
+	user, err := j.userFinder.Lookup(usertools.VcapUser)
+	if err != nil {
+		return specs.Spec{}, err
+	}
+
+	spec, err := j.runcAdapter.BuildSpec(logger, bpmCfg, procCfg, user)
+	if err != nil {
+		return specs.Spec{}, errs.New(errs.ConfigInvalid, "%w", err)
+	}
+
+	return spec, nil
+}
+
+// RunningSpec returns the spec which the process was last started with.
+func (j *RuncLifecycle) RunningSpec(bpmCfg *config.BPMConfig) (specs.Spec, error) {
+	spec, _, err := j.runcClient.BundleSpec(bpmCfg.BundlePath())
+	if os.IsNotExist(err) {
+		return specs.Spec{}, isNotExistError
+	} else if err != nil {
+		return specs.Spec{}, err
+	}
+
+	return spec, nil
+}
+
+// SpecChanged reports whether the spec built from the current configuration
+// of the process differs from the spec which it was last started with.
+func (j *RuncLifecycle) SpecChanged(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) (bool, error) {
+	_, runningHash, err := j.runcClient.BundleSpec(bpmCfg.BundlePath())
+	if os.IsNotExist(err) {
+		return false, isNotExistError
+	} else if err != nil {
+		return false, err
+	}
+
+	desired, err := j.DesiredSpec(logger, bpmCfg, procCfg)
+	if err != nil {
+		return false, err
+	}
+
+	desiredHash, err := client.SpecHash(desired)
+	if err != nil {
+		return false, err
+	}
+
+	return desiredHash != runningHash, nil
+}
+
 func (j *RuncLifecycle) StatProcess(cfg *config.BPMConfig) (*models.Process, error) {
 	container, err := j.runcClient.ContainerState(cfg.ContainerID())
 	if err != nil {
@@ -237,33 +357,101 @@ func (j *RuncLifecycle) ListProcesses() ([]*models.Process, error) {
 	return processes, nil
 }
 
+// SignalProcess sends a signal to the init process of a container.
+func (j *RuncLifecycle) SignalProcess(logger lager.Logger, cfg *config.BPMConfig, signal client.Signal) error {
+	logger.Info("signalling-container", lager.Data{"signal": signal.String()})
+	if err := j.runcClient.SignalContainer(cfg.ContainerID(), signal); err != nil {
+		return err
+	}
+
+	j.record(logger, cfg, events.Event{Type: events.SignalSent, Signal: signal.String()})
+	return nil
+}
+
+// PauseProcess freezes every process in the container of a process. The
+// processes keep their state and can be inspected until they are resumed.
+func (j *RuncLifecycle) PauseProcess(logger lager.Logger, cfg *config.BPMConfig) error {
+	logger.Info("pausing-container")
+	if err := j.runcClient.PauseContainer(cfg.ContainerID()); err != nil {
+		return err
+	}
+
+	j.record(logger, cfg, events.Event{Type: events.Paused})
+	return nil
+}
+
+// ResumeProcess thaws every process in the container of a process which has
+// been paused.
+func (j *RuncLifecycle) ResumeProcess(logger lager.Logger, cfg *config.BPMConfig) error {
+	logger.Info("resuming-container")
+	if err := j.runcClient.ResumeContainer(cfg.ContainerID()); err != nil {
+		return err
+	}
+
+	j.record(logger, cfg, events.Event{Type: events.Resumed})
+	return nil
+}
+
+// StopProcess sends SIGTERM to a process and waits for it to exit. The init
+// process of the container is watched so that this returns as soon as it
+// exits. If the kernel cannot watch it then its state is polled instead. If
+// the process does not exit within the timeout then it is sent SIGQUIT so
+// that it can dump its state before it is killed.
 func (j *RuncLifecycle) StopProcess(logger lager.Logger, cfg *config.BPMConfig, exitTimeout time.Duration) error {
+	var exited <-chan struct{}
+
+	// The init process is watched before it is signalled. Once it has
+	// exited its pid can be reused and so it cannot be looked up afterwards.
+	state, stateErr := j.runcClient.ContainerState(cfg.ContainerID())
+	if stateErr != nil {
+		logger.Error("failed-to-fetch-state", stateErr)
+	} else if state.Status != ContainerStateStopped {
+		stopWatching := make(chan struct{})
+		defer close(stopWatching)
+
+		var err error
+		exited, err = j.exitWatcher.Watch(state.Pid, stopWatching)
+		if err != nil {
+			logger.Info("polling-for-exit", lager.Data{"reason": err.Error()})
+			exited = nil
+		}
+	}
+
 	err := j.runcClient.SignalContainer(cfg.ContainerID(), client.Term)
 	if err != nil {
 		return err
 	}
+	j.record(logger, cfg, events.Event{Type: events.SignalSent, Signal: client.Term.String()})
 
-	state, err := j.runcClient.ContainerState(cfg.ContainerID())
-	if err != nil {
-		logger.Error("failed-to-fetch-state", err)
-	} else {
-		if state.Status == ContainerStateStopped {
-			return nil
-		}
+	if stateErr == nil && state.Status == ContainerStateStopped {
+		j.record(logger, cfg, events.Event{Type: events.Exited, Pid: state.Pid})
+		return nil
 	}
 
 	timeout := j.clock.NewTimer(exitTimeout)
-	stateTicker := j.clock.NewTicker(ContainerStatePollInterval)
-	defer stateTicker.Stop()
+	defer timeout.Stop()
+
+	// A nil channel is never ready and so the state is only polled when the
+	// process is not being watched.
+	var poll <-chan time.Time
+	if exited == nil {
+		stateTicker := j.clock.NewTicker(ContainerStatePollInterval)
+		defer stateTicker.Stop()
+		poll = stateTicker.C()
+	}
 
 	for {
 		select {
-		case <-stateTicker.C():
+		case <-exited:
+			j.record(logger, cfg, events.Event{Type: events.Exited, Pid: state.Pid})
+			return nil
+		case <-poll:
 			state, err = j.runcClient.ContainerState(cfg.ContainerID())
 			if err != nil {
 				logger.Error("failed-to-fetch-state", err)
 			} else {
 				if state.Status == ContainerStateStopped {
+					j.record(logger, cfg, events.Event{Type: events.Exited, Pid: state.Pid})
 					return nil
 				}
 			}
@@ -271,6 +459,8 @@ func (j *RuncLifecycle) StopProcess(logger lager.Logger, cfg *config.BPMConfig,
 			err := j.runcClient.SignalContainer(cfg.ContainerID(), client.Quit)
 			if err != nil {
 				logger.Error("failed-to-sigquit", err)
+			} else {
+				j.record(logger, cfg, events.Event{Type: events.SignalSent, Signal: client.Quit.String()})
 			}
 
 			j.clock.Sleep(ContainerSigQuitGracePeriod)
@@ -279,7 +469,91 @@ func (j *RuncLifecycle) StopProcess(logger lager.Logger, cfg *config.BPMConfig,
 	}
 }
 
+// WaitForReady waits until the readiness condition of a process is true or
+// its timeout expires.
+func (j *RuncLifecycle) WaitForReady(logger lager.Logger, ready *config.Readiness) error {
+	if isReady(ready) {
+		return nil
+	}
+
+	timeout := j.clock.NewTimer(ready.TimeoutDuration())
+	defer timeout.Stop()
+	readyTicker := j.clock.NewTicker(ReadinessPollInterval)
+	defer readyTicker.Stop()
+
+	for {
+		select {
+		case <-readyTicker.C():
+			if isReady(ready) {
+				return nil
+			}
+		case <-timeout.C():
+			logger.Info("timed-out-waiting-for-readiness", lager.Data{"path": ready.Path, "port": ready.Port})
+			return notReadyError
+		}
+	}
+}
+
+// WaitForCgroupEmpty waits until every process in a cgroup has exited or the
+// timeout expires. The cgroup of a process can outlive its container if any
+// of the processes in it are slow to exit.
+func (j *RuncLifecycle) WaitForCgroupEmpty(logger lager.Logger, cgroup string, timeout time.Duration) error {
+	pids, err := cgroups.Procs(cgroup)
+	if err != nil {
+		return err
+	}
+
+	if len(pids) == 0 {
+		return nil
+	}
+
+	timer := j.clock.NewTimer(timeout)
+	defer timer.Stop()
+	emptyTicker := j.clock.NewTicker(ContainerStatePollInterval)
+	defer emptyTicker.Stop()
+
+	for {
+		select {
+		case <-emptyTicker.C():
+			pids, err = cgroups.Procs(cgroup)
+			if err != nil {
+				return err
+			}
+
+			if len(pids) == 0 {
+				return nil
+			}
+		case <-timer.C():
+			logger.Info("timed-out-waiting-for-cgroup", lager.Data{"cgroup": cgroup, "remaining": len(pids)})
+			return cgroupTimeoutError
+		}
+	}
+}
+
+func isReady(ready *config.Readiness) bool {
+	if ready.Path != "" {
+		_, err := os.Stat(ready.Path)
+		return err == nil
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(ready.Port)), ReadinessPollInterval)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
+
 func (j *RuncLifecycle) RemoveProcess(logger lager.Logger, cfg *config.BPMConfig) error {
+	// runc kills anything left in the container when it is deleted and so
+	// the container is still removed if the stragglers could not be killed.
+	// The error is returned once it has been.
+	stragglerErr := j.killStragglers(logger, cfg)
+	if stragglerErr != nil {
+		logger.Error("failed-to-kill-stragglers", stragglerErr)
+	}
+
 	logger.Info("forcefully-deleting-container")
 	if err := j.runcClient.DeleteContainer(cfg.ContainerID()); err != nil {
 		return err
@@ -290,13 +564,254 @@ func (j *RuncLifecycle) RemoveProcess(logger lager.Logger, cfg *config.BPMConfig
 		return err
 	}
 
+	logger.Info("removing-secrets")
+	if err := j.runcAdapter.RemoveSecrets(cfg); err != nil {
+		return err
+	}
+
 	logger.Info("deleting-pidfile")
-	return j.deleteFile(cfg.PidFile())
+	if err := j.deleteFile(cfg.PidFile()); err != nil {
+		return err
+	}
+
+	j.record(logger, cfg, events.Event{Type: events.Removed})
+	return stragglerErr
+}
+
+// killStragglers kills every process left in the cgroup of a container and
+// waits for them to exit. Processes which daemonized themselves can outlive
+// the init process of the container and would otherwise race with the next
+// start of the process (e.g. for its ports). The cgroup is frozen while they
+// are killed so that none of them can fork.
+func (j *RuncLifecycle) killStragglers(logger lager.Logger, cfg *config.BPMConfig) error {
+	cgroup, err := j.ContainerCgroup(cfg)
+	if err != nil {
+		logger.Error("failed-to-find-cgroup", err)
+		return err
+	}
+
+	if cgroup == "" {
+		return nil
+	}
+
+	pids, err := cgroups.Procs(cgroup)
+	if err != nil || len(pids) == 0 {
+		return err
+	}
+
+	if err := cgroups.Freeze(cgroup); err != nil {
+		logger.Error("failed-to-freeze-cgroup", err)
+
+		// The cgroup is left freezing if it did not finish in time and
+		// its processes could not handle SIGKILL.
+		thaw(logger, cgroup)
+		return j.killUntilEmpty(logger, cgroup, StragglerExitTimeout)
+	}
+
+	if err := killFrozen(logger, cgroup); err != nil {
+		return err
+	}
+
+	return j.WaitForCgroupEmpty(logger, cgroup, StragglerExitTimeout)
+}
+
+// killFrozen kills every process in a frozen cgroup. The cgroup is thawed
+// afterwards, whether or not they could be listed, so that they can exit.
+func killFrozen(logger lager.Logger, cgroup string) error {
+	defer thaw(logger, cgroup)
+
+	// Processes may have forked or exited before the cgroup was frozen.
+	pids, err := cgroups.Procs(cgroup)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("killing-stragglers", lager.Data{"count": len(pids)})
+	killAll(logger, pids)
+
+	return nil
+}
+
+func thaw(logger lager.Logger, cgroup string) {
+	if err := cgroups.Thaw(cgroup); err != nil {
+		logger.Error("failed-to-thaw-cgroup", err)
+	}
+}
+
+// killUntilEmpty kills the processes in a cgroup which could not be frozen
+// until none are left. The processes can fork while they are being killed
+// and so the cgroup is read again after each round. It is only polled once a
+// round finds no processes which have not already been killed.
+func (j *RuncLifecycle) killUntilEmpty(logger lager.Logger, cgroup string, timeout time.Duration) error {
+	timer := j.clock.NewTimer(timeout)
+	defer timer.Stop()
+	emptyTicker := j.clock.NewTicker(ContainerStatePollInterval)
+	defer emptyTicker.Stop()
+
+	killed := map[int]bool{}
+
+	for {
+		pids, err := cgroups.Procs(cgroup)
+		if err != nil {
+			return err
+		}
+
+		if len(pids) == 0 {
+			return nil
+		}
+
+		var found []int
+		for _, pid := range pids {
+			if !killed[pid] {
+				killed[pid] = true
+				found = append(found, pid)
+			}
+		}
+
+		if len(found) > 0 {
+			logger.Info("killing-stragglers", lager.Data{"count": len(found)})
+			killAll(logger, found)
+
+			// The cgroup is read again straight away while new
+			// processes keep appearing in it.
+			select {
+			case <-timer.C():
+			default:
+				continue
+			}
+		} else {
+			select {
+			case <-emptyTicker.C():
+				// A process which is stuck in the kernel may not have
+				// handled SIGKILL yet and so it is sent again.
+				killed = map[int]bool{}
+				continue
+			case <-timer.C():
+			}
+		}
+
+		logger.Info("timed-out-waiting-for-cgroup", lager.Data{"cgroup": cgroup, "remaining": len(pids)})
+		return cgroupTimeoutError
+	}
+}
+
+func killAll(logger lager.Logger, pids []int) {
+	for _, pid := range pids {
+		if err := unix.Kill(pid, unix.SIGKILL); err != nil && err != unix.ESRCH {
+			logger.Error("failed-to-kill-straggler", err, lager.Data{"pid": pid})
+		}
+	}
+}
+
+// OOMKilled reports whether the kernel has killed any process in a container
+// because it ran out of memory. The kills are counted in the memory cgroup of
+// the container and so it must be called before the container is removed.
+func (j *RuncLifecycle) OOMKilled(cfg *config.BPMConfig) (bool, error) {
+	init, err := j.runcClient.InitProcess(cfg.ContainerID())
+	if err != nil || init == nil {
+		return false, err
+	}
+
+	cgroup, ok := init.Cgroups["memory"]
+	if !ok {
+		cgroup = init.Cgroups[""]
+	}
+
+	if cgroup == "" {
+		return false, nil
+	}
+
+	kills, err := cgroups.OOMKills(cgroup)
+	return kills > 0, err
+}
+
+// ContainerCgroup returns the cgroup which every process of a container is in
+// or an empty string if the container does not exist. It is read from the
+// state of the container and so it can still be found once the init process
+// of the container has exited. If the state cannot be read then the cgroup
+// of the process in the pidfile is used instead.
+func (j *RuncLifecycle) ContainerCgroup(cfg *config.BPMConfig) (string, error) {
+	init, err := j.runcClient.InitProcess(cfg.ContainerID())
+	if err != nil {
+		if cgroup := pidFileCgroup(cfg); cgroup != "" {
+			return cgroup, nil
+		}
+
+		return "", err
+	}
+
+	if init == nil {
+		return "", nil
+	}
+
+	return freezerCgroup(init.Cgroups), nil
+}
+
+// pidFileCgroup returns the cgroup which can be frozen of the process in the
+// pidfile of a container. The pid may have been reused by a process outside
+// of the container and so the cgroup is only returned if it is named after
+// the container.
+func pidFileCgroup(cfg *config.BPMConfig) string {
+	pid := readPid(cfg.PidFile())
+	if pid <= 0 {
+		return ""
+	}
+
+	cgroup, err := cgroups.ProcessCgroup(pid, "freezer")
+	if err != nil {
+		cgroup, err = cgroups.ProcessCgroup(pid, "")
+	}
+
+	if err != nil || filepath.Base(cgroup) != cfg.ContainerID() {
+		return ""
+	}
+
+	return cgroup
+}
+
+// freezerCgroup returns the cgroup of a container which can be frozen: its
+// freezer cgroup with cgroups v1 or its only cgroup with cgroups v2.
+func freezerCgroup(paths map[string]string) string {
+	if cgroup, ok := paths["freezer"]; ok {
+		return cgroup
+	}
+
+	return paths[""]
+}
+
+// record adds an event about a process to the journal. Failing to record an
+// event does not fail the operation which it describes.
+func (j *RuncLifecycle) record(logger lager.Logger, cfg *config.BPMConfig, e events.Event) {
+	e.Job = cfg.JobName()
+	e.Process = cfg.ProcName()
+
+	if err := j.journal.Record(e); err != nil {
+		logger.Error("failed-to-record-event", err, lager.Data{"event": e.Type})
+	}
+}
+
+// readPid reads the pid from a pidfile. It returns 0 if the pid cannot be
+// read.
+func readPid(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
 }
 
 func newProcessFromContainerState(id, status string, pid int) *models.Process {
-	if status == ContainerStateStopped {
-		status = "failed"
+	switch status {
+	case ContainerStateStopped:
+		status = models.ProcessStateFailed
+	case ContainerStatePaused:
+		status = models.ProcessStatePaused
 	}
 
 	return &models.Process{
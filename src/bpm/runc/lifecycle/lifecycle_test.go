@@ -19,9 +19,13 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -35,6 +39,8 @@ import (
 	"github.com/onsi/gomega/gbytes"
 
 	"bpm/config"
+	"bpm/errs"
+	"bpm/events"
 	"bpm/models"
 	"bpm/runc/client"
 	"bpm/runc/lifecycle"
@@ -48,6 +54,7 @@ var _ = Describe("RuncJobLifecycle", func() {
 		fakeRuncClient    *lifecyclefakes.FakeRuncClient
 		fakeUserFinder    *lifecyclefakes.FakeUserFinder
 		fakeCommandRunner *lifecyclefakes.FakeCommandRunner
+		fakeExitWatcher   *lifecyclefakes.FakeExitWatcher
 		fakeFileRemover   *fileRemover
 
 		logger *lagertest.TestLogger
@@ -67,15 +74,26 @@ var _ = Describe("RuncJobLifecycle", func() {
 
 		fakeClock *fakeclock.FakeClock
 
+		journalDir string
+		journal    *events.Journal
+
 		runcLifecycle *lifecycle.RuncLifecycle
 	)
 
+	recorded := func() []events.Event {
+		recorded, err := journal.Read(events.Filter{})
+		Expect(err).NotTo(HaveOccurred())
+		return recorded
+	}
+
 	BeforeEach(func() {
 		fakeClock = fakeclock.NewFakeClock(time.Now())
 		fakeRuncAdapter = &lifecyclefakes.FakeRuncAdapter{}
 		fakeRuncClient = &lifecyclefakes.FakeRuncClient{}
 		fakeUserFinder = &lifecyclefakes.FakeUserFinder{}
 		fakeCommandRunner = &lifecyclefakes.FakeCommandRunner{}
+		fakeExitWatcher = &lifecyclefakes.FakeExitWatcher{}
+		fakeExitWatcher.WatchReturns(nil, errors.New("watching processes is not supported by the kernel"))
 		fakeFileRemover = &fileRemover{}
 
 		logger = lagertest.NewTestLogger("lifecycle")
@@ -107,13 +125,19 @@ var _ = Describe("RuncJobLifecycle", func() {
 
 		expectedSystemRoot = "system-root"
 
+		journalDir, err = ioutil.TempDir("", "runc-lifecycle-journal")
+		Expect(err).NotTo(HaveOccurred())
+		journal = events.NewJournal(filepath.Join(journalDir, "events.log"), fakeClock)
+
 		runcLifecycle = lifecycle.NewRuncLifecycle(
 			fakeRuncClient,
 			fakeRuncAdapter,
 			fakeUserFinder,
 			fakeCommandRunner,
+			fakeExitWatcher,
 			fakeClock,
 			fakeFileRemover.Remove,
+			journal,
 		)
 		bpmCfg = config.NewBPMConfig(expectedSystemRoot, expectedJobName, expectedProcName)
 	})
@@ -121,6 +145,7 @@ var _ = Describe("RuncJobLifecycle", func() {
 	AfterEach(func() {
 		Expect(os.RemoveAll(expectedStdout.Name())).To(Succeed())
 		Expect(os.RemoveAll(expectedStderr.Name())).To(Succeed())
+		Expect(os.RemoveAll(journalDir)).To(Succeed())
 	})
 
 	var ItSetsUpAndRunsAProcess = func(run func(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) error) {
@@ -144,14 +169,34 @@ var _ = Describe("RuncJobLifecycle", func() {
 				Expect(fakeCommandRunner.RunArgsForCall(0)).To(Equal(expectedCommand))
 			})
 
+			It("records that the hook ran in the journal", func() {
+				err := run(logger, bpmCfg, procCfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				e := recorded()[0]
+				Expect(e.Type).To(Equal(events.HookRan))
+				Expect(e.Job).To(Equal(expectedJobName))
+				Expect(e.Process).To(Equal(expectedProcName))
+				Expect(e.Hook).To(Equal("/please/execute/me"))
+				Expect(e.Error).To(BeEmpty())
+			})
+
 			Context("when the PreStart Hook fails", func() {
 				BeforeEach(func() {
 					fakeCommandRunner.RunReturns(errors.New("fake test error"))
 				})
 
-				It("returns an error", func() {
+				It("returns a hook failure", func() {
 					err := run(logger, bpmCfg, procCfg)
 					Expect(err).To(HaveOccurred())
+					Expect(errors.Is(err, errs.HookFailure)).To(BeTrue())
+				})
+
+				It("records the failure in the journal", func() {
+					run(logger, bpmCfg, procCfg)
+
+					Expect(recorded()).To(HaveLen(1))
+					Expect(recorded()[0].Error).To(Equal("fake test error"))
 				})
 			})
 		})
@@ -179,7 +224,7 @@ var _ = Describe("RuncJobLifecycle", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(fakeRuncClient.RunContainerCallCount()).To(Equal(1))
-				_, _, cid, _, _, _ := fakeRuncClient.RunContainerArgsForCall(0)
+				_, _, _, cid, _, _, _ := fakeRuncClient.RunContainerArgsForCall(0)
 				Expect(cid).To(Equal(config.Encode(expectedJobName)))
 			})
 		})
@@ -257,8 +302,9 @@ var _ = Describe("RuncJobLifecycle", func() {
 			Expect(user).To(Equal(expectedUser))
 
 			Expect(fakeRuncClient.RunContainerCallCount()).To(Equal(1))
-			pidFilePath, bundlePath, cid, detach, stdout, stderr := fakeRuncClient.RunContainerArgsForCall(0)
+			pidFilePath, logFilePath, bundlePath, cid, detach, stdout, stderr := fakeRuncClient.RunContainerArgsForCall(0)
 			Expect(pidFilePath).To(Equal(bpmCfg.PidFile()))
+			Expect(logFilePath).To(Equal(bpmCfg.RuncLog()))
 			Expect(bundlePath).To(Equal(filepath.Join(expectedSystemRoot, "data", "bpm", "bundles", expectedJobName, expectedProcName)))
 			Expect(cid).To(Equal(expectedContainerID))
 			Expect(detach).To(BeTrue())
@@ -271,9 +317,26 @@ var _ = Describe("RuncJobLifecycle", func() {
 				fakeRuncClient.RunContainerReturns(1, errors.New("fake test error"))
 			})
 
-			It("returns an error", func() {
+			It("returns a runtime failure", func() {
 				err := runcLifecycle.StartProcess(logger, bpmCfg, procCfg)
 				Expect(err).To(HaveOccurred())
+				Expect(errors.Is(err, errs.RuntimeFailure)).To(BeTrue())
+			})
+		})
+
+		Context("when runc logs why it failed", func() {
+			BeforeEach(func() {
+				fakeRuncClient.RunContainerReturns(1, &client.RuncError{
+					Message: "no such file or directory",
+					Err:     errors.New("exit status 1"),
+				})
+			})
+
+			It("records the runc error in the bpm log", func() {
+				err := runcLifecycle.StartProcess(logger, bpmCfg, procCfg)
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				Expect(logger).To(gbytes.Say("runc-failed"))
+				Expect(logger).To(gbytes.Say("no such file or directory"))
 			})
 		})
 
@@ -310,8 +373,9 @@ var _ = Describe("RuncJobLifecycle", func() {
 			Expect(user).To(Equal(expectedUser))
 
 			Expect(fakeRuncClient.RunContainerCallCount()).To(Equal(1))
-			pidFilePath, bundlePath, cid, detach, _, _ := fakeRuncClient.RunContainerArgsForCall(0)
+			pidFilePath, logFilePath, bundlePath, cid, detach, _, _ := fakeRuncClient.RunContainerArgsForCall(0)
 			Expect(pidFilePath).To(Equal(bpmCfg.PidFile()))
+			Expect(logFilePath).To(Equal(bpmCfg.RuncLog()))
 			Expect(bundlePath).To(Equal(filepath.Join(expectedSystemRoot, "data", "bpm", "bundles", expectedJobName, expectedProcName)))
 			Expect(cid).To(Equal(expectedContainerID))
 			Expect(detach).To(BeFalse())
@@ -325,8 +389,48 @@ var _ = Describe("RuncJobLifecycle", func() {
 			It("returns an error", func() {
 				status, err := runcLifecycle.RunProcess(logger, bpmCfg, procCfg)
 				Expect(err).To(HaveOccurred())
+				Expect(errors.Is(err, errs.RuntimeFailure)).To(BeFalse())
+				Expect(status).To(Equal(1))
+			})
+		})
+
+		Context("when the process exits unsuccessfully", func() {
+			BeforeEach(func() {
+				exitErr := exec.Command("sh", "-c", "exit 3").Run()
+				fakeRuncClient.RunContainerReturns(3, exitErr)
+			})
+
+			It("records its exit status in the journal", func() {
+				status, err := runcLifecycle.RunProcess(logger, bpmCfg, procCfg)
+				Expect(err).To(HaveOccurred())
+				Expect(status).To(Equal(3))
+
+				Expect(recorded()).To(HaveLen(1))
+				Expect(recorded()[0].Type).To(Equal(events.Exited))
+				Expect(*recorded()[0].ExitStatus).To(Equal(3))
+			})
+		})
+
+		Context("when runc itself fails", func() {
+			BeforeEach(func() {
+				fakeRuncClient.RunContainerReturns(1, &client.RuncError{
+					Message: "no such file or directory",
+					Err:     errors.New("exit status 1"),
+				})
+			})
+
+			It("returns a runtime failure", func() {
+				status, err := runcLifecycle.RunProcess(logger, bpmCfg, procCfg)
+				Expect(errors.Is(err, errs.RuntimeFailure)).To(BeTrue())
 				Expect(status).To(Equal(1))
 			})
+
+			It("does not record an exit", func() {
+				_, err := runcLifecycle.RunProcess(logger, bpmCfg, procCfg)
+				Expect(err).To(HaveOccurred())
+
+				Expect(recorded()).To(BeEmpty())
+			})
 		})
 
 		ItSetsUpAndRunsAProcess(func(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) error {
@@ -336,6 +440,81 @@ var _ = Describe("RuncJobLifecycle", func() {
 		})
 	})
 
+	Describe("SignalProcess", func() {
+		It("signals the container", func() {
+			err := runcLifecycle.SignalProcess(logger, bpmCfg, client.Hup)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuncClient.SignalContainerCallCount()).To(Equal(1))
+			cid, signal := fakeRuncClient.SignalContainerArgsForCall(0)
+			Expect(cid).To(Equal(expectedContainerID))
+			Expect(signal).To(Equal(client.Hup))
+		})
+
+		It("records the signal in the journal", func() {
+			err := runcLifecycle.SignalProcess(logger, bpmCfg, client.Hup)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorded()).To(HaveLen(1))
+			Expect(recorded()[0].Type).To(Equal(events.SignalSent))
+			Expect(recorded()[0].Signal).To(Equal("HUP"))
+		})
+
+		Context("when signalling the container fails", func() {
+			It("returns the error", func() {
+				fakeRuncClient.SignalContainerReturns(errors.New("boom"))
+
+				err := runcLifecycle.SignalProcess(logger, bpmCfg, client.Hup)
+				Expect(err).To(MatchError("boom"))
+			})
+		})
+	})
+
+	Describe("PauseProcess", func() {
+		It("pauses the container", func() {
+			err := runcLifecycle.PauseProcess(logger, bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuncClient.PauseContainerCallCount()).To(Equal(1))
+			Expect(fakeRuncClient.PauseContainerArgsForCall(0)).To(Equal(expectedContainerID))
+
+			Expect(recorded()).To(HaveLen(1))
+			Expect(recorded()[0].Type).To(Equal(events.Paused))
+		})
+
+		Context("when pausing the container fails", func() {
+			It("returns the error", func() {
+				fakeRuncClient.PauseContainerReturns(errors.New("boom"))
+
+				err := runcLifecycle.PauseProcess(logger, bpmCfg)
+				Expect(err).To(MatchError("boom"))
+				Expect(recorded()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("ResumeProcess", func() {
+		It("resumes the container", func() {
+			err := runcLifecycle.ResumeProcess(logger, bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuncClient.ResumeContainerCallCount()).To(Equal(1))
+			Expect(fakeRuncClient.ResumeContainerArgsForCall(0)).To(Equal(expectedContainerID))
+
+			Expect(recorded()).To(HaveLen(1))
+			Expect(recorded()[0].Type).To(Equal(events.Resumed))
+		})
+
+		Context("when resuming the container fails", func() {
+			It("returns the error", func() {
+				fakeRuncClient.ResumeContainerReturns(errors.New("boom"))
+
+				err := runcLifecycle.ResumeProcess(logger, bpmCfg)
+				Expect(err).To(MatchError("boom"))
+			})
+		})
+	})
+
 	Describe("StopProcess", func() {
 		var exitTimeout time.Duration
 
@@ -360,6 +539,22 @@ var _ = Describe("RuncJobLifecycle", func() {
 			Expect(signal).To(Equal(client.Term))
 		})
 
+		It("records the signal and the exit in the journal", func() {
+			fakeRuncClient.ContainerStateReturns(&specs.State{
+				Status: "stopped",
+				Pid:    42,
+			}, nil)
+
+			err := runcLifecycle.StopProcess(logger, bpmCfg, exitTimeout)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorded()).To(HaveLen(2))
+			Expect(recorded()[0].Type).To(Equal(events.SignalSent))
+			Expect(recorded()[0].Signal).To(Equal("TERM"))
+			Expect(recorded()[1].Type).To(Equal(events.Exited))
+			Expect(recorded()[1].Pid).To(Equal(42))
+		})
+
 		Context("when the container does not stop immediately", func() {
 			var stopped chan struct{}
 
@@ -433,11 +628,90 @@ var _ = Describe("RuncJobLifecycle", func() {
 					Expect(cid).To(Equal(expectedContainerID))
 					Expect(signal).To(Equal(client.Quit))
 
-					fakeClock.WaitForWatcherAndIncrement(lifecycle.ContainerSigQuitGracePeriod)
+					fakeClock.WaitForNWatchersAndIncrement(lifecycle.ContainerSigQuitGracePeriod, 2)
 
 					var actualError error
 					Eventually(errChan).Should(Receive(&actualError))
 					Expect(actualError).To(MatchError("failed to stop job within timeout"))
+					Expect(errors.Is(actualError, errs.StopTimeout)).To(BeTrue())
+				})
+			})
+		})
+
+		Context("when the init process of the container can be watched", func() {
+			var exited chan struct{}
+
+			BeforeEach(func() {
+				exited = make(chan struct{})
+				fakeRuncClient.ContainerStateReturns(&specs.State{Status: "running", Pid: 42}, nil)
+				fakeExitWatcher.WatchReturns(exited, nil)
+			})
+
+			It("returns as soon as the process exits without polling its state", func() {
+				errChan := make(chan error)
+				go func() {
+					defer GinkgoRecover()
+					errChan <- runcLifecycle.StopProcess(logger, bpmCfg, exitTimeout)
+				}()
+
+				Eventually(fakeExitWatcher.WatchCallCount).Should(Equal(1))
+				pid, _ := fakeExitWatcher.WatchArgsForCall(0)
+				Expect(pid).To(Equal(42))
+
+				Consistently(errChan).ShouldNot(Receive())
+				close(exited)
+
+				Eventually(errChan).Should(Receive(BeNil()))
+				Expect(fakeRuncClient.ContainerStateCallCount()).To(Equal(1))
+
+				Expect(recorded()).To(HaveLen(2))
+				Expect(recorded()[1].Type).To(Equal(events.Exited))
+				Expect(recorded()[1].Pid).To(Equal(42))
+			})
+
+			It("watches the process before signalling it so that its pid cannot be reused in between", func() {
+				signalled := -1
+				fakeExitWatcher.WatchStub = func(int, <-chan struct{}) (<-chan struct{}, error) {
+					signalled = fakeRuncClient.SignalContainerCallCount()
+					return exited, nil
+				}
+				close(exited)
+
+				err := runcLifecycle.StopProcess(logger, bpmCfg, exitTimeout)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(signalled).To(Equal(0))
+				Expect(fakeRuncClient.SignalContainerCallCount()).To(Equal(1))
+			})
+
+			It("stops watching once it returns", func() {
+				close(exited)
+
+				err := runcLifecycle.StopProcess(logger, bpmCfg, exitTimeout)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, stop := fakeExitWatcher.WatchArgsForCall(0)
+				Expect(stop).To(BeClosed())
+			})
+
+			Context("and the exit timeout has passed", func() {
+				It("sends a SIGQUIT and returns a timeout error", func() {
+					errChan := make(chan error)
+					go func() {
+						defer GinkgoRecover()
+						errChan <- runcLifecycle.StopProcess(logger, bpmCfg, exitTimeout)
+					}()
+
+					fakeClock.WaitForWatcherAndIncrement(exitTimeout)
+
+					Eventually(fakeRuncClient.SignalContainerCallCount).Should(Equal(2))
+					_, signal := fakeRuncClient.SignalContainerArgsForCall(1)
+					Expect(signal).To(Equal(client.Quit))
+
+					fakeClock.WaitForWatcherAndIncrement(lifecycle.ContainerSigQuitGracePeriod)
+
+					Eventually(errChan).Should(Receive(MatchError("failed to stop job within timeout")))
+					Expect(fakeRuncClient.ContainerStateCallCount()).To(Equal(1))
 				})
 			})
 		})
@@ -474,11 +748,12 @@ var _ = Describe("RuncJobLifecycle", func() {
 				Expect(cid).To(Equal(expectedContainerID))
 				Expect(signal).To(Equal(client.Quit))
 
-				fakeClock.WaitForWatcherAndIncrement(lifecycle.ContainerSigQuitGracePeriod)
+				fakeClock.WaitForNWatchersAndIncrement(lifecycle.ContainerSigQuitGracePeriod, 2)
 
 				var actualError error
 				Eventually(errChan).Should(Receive(&actualError))
 				Expect(actualError).To(MatchError("failed to stop job within timeout"))
+				Expect(fakeExitWatcher.WatchCallCount()).To(Equal(0))
 			})
 		})
 
@@ -497,6 +772,451 @@ var _ = Describe("RuncJobLifecycle", func() {
 		})
 	})
 
+	Describe("WaitForReady", func() {
+		var (
+			tempDir   string
+			readyPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "bpm-ready")
+			Expect(err).NotTo(HaveOccurred())
+
+			readyPath = filepath.Join(tempDir, "ready.sock")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tempDir)).To(Succeed())
+		})
+
+		Context("when the path already exists", func() {
+			It("returns immediately", func() {
+				Expect(ioutil.WriteFile(readyPath, nil, 0600)).To(Succeed())
+
+				err := runcLifecycle.WaitForReady(logger, &config.Readiness{Path: readyPath})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the path is created later", func() {
+			It("polls until it exists", func() {
+				errChan := make(chan error)
+				go func() {
+					defer GinkgoRecover()
+					errChan <- runcLifecycle.WaitForReady(logger, &config.Readiness{Path: readyPath})
+				}()
+
+				fakeClock.WaitForNWatchersAndIncrement(lifecycle.ReadinessPollInterval, 2)
+				Consistently(errChan).ShouldNot(Receive())
+
+				Expect(ioutil.WriteFile(readyPath, nil, 0600)).To(Succeed())
+				fakeClock.WaitForNWatchersAndIncrement(lifecycle.ReadinessPollInterval, 2)
+
+				Eventually(errChan).Should(Receive(BeNil()))
+			})
+		})
+
+		Context("when the port accepts connections", func() {
+			It("returns immediately", func() {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).NotTo(HaveOccurred())
+				defer listener.Close()
+
+				port := listener.Addr().(*net.TCPAddr).Port
+				err = runcLifecycle.WaitForReady(logger, &config.Readiness{Port: port})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the process does not become ready within the timeout", func() {
+			It("returns a not ready error", func() {
+				errChan := make(chan error)
+				go func() {
+					defer GinkgoRecover()
+					errChan <- runcLifecycle.WaitForReady(logger, &config.Readiness{Path: readyPath, Timeout: "5s"})
+				}()
+
+				fakeClock.WaitForNWatchersAndIncrement(5*time.Second, 2)
+
+				var actualError error
+				Eventually(errChan).Should(Receive(&actualError))
+				Expect(actualError).To(MatchError("process did not become ready within timeout"))
+				Expect(errors.Is(actualError, errs.NotReady)).To(BeTrue())
+				Expect(logger).To(gbytes.Say("timed-out-waiting-for-readiness"))
+			})
+		})
+	})
+
+	Describe("WaitForCgroupEmpty", func() {
+		var cgroup string
+
+		const cgroupTimeout = 5 * time.Second
+
+		BeforeEach(func() {
+			var err error
+			cgroup, err = ioutil.TempDir("", "bpm-cgroup")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cgroup)).To(Succeed())
+		})
+
+		Context("when the cgroup has been removed", func() {
+			It("returns immediately", func() {
+				err := runcLifecycle.WaitForCgroupEmpty(logger, filepath.Join(cgroup, "gone"), cgroupTimeout)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the processes exit", func() {
+			It("polls until the cgroup is empty", func() {
+				procsPath := filepath.Join(cgroup, "cgroup.procs")
+				Expect(ioutil.WriteFile(procsPath, []byte("123\n456\n"), 0600)).To(Succeed())
+
+				errChan := make(chan error)
+				go func() {
+					defer GinkgoRecover()
+					errChan <- runcLifecycle.WaitForCgroupEmpty(logger, cgroup, cgroupTimeout)
+				}()
+
+				fakeClock.WaitForNWatchersAndIncrement(lifecycle.ContainerStatePollInterval, 2)
+				Consistently(errChan).ShouldNot(Receive())
+
+				Expect(ioutil.WriteFile(procsPath, nil, 0600)).To(Succeed())
+				fakeClock.WaitForNWatchersAndIncrement(lifecycle.ContainerStatePollInterval, 2)
+
+				Eventually(errChan).Should(Receive(BeNil()))
+			})
+		})
+
+		Context("when the processes do not exit within the timeout", func() {
+			It("returns a stop timeout error", func() {
+				Expect(ioutil.WriteFile(filepath.Join(cgroup, "cgroup.procs"), []byte("123\n"), 0600)).To(Succeed())
+
+				errChan := make(chan error)
+				go func() {
+					defer GinkgoRecover()
+					errChan <- runcLifecycle.WaitForCgroupEmpty(logger, cgroup, cgroupTimeout)
+				}()
+
+				fakeClock.WaitForNWatchersAndIncrement(cgroupTimeout, 2)
+
+				var actualError error
+				Eventually(errChan).Should(Receive(&actualError))
+				Expect(actualError).To(MatchError("processes in the cgroup did not exit within timeout"))
+				Expect(errors.Is(actualError, errs.StopTimeout)).To(BeTrue())
+				Expect(logger).To(gbytes.Say("timed-out-waiting-for-cgroup"))
+			})
+		})
+	})
+
+	Describe("ContainerCgroup", func() {
+		It("returns the freezer cgroup of the container with cgroups v1", func() {
+			fakeRuncClient.InitProcessReturns(&client.InitProcess{
+				Pid: 42,
+				Cgroups: map[string]string{
+					"freezer": "/sys/fs/cgroup/freezer/container",
+					"memory":  "/sys/fs/cgroup/memory/container",
+				},
+			}, nil)
+
+			cgroup, err := runcLifecycle.ContainerCgroup(bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cgroup).To(Equal("/sys/fs/cgroup/freezer/container"))
+			Expect(fakeRuncClient.InitProcessArgsForCall(0)).To(Equal(bpmCfg.ContainerID()))
+		})
+
+		It("returns the only cgroup of the container with cgroups v2", func() {
+			fakeRuncClient.InitProcessReturns(&client.InitProcess{
+				Pid:     42,
+				Cgroups: map[string]string{"": "/sys/fs/cgroup/container"},
+			}, nil)
+
+			cgroup, err := runcLifecycle.ContainerCgroup(bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cgroup).To(Equal("/sys/fs/cgroup/container"))
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns an empty string", func() {
+				fakeRuncClient.InitProcessReturns(nil, nil)
+
+				cgroup, err := runcLifecycle.ContainerCgroup(bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cgroup).To(BeEmpty())
+			})
+		})
+
+		Context("when the state of the container cannot be read", func() {
+			It("returns the error if the process in the pidfile cannot be found", func() {
+				fakeRuncClient.InitProcessReturns(nil, errors.New("permission denied"))
+
+				_, err := runcLifecycle.ContainerCgroup(bpmCfg)
+				Expect(err).To(MatchError("permission denied"))
+			})
+		})
+	})
+
+	Describe("RepairProcess", func() {
+		var cgroupDir string
+
+		// ownStartTime returns the start time of the test process in the
+		// same form that runc records for the init process of a container.
+		ownStartTime := func() uint64 {
+			data, err := ioutil.ReadFile("/proc/self/stat")
+			Expect(err).NotTo(HaveOccurred())
+
+			stat := string(data)
+			fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+			start, err := strconv.ParseUint(fields[19], 10, 64)
+			Expect(err).NotTo(HaveOccurred())
+			return start
+		}
+
+		writeProcs := func(pids ...int) {
+			var procs string
+			for _, pid := range pids {
+				procs += fmt.Sprintf("%d\n", pid)
+			}
+			Expect(ioutil.WriteFile(filepath.Join(cgroupDir, "cgroup.procs"), []byte(procs), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			cgroupDir, err = ioutil.TempDir("", "runc-lifecycle-cgroup")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cgroupDir)).To(Succeed())
+		})
+
+		Context("when the init process is alive and in the cgroup of the container", func() {
+			BeforeEach(func() {
+				writeProcs(1, os.Getpid())
+				fakeRuncClient.InitProcessReturns(&client.InitProcess{
+					Pid:       os.Getpid(),
+					StartTime: ownStartTime(),
+					Cgroups:   map[string]string{"memory": cgroupDir},
+				}, nil)
+			})
+
+			It("leaves the container alone", func() {
+				reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reason).To(BeEmpty())
+
+				Expect(fakeRuncClient.InitProcessArgsForCall(0)).To(Equal(expectedContainerID))
+				Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(0))
+				Expect(fakeRuncClient.DestroyBundleCallCount()).To(Equal(0))
+				Expect(recorded()).To(BeEmpty())
+			})
+		})
+
+		Context("when there is no state for the container", func() {
+			It("does nothing", func() {
+				reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reason).To(BeEmpty())
+
+				Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the init pid has been reused by another process", func() {
+			BeforeEach(func() {
+				writeProcs(os.Getpid())
+				fakeRuncClient.InitProcessReturns(&client.InitProcess{
+					Pid:       os.Getpid(),
+					StartTime: ownStartTime() + 1,
+					Cgroups:   map[string]string{"memory": cgroupDir},
+				}, nil)
+			})
+
+			It("removes the state, bundle, secrets, and pidfile of the container", func() {
+				reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reason).To(Equal(fmt.Sprintf("init pid %d has been reused by another process", os.Getpid())))
+
+				Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(1))
+				Expect(fakeRuncClient.DestroyContainerStateArgsForCall(0)).To(Equal(expectedContainerID))
+				Expect(fakeRuncClient.DestroyBundleCallCount()).To(Equal(1))
+				Expect(fakeRuncClient.DestroyBundleArgsForCall(0)).To(Equal(bpmCfg.BundlePath()))
+				Expect(fakeRuncAdapter.RemoveSecretsCallCount()).To(Equal(1))
+				Expect(fakeFileRemover.deletedFiles).To(ConsistOf(bpmCfg.PidFile()))
+			})
+
+			It("does not kill or delete the container with runc", func() {
+				_, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeRuncClient.SignalContainerCallCount()).To(Equal(0))
+				Expect(fakeRuncClient.DeleteContainerCallCount()).To(Equal(0))
+			})
+
+			It("records the repair in the journal", func() {
+				reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(recorded()).To(HaveLen(1))
+				Expect(recorded()[0].Type).To(Equal(events.Repaired))
+				Expect(recorded()[0].Reason).To(Equal(reason))
+			})
+		})
+
+		Context("when the init process is not in the cgroup of the container", func() {
+			BeforeEach(func() {
+				writeProcs(1)
+				fakeRuncClient.InitProcessReturns(&client.InitProcess{
+					Pid:       os.Getpid(),
+					StartTime: ownStartTime(),
+					Cgroups:   map[string]string{"memory": cgroupDir},
+				}, nil)
+			})
+
+			It("removes the state of the container", func() {
+				reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reason).To(Equal(fmt.Sprintf("init pid %d is not in the cgroups of the container", os.Getpid())))
+
+				Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the init process has exited", func() {
+			var deadPid int
+
+			BeforeEach(func() {
+				cmd := exec.Command("true")
+				Expect(cmd.Run()).To(Succeed())
+				deadPid = cmd.Process.Pid
+			})
+
+			Context("and the cgroup of the container still exists", func() {
+				BeforeEach(func() {
+					fakeRuncClient.InitProcessReturns(&client.InitProcess{
+						Pid:       deadPid,
+						StartTime: 1,
+						Cgroups:   map[string]string{"memory": cgroupDir},
+					}, nil)
+				})
+
+				It("leaves the container for runc to clean up", func() {
+					reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reason).To(BeEmpty())
+
+					Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("and the cgroup of the container is gone", func() {
+				BeforeEach(func() {
+					fakeRuncClient.InitProcessReturns(&client.InitProcess{
+						Pid:       deadPid,
+						StartTime: 1,
+						Cgroups:   map[string]string{"memory": filepath.Join(cgroupDir, "gone")},
+					}, nil)
+				})
+
+				It("removes the state of the container", func() {
+					reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reason).To(Equal(fmt.Sprintf("init pid %d and the cgroups of the container are gone", deadPid)))
+
+					Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(1))
+				})
+			})
+		})
+
+		Context("when the state of the container is invalid", func() {
+			BeforeEach(func() {
+				fakeRuncClient.InitProcessReturns(nil, fmt.Errorf("%w: unexpected end of JSON input", client.ErrInvalidState))
+			})
+
+			It("removes the state of the container", func() {
+				reason, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reason).To(Equal("container state is invalid: unexpected end of JSON input"))
+
+				Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the state of the container cannot be read", func() {
+			BeforeEach(func() {
+				fakeRuncClient.InitProcessReturns(nil, errors.New("permission denied"))
+			})
+
+			It("returns an error and leaves the container alone", func() {
+				_, err := runcLifecycle.RepairProcess(logger, bpmCfg)
+				Expect(err).To(MatchError("permission denied"))
+
+				Expect(fakeRuncClient.DestroyContainerStateCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("OOMKilled", func() {
+		var memoryCgroup string
+
+		BeforeEach(func() {
+			var err error
+			memoryCgroup, err = ioutil.TempDir("", "runc-lifecycle-cgroup")
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeRuncClient.InitProcessReturns(&client.InitProcess{
+				Cgroups: map[string]string{"freezer": "/not/used", "memory": memoryCgroup},
+			}, nil)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(memoryCgroup)).To(Succeed())
+		})
+
+		It("reports whether the kernel killed a process in the memory cgroup of the container", func() {
+			Expect(ioutil.WriteFile(filepath.Join(memoryCgroup, "memory.oom_control"), []byte("oom_kill_disable 0\nunder_oom 0\noom_kill 1\n"), 0600)).To(Succeed())
+
+			oomKilled, err := runcLifecycle.OOMKilled(bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(oomKilled).To(BeTrue())
+			Expect(fakeRuncClient.InitProcessArgsForCall(0)).To(Equal(expectedContainerID))
+		})
+
+		It("reads the only cgroup of the container with cgroups v2", func() {
+			fakeRuncClient.InitProcessReturns(&client.InitProcess{
+				Cgroups: map[string]string{"": memoryCgroup},
+			}, nil)
+			Expect(ioutil.WriteFile(filepath.Join(memoryCgroup, "memory.events"), []byte("oom 1\noom_kill 1\n"), 0600)).To(Succeed())
+
+			oomKilled, err := runcLifecycle.OOMKilled(bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(oomKilled).To(BeTrue())
+		})
+
+		It("reports no kill when there have not been any", func() {
+			Expect(ioutil.WriteFile(filepath.Join(memoryCgroup, "memory.oom_control"), []byte("oom_kill_disable 0\nunder_oom 0\noom_kill 0\n"), 0600)).To(Succeed())
+
+			oomKilled, err := runcLifecycle.OOMKilled(bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(oomKilled).To(BeFalse())
+		})
+
+		Context("when the container does not exist", func() {
+			BeforeEach(func() {
+				fakeRuncClient.InitProcessReturns(nil, nil)
+			})
+
+			It("reports no kill", func() {
+				oomKilled, err := runcLifecycle.OOMKilled(bpmCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(oomKilled).To(BeFalse())
+			})
+		})
+	})
+
 	Describe("RemoveProcess", func() {
 		It("deletes the container", func() {
 			err := runcLifecycle.RemoveProcess(logger, bpmCfg)
@@ -516,6 +1236,14 @@ var _ = Describe("RuncJobLifecycle", func() {
 			Expect(bundlePath).To(Equal(filepath.Join(expectedSystemRoot, "data", "bpm", "bundles", expectedJobName, expectedProcName)))
 		})
 
+		It("removes the secrets", func() {
+			err := runcLifecycle.RemoveProcess(logger, bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuncAdapter.RemoveSecretsCallCount()).To(Equal(1))
+			Expect(fakeRuncAdapter.RemoveSecretsArgsForCall(0)).To(Equal(bpmCfg))
+		})
+
 		It("deletes the pidfile", func() {
 			err := runcLifecycle.RemoveProcess(logger, bpmCfg)
 			Expect(err).NotTo(HaveOccurred())
@@ -523,6 +1251,199 @@ var _ = Describe("RuncJobLifecycle", func() {
 			Expect(fakeFileRemover.deletedFiles).To(ConsistOf(bpmCfg.PidFile()))
 		})
 
+		It("records the removal in the journal", func() {
+			err := runcLifecycle.RemoveProcess(logger, bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorded()).To(HaveLen(1))
+			Expect(recorded()[0].Type).To(Equal(events.Removed))
+		})
+
+		Context("when processes are left in the cgroup of the container", func() {
+			var (
+				cgroupDir string
+				straggler *exec.Cmd
+				killed    chan struct{}
+			)
+
+			BeforeEach(func() {
+				var err error
+				cgroupDir, err = ioutil.TempDir("", "runc-lifecycle-cgroup")
+				Expect(err).NotTo(HaveOccurred())
+
+				straggler = exec.Command("sleep", "60")
+				Expect(straggler.Start()).To(Succeed())
+
+				procsPath := filepath.Join(cgroupDir, "cgroup.procs")
+				Expect(ioutil.WriteFile(filepath.Join(cgroupDir, "freezer.state"), []byte("THAWED"), 0600)).To(Succeed())
+				Expect(ioutil.WriteFile(procsPath, []byte(fmt.Sprintf("%d\n", straggler.Process.Pid)), 0600)).To(Succeed())
+
+				// The kernel removes a process from its cgroup once it has
+				// exited.
+				killed = make(chan struct{})
+				go func() {
+					straggler.Wait()
+					ioutil.WriteFile(procsPath, nil, 0600)
+					close(killed)
+				}()
+
+				fakeRuncClient.InitProcessReturns(&client.InitProcess{
+					Pid:     straggler.Process.Pid,
+					Cgroups: map[string]string{"freezer": cgroupDir, "memory": "/not/used"},
+				}, nil)
+			})
+
+			AfterEach(func() {
+				straggler.Process.Kill()
+				Eventually(killed).Should(BeClosed())
+				Expect(os.RemoveAll(cgroupDir)).To(Succeed())
+			})
+
+			removeProcess := func() error {
+				errChan := make(chan error, 1)
+				go func() {
+					defer GinkgoRecover()
+					errChan <- runcLifecycle.RemoveProcess(logger, bpmCfg)
+				}()
+
+				var err error
+				Eventually(func() bool {
+					select {
+					case err = <-errChan:
+						return true
+					default:
+						fakeClock.Increment(lifecycle.ContainerStatePollInterval)
+						return false
+					}
+				}).Should(BeTrue())
+
+				return err
+			}
+
+			It("kills them and waits for them to exit before deleting the container and the pidfile", func() {
+				fakeRuncClient.DeleteContainerStub = func(string) error {
+					Expect(killed).To(BeClosed())
+					return nil
+				}
+
+				Expect(removeProcess()).To(Succeed())
+
+				Expect(killed).To(BeClosed())
+				Expect(straggler.ProcessState.Sys().(syscall.WaitStatus).Signal()).To(Equal(syscall.SIGKILL))
+				Expect(fakeRuncClient.DeleteContainerCallCount()).To(Equal(1))
+				Expect(fakeFileRemover.deletedFiles).To(ConsistOf(bpmCfg.PidFile()))
+			})
+
+			It("thaws the cgroup once they have been killed", func() {
+				Expect(removeProcess()).To(Succeed())
+
+				state, err := ioutil.ReadFile(filepath.Join(cgroupDir, "freezer.state"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(state)).To(Equal("THAWED"))
+			})
+
+			It("logs how many processes were killed", func() {
+				Expect(removeProcess()).To(Succeed())
+
+				Expect(logger).To(gbytes.Say("killing-stragglers.*\"count\":1"))
+			})
+
+			Context("and the cgroup cannot be frozen", func() {
+				var (
+					unfrozenDir   string
+					first, forked *exec.Cmd
+					emptied       chan struct{}
+				)
+
+				BeforeEach(func() {
+					var err error
+					unfrozenDir, err = ioutil.TempDir("", "runc-lifecycle-cgroup")
+					Expect(err).NotTo(HaveOccurred())
+
+					first = exec.Command("sleep", "60")
+					Expect(first.Start()).To(Succeed())
+					forked = exec.Command("sleep", "60")
+					Expect(forked.Start()).To(Succeed())
+
+					// The first process forks the second one as it is
+					// killed.
+					procsPath := filepath.Join(unfrozenDir, "cgroup.procs")
+					Expect(ioutil.WriteFile(procsPath, []byte(fmt.Sprintf("%d\n", first.Process.Pid)), 0600)).To(Succeed())
+
+					emptied = make(chan struct{})
+					go func() {
+						first.Wait()
+						ioutil.WriteFile(procsPath, []byte(fmt.Sprintf("%d\n", forked.Process.Pid)), 0600)
+						forked.Wait()
+						ioutil.WriteFile(procsPath, nil, 0600)
+						close(emptied)
+					}()
+
+					fakeRuncClient.InitProcessReturns(&client.InitProcess{
+						Cgroups: map[string]string{"": unfrozenDir},
+					}, nil)
+				})
+
+				AfterEach(func() {
+					first.Process.Kill()
+					forked.Process.Kill()
+					Eventually(emptied).Should(BeClosed())
+					Expect(os.RemoveAll(unfrozenDir)).To(Succeed())
+				})
+
+				It("keeps killing the processes in the cgroup until it is empty", func() {
+					Expect(removeProcess()).To(Succeed())
+
+					Expect(emptied).To(BeClosed())
+					Expect(first.ProcessState.Sys().(syscall.WaitStatus).Signal()).To(Equal(syscall.SIGKILL))
+					Expect(forked.ProcessState.Sys().(syscall.WaitStatus).Signal()).To(Equal(syscall.SIGKILL))
+					Expect(fakeRuncClient.DeleteContainerCallCount()).To(Equal(1))
+				})
+
+				It("thaws the cgroup before killing them", func() {
+					Expect(removeProcess()).To(Succeed())
+
+					state, err := ioutil.ReadFile(filepath.Join(unfrozenDir, "cgroup.freeze"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(state)).To(Equal("0"))
+				})
+			})
+
+			Context("and they do not exit", func() {
+				BeforeEach(func() {
+					fakeRuncClient.InitProcessReturns(&client.InitProcess{
+						Cgroups: map[string]string{"freezer": cgroupDir},
+					}, nil)
+					Expect(ioutil.WriteFile(filepath.Join(cgroupDir, "cgroup.procs"), []byte("999999999\n"), 0600)).To(Succeed())
+				})
+
+				It("still removes the container before returning an error", func() {
+					err := removeProcess()
+					Expect(errors.Is(err, errs.StopTimeout)).To(BeTrue())
+
+					Expect(fakeRuncClient.DeleteContainerCallCount()).To(Equal(1))
+					Expect(fakeRuncClient.DestroyBundleCallCount()).To(Equal(1))
+					Expect(fakeRuncAdapter.RemoveSecretsCallCount()).To(Equal(1))
+					Expect(fakeFileRemover.deletedFiles).To(ConsistOf(bpmCfg.PidFile()))
+					Expect(logger).To(gbytes.Say("failed-to-kill-stragglers"))
+				})
+			})
+		})
+
+		Context("when the cgroup of the container cannot be found", func() {
+			BeforeEach(func() {
+				fakeRuncClient.InitProcessReturns(nil, errors.New("permission denied"))
+			})
+
+			It("still removes the container before returning an error", func() {
+				err := runcLifecycle.RemoveProcess(logger, bpmCfg)
+				Expect(err).To(MatchError("permission denied"))
+
+				Expect(fakeRuncClient.DeleteContainerCallCount()).To(Equal(1))
+				Expect(fakeFileRemover.deletedFiles).To(ConsistOf(bpmCfg.PidFile()))
+			})
+		})
+
 		Context("when the process name is the same as the job name", func() {
 			BeforeEach(func() {
 				bpmCfg = config.NewBPMConfig(expectedSystemRoot, expectedJobName, expectedJobName)
@@ -560,6 +1481,15 @@ var _ = Describe("RuncJobLifecycle", func() {
 				Expect(err).To(Equal(expectedErr))
 			})
 		})
+
+		Context("when removing the secrets fails", func() {
+			It("returns an error", func() {
+				expectedErr := errors.New("an error3")
+				fakeRuncAdapter.RemoveSecretsReturns(expectedErr)
+				err := runcLifecycle.RemoveProcess(logger, bpmCfg)
+				Expect(err).To(Equal(expectedErr))
+			})
+		})
 	})
 
 	Describe("ListProcesses", func() {
@@ -587,6 +1517,11 @@ var _ = Describe("RuncJobLifecycle", func() {
 					InitProcessPid: 0,
 					Status:         "stopped",
 				},
+				{
+					ID:             "job-process-4",
+					InitProcessPid: 45678,
+					Status:         "paused",
+				},
 			}
 			fakeRuncClient.ListContainersReturns(containerStates, nil)
 
@@ -597,6 +1532,7 @@ var _ = Describe("RuncJobLifecycle", func() {
 				{Name: "job-process-2", Pid: 23456, Status: "created"},
 				{Name: "job-process-1", Pid: 34567, Status: "running"},
 				{Name: "job-process-3", Pid: 0, Status: "failed"},
+				{Name: "job-process-4", Pid: 45678, Status: "paused"},
 			}))
 		})
 
@@ -687,6 +1623,95 @@ var _ = Describe("RuncJobLifecycle", func() {
 		})
 	})
 
+	Describe("SpecChanged", func() {
+		BeforeEach(func() {
+			hash, err := client.SpecHash(jobSpec)
+			Expect(err).NotTo(HaveOccurred())
+			fakeRuncClient.BundleSpecReturns(jobSpec, hash, nil)
+		})
+
+		It("builds the spec from the current configuration", func() {
+			_, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuncClient.BundleSpecCallCount()).To(Equal(1))
+			Expect(fakeRuncClient.BundleSpecArgsForCall(0)).To(Equal(bpmCfg.BundlePath()))
+
+			Expect(fakeRuncAdapter.BuildSpecCallCount()).To(Equal(1))
+			_, actualBPMCfg, actualProcCfg, actualUser := fakeRuncAdapter.BuildSpecArgsForCall(0)
+			Expect(actualBPMCfg).To(Equal(bpmCfg))
+			Expect(actualProcCfg).To(Equal(procCfg))
+			Expect(actualUser).To(Equal(expectedUser))
+		})
+
+		Context("when the spec has not changed", func() {
+			It("returns false", func() {
+				changed, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(changed).To(BeFalse())
+			})
+		})
+
+		Context("when the spec has changed", func() {
+			BeforeEach(func() {
+				changedSpec := jobSpec
+				changedSpec.Hostname = "changed"
+				fakeRuncAdapter.BuildSpecReturns(changedSpec, nil)
+			})
+
+			It("returns true", func() {
+				changed, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(changed).To(BeTrue())
+			})
+		})
+
+		Context("when the bundle does not exist", func() {
+			BeforeEach(func() {
+				fakeRuncClient.BundleSpecReturns(specs.Spec{}, "", &os.PathError{Op: "open", Err: os.ErrNotExist})
+			})
+
+			It("returns an 'IsNotExist' error", func() {
+				_, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+				Expect(lifecycle.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when building the spec fails", func() {
+			BeforeEach(func() {
+				fakeRuncAdapter.BuildSpecReturns(specs.Spec{}, errors.New("boom"))
+			})
+
+			It("returns a config invalid error", func() {
+				_, err := runcLifecycle.SpecChanged(logger, bpmCfg, procCfg)
+				Expect(errors.Is(err, errs.ConfigInvalid)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("RunningSpec", func() {
+		BeforeEach(func() {
+			fakeRuncClient.BundleSpecReturns(jobSpec, "some-hash", nil)
+		})
+
+		It("returns the spec stored in the bundle", func() {
+			spec, err := runcLifecycle.RunningSpec(bpmCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec).To(Equal(jobSpec))
+		})
+
+		Context("when the bundle does not exist", func() {
+			BeforeEach(func() {
+				fakeRuncClient.BundleSpecReturns(specs.Spec{}, "", &os.PathError{Op: "open", Err: os.ErrNotExist})
+			})
+
+			It("returns an 'IsNotExist' error", func() {
+				_, err := runcLifecycle.RunningSpec(bpmCfg)
+				Expect(lifecycle.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
 	Describe("OpenShell", func() {
 		var expectedStdin *gbytes.Buffer
 
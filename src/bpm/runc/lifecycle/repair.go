@@ -0,0 +1,188 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+
+	"bpm/cgroups"
+	"bpm/config"
+	"bpm/events"
+	"bpm/runc/client"
+)
+
+// RepairProcess removes the state which runc keeps for a container if it no
+// longer describes a live process. This happens after an unclean reboot:
+// the init pid of the container is gone or has been reused by an unrelated
+// process and the cgroups of the container no longer exist. runc either
+// fails to read such a container or reports it as running.
+//
+// The state is only removed if none of the processes of the container can
+// still be running. The bundle, secrets, and pidfile are removed along with
+// it so that the process can be started again. The reason that the state was
+// stale is returned or an empty string if it was not.
+func (j *RuncLifecycle) RepairProcess(logger lager.Logger, cfg *config.BPMConfig) (string, error) {
+	var reason string
+
+	init, err := j.runcClient.InitProcess(cfg.ContainerID())
+	switch {
+	case errors.Is(err, client.ErrInvalidState):
+		reason = err.Error()
+	case err != nil:
+		return "", err
+	case init != nil:
+		reason, err = staleReason(init)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if reason == "" {
+		return "", nil
+	}
+
+	logger = logger.Session("repair", lager.Data{"reason": reason})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	logger.Info("destroying-container-state")
+	if err := j.runcClient.DestroyContainerState(cfg.ContainerID()); err != nil {
+		return "", err
+	}
+
+	if init != nil {
+		// A cgroup which still has processes in it cannot be removed and
+		// one which is already gone does not need to be.
+		for _, cgroup := range init.Cgroups {
+			os.Remove(cgroup)
+		}
+	}
+
+	logger.Info("destroying-bundle")
+	if err := j.runcClient.DestroyBundle(cfg.BundlePath()); err != nil {
+		return "", err
+	}
+
+	logger.Info("removing-secrets")
+	if err := j.runcAdapter.RemoveSecrets(cfg); err != nil {
+		return "", err
+	}
+
+	logger.Info("deleting-pidfile")
+	if err := j.deleteFile(cfg.PidFile()); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	j.record(logger, cfg, events.Event{Type: events.Repaired, Reason: reason})
+	return reason, nil
+}
+
+// staleReason returns why the init process recorded by runc is no longer a
+// live process of the container or an empty string if it is.
+//
+// The init process is identified by both its pid and its start time because
+// pids are reused after a reboot. If the process is still alive it must also
+// be in one of the cgroups of the container. If it is gone, the container is
+// only stale if its cgroups are gone too; otherwise its other processes may
+// still be running and runc can clean it up as usual.
+func staleReason(init *client.InitProcess) (string, error) {
+	startTime, err := processStartTime(init.Pid)
+	alive := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if alive && startTime != init.StartTime {
+		return fmt.Sprintf("init pid %d has been reused by another process", init.Pid), nil
+	}
+
+	if len(init.Cgroups) == 0 {
+		return "", nil
+	}
+
+	subsystems := make([]string, 0, len(init.Cgroups))
+	for subsystem := range init.Cgroups {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+
+	for _, subsystem := range subsystems {
+		cgroup := init.Cgroups[subsystem]
+
+		if !alive {
+			if _, err := os.Stat(cgroup); err == nil {
+				return "", nil
+			}
+			continue
+		}
+
+		pids, err := cgroups.Procs(cgroup)
+		if err != nil {
+			return "", err
+		}
+
+		for _, pid := range pids {
+			if pid == init.Pid {
+				return "", nil
+			}
+		}
+	}
+
+	if !alive {
+		return fmt.Sprintf("init pid %d and the cgroups of the container are gone", init.Pid), nil
+	}
+
+	return fmt.Sprintf("init pid %d is not in the cgroups of the container", init.Pid), nil
+}
+
+// processStartTime returns when a process started in clock ticks since boot.
+// It returns an error which satisfies os.IsNotExist if the process does not
+// exist.
+func processStartTime(pid int) (uint64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	return parseStartTime(string(data))
+}
+
+// parseStartTime reads the starttime field of /proc/<pid>/stat. The name of
+// the process is skipped first because it can contain spaces and
+// parentheses.
+func parseStartTime(stat string) (uint64, error) {
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, errors.New("malformed process stat")
+	}
+
+	// The fields after the name start with the state, which is the third
+	// field. The start time is the 22nd.
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return 0, errors.New("malformed process stat")
+	}
+
+	return strconv.ParseUint(fields[19], 10, 64)
+}
@@ -0,0 +1,416 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package supervisor watches the processes on a VM and restarts them when
+// they exit according to their restart policy. It is used by bpm supervise.
+package supervisor
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/sys/unix"
+
+	"bpm/config"
+	"bpm/events"
+	"bpm/models"
+)
+
+const (
+	// InitialBackoff is how long to wait before restarting a process the
+	// first time it exits. The wait doubles with each restart up to
+	// MaxBackoff.
+	InitialBackoff = 1 * time.Second
+	MaxBackoff     = 1 * time.Minute
+
+	// A process which has run for StableRunTime is no longer considered to
+	// be crash looping and its backoff is reset.
+	StableRunTime = 1 * time.Minute
+
+	// CrashLoopLimit is the number of times a process is restarted without
+	// running for StableRunTime before the supervisor gives up on it.
+	CrashLoopLimit = 5
+
+	// PollInterval is how often to check on a process which the supervisor
+	// is not able to wait for.
+	PollInterval = 1 * time.Second
+
+	// ReloadInterval is how often the configuration of the jobs on the
+	// machine is read again so that processes which have been added or
+	// removed are picked up.
+	ReloadInterval = 1 * time.Minute
+)
+
+//go:generate counterfeiter . Lifecycle
+
+type Lifecycle interface {
+	StartProcess(logger lager.Logger, bpmCfg *config.BPMConfig, procCfg *config.ProcessConfig) error
+	StatProcess(cfg *config.BPMConfig) (*models.Process, error)
+	RemoveProcess(logger lager.Logger, cfg *config.BPMConfig) error
+}
+
+//go:generate counterfeiter . Waiter
+
+// Waiter waits for the init process of a container to exit.
+type Waiter interface {
+	Wait(pid int) (Exit, error)
+}
+
+// Exit describes how a process exited.
+type Exit struct {
+	Status int
+	Signal syscall.Signal
+
+	// Unknown is set when the process could only be seen to have gone away
+	// (e.g. because it was not started by the supervisor).
+	Unknown bool
+
+	// OOMKilled is set when the kernel killed a process in the container
+	// because it ran out of memory.
+	OOMKilled bool
+}
+
+// Failed reports whether the process exited unsuccessfully.
+func (e Exit) Failed() bool {
+	return e.Unknown || e.Signal != 0 || e.Status != 0
+}
+
+// LockFunc takes the lifecycle lock of a process. It returns a function which
+// releases the lock.
+type LockFunc func(logger lager.Logger, cfg *config.BPMConfig) (func(), error)
+
+// LoadFunc reads the configuration of every process on the machine.
+type LoadFunc func() []Process
+
+// Process is a process which is supervised.
+type Process struct {
+	Config        *config.BPMConfig
+	ProcessConfig *config.ProcessConfig
+}
+
+type Supervisor struct {
+	clock     clock.Clock
+	journal   *events.Journal
+	lifecycle Lifecycle
+	load      LoadFunc
+	lock      LockFunc
+	logger    lager.Logger
+	waiter    Waiter
+}
+
+func New(logger lager.Logger, lifecycle Lifecycle, waiter Waiter, lock LockFunc, load LoadFunc, clock clock.Clock, journal *events.Journal) *Supervisor {
+	return &Supervisor{
+		clock:     clock,
+		journal:   journal,
+		lifecycle: lifecycle,
+		load:      load,
+		lock:      lock,
+		logger:    logger,
+		waiter:    waiter,
+	}
+}
+
+// Run supervises every process on the machine until stop is closed. The
+// configuration of the processes is read again every ReloadInterval and
+// whenever something is sent on reload. Processes which have been added are
+// then supervised as well and processes which have been removed are left
+// alone from then on. The processes are left running when it returns.
+func (s *Supervisor) Run(reload <-chan struct{}, stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	supervised := map[string]chan struct{}{}
+	defer func() {
+		for _, done := range supervised {
+			close(done)
+		}
+	}()
+
+	ticker := s.clock.NewTicker(ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		loaded := map[string]bool{}
+
+		for _, p := range s.load() {
+			id := p.Config.ContainerID()
+			loaded[id] = true
+
+			if _, ok := supervised[id]; ok {
+				continue
+			}
+
+			done := make(chan struct{})
+			supervised[id] = done
+
+			wg.Add(1)
+			go func(p Process) {
+				defer wg.Done()
+				s.Supervise(p, done)
+			}(p)
+		}
+
+		for id, done := range supervised {
+			if !loaded[id] {
+				close(done)
+				delete(supervised, id)
+			}
+		}
+
+		select {
+		case <-reload:
+			s.logger.Info("reloading")
+		case <-ticker.C():
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Supervise starts a process if it is not already running and then restarts
+// it whenever it exits until stop is closed. A process which is stopped or
+// removed by another bpm command is left alone until it is started again.
+func (s *Supervisor) Supervise(p Process, stop <-chan struct{}) {
+	logger := s.logger.Session("supervise", lager.Data{
+		"job":     p.Config.JobName(),
+		"process": p.Config.ProcName(),
+	})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	if _, err := s.withLock(logger, p, s.start); err != nil {
+		logger.Error("failed-to-start", err)
+	}
+
+	var (
+		crashes   int
+		restarted bool
+		backoff   = InitialBackoff
+	)
+
+	for {
+		process, err := s.lifecycle.StatProcess(p.Config)
+		if err != nil || process.Status != models.ProcessStateRunning {
+			if !s.sleep(PollInterval, stop) {
+				return
+			}
+			continue
+		}
+
+		// A process which was started by someone else has not been crash
+		// looping under the supervisor.
+		if !restarted {
+			crashes = 0
+			backoff = InitialBackoff
+		}
+		restarted = false
+
+		startedAt := s.clock.Now()
+		exit, ok := s.wait(logger, process.Pid, stop)
+		if !ok {
+			return
+		}
+
+		logger.Info("process-exited", lager.Data{
+			"status":     exit.Status,
+			"signal":     int(exit.Signal),
+			"unknown":    exit.Unknown,
+			"oom-killed": exit.OOMKilled,
+		})
+		s.recordExit(logger, p, process.Pid, exit)
+
+		if s.clock.Since(startedAt) >= StableRunTime {
+			crashes = 0
+			backoff = InitialBackoff
+		}
+
+		// The configuration may have changed since the process was
+		// started and it is started again with the new one.
+		current, ok := s.reloadConfig(p)
+		if !ok {
+			logger.Info("not-restarting", lager.Data{"reason": "process is no longer configured"})
+			continue
+		}
+		p = current
+
+		if !p.ProcessConfig.ShouldRestart(exit.Failed()) {
+			logger.Info("not-restarting", lager.Data{"restart": p.ProcessConfig.RestartPolicy()})
+			continue
+		}
+
+		// A process which fails to start again is retried in the same way as
+		// one which exits. Its container may not exist after a failed start
+		// and so the retries do not check whether it has been removed.
+		attempt := s.restart
+		for {
+			if crashes >= CrashLoopLimit {
+				logger.Info("crash-loop-limit-reached", lager.Data{"restarts": crashes})
+				break
+			}
+
+			logger.Info("waiting-to-restart", lager.Data{"backoff": backoff.String()})
+			if !s.sleep(backoff, stop) {
+				return
+			}
+
+			restarted, err = s.withLock(logger, p, attempt)
+			if restarted {
+				crashes++
+				backoff *= 2
+				if backoff > MaxBackoff {
+					backoff = MaxBackoff
+				}
+			}
+
+			if err == nil {
+				break
+			}
+
+			logger.Error("failed-to-restart", err)
+			if !restarted {
+				break
+			}
+			attempt = s.start
+		}
+	}
+}
+
+// reloadConfig reads the configuration of a process again. It returns false
+// if the process is no longer configured on the machine.
+func (s *Supervisor) reloadConfig(p Process) (Process, bool) {
+	for _, current := range s.load() {
+		if current.Config.ContainerID() == p.Config.ContainerID() {
+			return current, true
+		}
+	}
+
+	return Process{}, false
+}
+
+func (s *Supervisor) withLock(logger lager.Logger, p Process, fn func(lager.Logger, Process) (bool, error)) (bool, error) {
+	unlock, err := s.lock(logger, p.Config)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	return fn(logger, p)
+}
+
+// start starts a process unless it is already running. A paused process is
+// left alone.
+func (s *Supervisor) start(logger lager.Logger, p Process) (bool, error) {
+	process, err := s.lifecycle.StatProcess(p.Config)
+	if err == nil && isAlive(process) {
+		logger.Info("process-already-running", lager.Data{"state": process.Status})
+		return false, nil
+	}
+
+	if err == nil {
+		if err := s.lifecycle.RemoveProcess(logger, p.Config); err != nil {
+			return false, err
+		}
+	}
+
+	return true, s.lifecycle.StartProcess(logger, p.Config, p.ProcessConfig)
+}
+
+// restart starts a process again after it has exited. A process which has
+// been removed was stopped on purpose and so it is not restarted.
+func (s *Supervisor) restart(logger lager.Logger, p Process) (bool, error) {
+	process, err := s.lifecycle.StatProcess(p.Config)
+	if err != nil {
+		logger.Info("process-removed")
+		return false, nil
+	}
+
+	if isAlive(process) {
+		logger.Info("process-already-running", lager.Data{"state": process.Status})
+		return false, nil
+	}
+
+	logger.Info("restarting")
+	if err := s.lifecycle.RemoveProcess(logger, p.Config); err != nil {
+		return false, err
+	}
+
+	return true, s.lifecycle.StartProcess(logger, p.Config, p.ProcessConfig)
+}
+
+// recordExit adds the exit of a process to the journal.
+func (s *Supervisor) recordExit(logger lager.Logger, p Process, pid int, exit Exit) {
+	e := events.Event{
+		Type:      events.Exited,
+		Job:       p.Config.JobName(),
+		Process:   p.Config.ProcName(),
+		Pid:       pid,
+		OOMKilled: exit.OOMKilled,
+	}
+
+	switch {
+	case exit.Unknown:
+	case exit.Signal != 0:
+		e.Signal = strings.TrimPrefix(unix.SignalName(exit.Signal), "SIG")
+	default:
+		status := exit.Status
+		e.ExitStatus = &status
+	}
+
+	if err := s.journal.Record(e); err != nil {
+		logger.Error("failed-to-record-event", err, lager.Data{"event": e.Type})
+	}
+}
+
+// isAlive reports whether the init process of a container has not exited.
+func isAlive(process *models.Process) bool {
+	return process.Status == models.ProcessStateRunning || process.Status == models.ProcessStatePaused
+}
+
+// wait waits for a process to exit. It returns false if stop is closed first.
+func (s *Supervisor) wait(logger lager.Logger, pid int, stop <-chan struct{}) (Exit, bool) {
+	exited := make(chan Exit, 1)
+	go func() {
+		exit, err := s.waiter.Wait(pid)
+		if err != nil {
+			logger.Error("failed-to-wait", err, lager.Data{"pid": pid})
+			exit = Exit{Unknown: true}
+		}
+		exited <- exit
+	}()
+
+	select {
+	case exit := <-exited:
+		return exit, true
+	case <-stop:
+		return Exit{}, false
+	}
+}
+
+// sleep waits for d. It returns false if stop is closed first.
+func (s *Supervisor) sleep(d time.Duration, stop <-chan struct{}) bool {
+	timer := s.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,484 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package supervisor_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"bpm/config"
+	"bpm/events"
+	"bpm/models"
+	"bpm/supervisor"
+	"bpm/supervisor/supervisorfakes"
+)
+
+var _ = Describe("Supervisor", func() {
+	var (
+		fakeLifecycle *supervisorfakes.FakeLifecycle
+		fakeWaiter    *supervisorfakes.FakeWaiter
+		fakeClock     *fakeclock.FakeClock
+		logger        *lagertest.TestLogger
+		journal       *events.Journal
+		tempDir       string
+
+		mu       sync.Mutex
+		state    *models.Process
+		nextPid  int
+		exits    chan supervisor.Exit
+		locks    int
+		startErr error
+
+		procCfg    *config.ProcessConfig
+		configured []*config.ProcessConfig
+		stop       chan struct{}
+		done       chan struct{}
+	)
+
+	setState := func(p *models.Process) {
+		mu.Lock()
+		defer mu.Unlock()
+		state = p
+	}
+
+	exit := func(e supervisor.Exit) {
+		setState(&models.Process{Name: "proc", Status: models.ProcessStateStopped})
+		exits <- e
+	}
+
+	lockCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return locks
+	}
+
+	configure := func(procCfgs ...*config.ProcessConfig) {
+		mu.Lock()
+		defer mu.Unlock()
+		configured = procCfgs
+	}
+
+	newSupervisor := func() *supervisor.Supervisor {
+		lock := func(lager.Logger, *config.BPMConfig) (func(), error) {
+			mu.Lock()
+			locks++
+			mu.Unlock()
+			return func() {}, nil
+		}
+
+		load := func() []supervisor.Process {
+			mu.Lock()
+			defer mu.Unlock()
+
+			var processes []supervisor.Process
+			for _, c := range configured {
+				processes = append(processes, supervisor.Process{
+					Config:        config.NewBPMConfig("/var/vcap", "job", c.Name),
+					ProcessConfig: c,
+				})
+			}
+			return processes
+		}
+
+		return supervisor.New(logger, fakeLifecycle, fakeWaiter, lock, load, fakeClock, journal)
+	}
+
+	supervise := func() {
+		s := newSupervisor()
+
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			s.Supervise(supervisor.Process{
+				Config:        config.NewBPMConfig("/var/vcap", "job", "proc"),
+				ProcessConfig: procCfg,
+			}, stop)
+		}()
+	}
+
+	BeforeEach(func() {
+		fakeLifecycle = &supervisorfakes.FakeLifecycle{}
+		fakeWaiter = &supervisorfakes.FakeWaiter{}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("supervisor")
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "bpm-supervisor")
+		Expect(err).NotTo(HaveOccurred())
+		journal = events.NewJournal(filepath.Join(tempDir, "events.log"), fakeClock)
+
+		state = nil
+		nextPid = 100
+		locks = 0
+		startErr = nil
+
+		// The goroutine waiting for the process in a previous test can
+		// still be running so each test has its own channel.
+		testExits := make(chan supervisor.Exit)
+		exits = testExits
+
+		fakeLifecycle.StatProcessStub = func(*config.BPMConfig) (*models.Process, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if state == nil {
+				return nil, errors.New("process is not running or could not be found")
+			}
+			return state, nil
+		}
+		fakeLifecycle.StartProcessStub = func(lager.Logger, *config.BPMConfig, *config.ProcessConfig) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if startErr != nil {
+				err := startErr
+				startErr = nil
+				return err
+			}
+			nextPid++
+			state = &models.Process{Name: "proc", Pid: nextPid, Status: models.ProcessStateRunning}
+			return nil
+		}
+		fakeLifecycle.RemoveProcessStub = func(lager.Logger, *config.BPMConfig) error {
+			setState(nil)
+			return nil
+		}
+		fakeWaiter.WaitStub = func(int) (supervisor.Exit, error) {
+			return <-testExits, nil
+		}
+
+		procCfg = &config.ProcessConfig{Name: "proc", Restart: config.RestartOnFailure}
+		configured = []*config.ProcessConfig{procCfg}
+		stop = make(chan struct{})
+		done = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		close(stop)
+		Eventually(done).Should(BeClosed())
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("starts a process which is not running and waits for it", func() {
+		supervise()
+
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+		Expect(fakeWaiter.WaitArgsForCall(0)).To(Equal(101))
+		Expect(fakeLifecycle.StartProcessCallCount()).To(Equal(1))
+		Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(0))
+
+		_, _, startedCfg := fakeLifecycle.StartProcessArgsForCall(0)
+		Expect(startedCfg).To(Equal(procCfg))
+		Expect(lockCount()).To(Equal(1))
+	})
+
+	It("does not start a process which is already running", func() {
+		setState(&models.Process{Name: "proc", Pid: 42, Status: models.ProcessStateRunning})
+		supervise()
+
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+		Expect(fakeWaiter.WaitArgsForCall(0)).To(Equal(42))
+		Expect(fakeLifecycle.StartProcessCallCount()).To(Equal(0))
+	})
+
+	It("does not start or wait for a process which is paused", func() {
+		setState(&models.Process{Name: "proc", Pid: 42, Status: models.ProcessStatePaused})
+		supervise()
+
+		Eventually(lockCount).Should(Equal(1))
+		fakeClock.WaitForWatcherAndIncrement(supervisor.PollInterval)
+		Consistently(fakeWaiter.WaitCallCount).Should(Equal(0))
+		Expect(fakeLifecycle.StartProcessCallCount()).To(Equal(0))
+		Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(0))
+
+		setState(&models.Process{Name: "proc", Pid: 42, Status: models.ProcessStateRunning})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.PollInterval)
+
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+		Expect(fakeWaiter.WaitArgsForCall(0)).To(Equal(42))
+	})
+
+	It("removes a process which has stopped before starting it", func() {
+		setState(&models.Process{Name: "proc", Status: models.ProcessStateFailed})
+		supervise()
+
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+		Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(1))
+		Expect(fakeLifecycle.StartProcessCallCount()).To(Equal(1))
+	})
+
+	It("restarts a process which fails after a backoff which doubles", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		exit(supervisor.Exit{Status: 1})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(2))
+		Expect(fakeWaiter.WaitArgsForCall(1)).To(Equal(102))
+		Expect(fakeLifecycle.RemoveProcessCallCount()).To(Equal(1))
+		Expect(fakeLifecycle.StartProcessCallCount()).To(Equal(2))
+		Expect(lockCount()).To(Equal(2))
+
+		exit(supervisor.Exit{Signal: syscall.SIGKILL})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Consistently(fakeLifecycle.StartProcessCallCount).Should(Equal(2))
+
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Eventually(fakeLifecycle.StartProcessCallCount).Should(Equal(3))
+	})
+
+	It("records how a process exited in the journal", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		exit(supervisor.Exit{Signal: syscall.SIGKILL, OOMKilled: true})
+
+		readExits := func() []events.Event {
+			recorded, err := journal.Read(events.Filter{})
+			Expect(err).NotTo(HaveOccurred())
+			return recorded
+		}
+		Eventually(readExits).Should(HaveLen(1))
+
+		e := readExits()[0]
+		Expect(e.Type).To(Equal(events.Exited))
+		Expect(e.Job).To(Equal("job"))
+		Expect(e.Process).To(Equal("proc"))
+		Expect(e.Pid).To(Equal(101))
+		Expect(e.Signal).To(Equal("KILL"))
+		Expect(e.ExitStatus).To(BeNil())
+		Expect(e.OOMKilled).To(BeTrue())
+	})
+
+	It("resets the backoff once a process has run for long enough", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		exit(supervisor.Exit{Status: 1})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(2))
+
+		fakeClock.Increment(supervisor.StableRunTime)
+
+		exit(supervisor.Exit{Status: 1})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Eventually(fakeLifecycle.StartProcessCallCount).Should(Equal(3))
+	})
+
+	It("gives up on a process which is crash looping", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		backoff := supervisor.InitialBackoff
+		for i := 1; i <= supervisor.CrashLoopLimit; i++ {
+			exit(supervisor.Exit{Status: 1})
+			fakeClock.WaitForWatcherAndIncrement(backoff)
+			Eventually(fakeWaiter.WaitCallCount).Should(Equal(i + 1))
+			backoff *= 2
+		}
+
+		exit(supervisor.Exit{Status: 1})
+		Eventually(logger).Should(gbytes.Say("crash-loop-limit-reached"))
+		Consistently(fakeLifecycle.StartProcessCallCount).Should(Equal(supervisor.CrashLoopLimit + 1))
+	})
+
+	It("retries a process which fails to start again", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		mu.Lock()
+		startErr = errors.New("hook failed")
+		mu.Unlock()
+
+		exit(supervisor.Exit{Status: 1})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Eventually(fakeLifecycle.StartProcessCallCount).Should(Equal(2))
+		Eventually(logger).Should(gbytes.Say("failed-to-restart"))
+
+		fakeClock.WaitForWatcherAndIncrement(2 * supervisor.InitialBackoff)
+		Eventually(fakeLifecycle.StartProcessCallCount).Should(Equal(3))
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(2))
+	})
+
+	It("does not restart a process which was removed", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		setState(nil)
+		exits <- supervisor.Exit{Signal: syscall.SIGTERM}
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+
+		Eventually(logger).Should(gbytes.Say("process-removed"))
+		Consistently(fakeLifecycle.StartProcessCallCount).Should(Equal(1))
+	})
+
+	It("watches a process again once it is started by someone else", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		setState(nil)
+		exits <- supervisor.Exit{Signal: syscall.SIGTERM}
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Eventually(logger).Should(gbytes.Say("process-removed"))
+
+		setState(&models.Process{Name: "proc", Pid: 500, Status: models.ProcessStateRunning})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.PollInterval)
+
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(2))
+		Expect(fakeWaiter.WaitArgsForCall(1)).To(Equal(500))
+	})
+
+	It("restarts a process with the configuration it has when it exits", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		changed := &config.ProcessConfig{Name: "proc", Restart: config.RestartAlways, Executable: "/bin/changed"}
+		configure(changed)
+
+		exit(supervisor.Exit{Status: 1})
+		fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+		Eventually(fakeLifecycle.StartProcessCallCount).Should(Equal(2))
+
+		_, _, startedCfg := fakeLifecycle.StartProcessArgsForCall(1)
+		Expect(startedCfg).To(Equal(changed))
+	})
+
+	It("does not restart a process which is no longer configured", func() {
+		supervise()
+		Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+		configure()
+
+		exit(supervisor.Exit{Status: 1})
+		Eventually(logger).Should(gbytes.Say("not-restarting.*no longer configured"))
+		Consistently(fakeLifecycle.StartProcessCallCount).Should(Equal(1))
+	})
+
+	Describe("supervising the machine", func() {
+		var reload chan struct{}
+
+		started := func() []string {
+			var names []string
+			for i := 0; i < fakeLifecycle.StartProcessCallCount(); i++ {
+				_, _, c := fakeLifecycle.StartProcessArgsForCall(i)
+				names = append(names, c.Name)
+			}
+			return names
+		}
+
+		BeforeEach(func() {
+			reload = make(chan struct{})
+
+			// None of the processes are ever seen to be running and so
+			// each one is only started once.
+			fakeLifecycle.StartProcessStub = nil
+			fakeLifecycle.StatProcessStub = nil
+			fakeLifecycle.StatProcessReturns(nil, errors.New("process is not running or could not be found"))
+
+			s := newSupervisor()
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				s.Run(reload, stop)
+			}()
+		})
+
+		It("supervises every process", func() {
+			Eventually(started).Should(ConsistOf("proc"))
+		})
+
+		It("supervises processes which are added when it reloads", func() {
+			Eventually(started).Should(ConsistOf("proc"))
+
+			configure(procCfg, &config.ProcessConfig{Name: "added"})
+			reload <- struct{}{}
+
+			Eventually(started).Should(ConsistOf("proc", "added"))
+		})
+
+		It("reloads periodically", func() {
+			Eventually(started).Should(ConsistOf("proc"))
+
+			configure(procCfg, &config.ProcessConfig{Name: "added"})
+			fakeClock.Increment(supervisor.ReloadInterval)
+
+			Eventually(started).Should(ConsistOf("proc", "added"))
+		})
+
+		It("stops supervising processes which are removed when it reloads", func() {
+			Eventually(started).Should(ConsistOf("proc"))
+
+			configure()
+			reload <- struct{}{}
+
+			Eventually(logger).Should(gbytes.Say("supervise.complete"))
+		})
+	})
+
+	Context("when the process exits successfully", func() {
+		It("is not restarted with the on-failure policy", func() {
+			supervise()
+			Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+			exit(supervisor.Exit{Status: 0})
+			Eventually(logger).Should(gbytes.Say("not-restarting"))
+			Consistently(fakeLifecycle.StartProcessCallCount).Should(Equal(1))
+		})
+
+		It("is restarted with the always policy", func() {
+			procCfg.Restart = config.RestartAlways
+			supervise()
+			Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+			exit(supervisor.Exit{Status: 0})
+			fakeClock.WaitForWatcherAndIncrement(supervisor.InitialBackoff)
+			Eventually(fakeLifecycle.StartProcessCallCount).Should(Equal(2))
+		})
+	})
+
+	Context("when the process has no restart policy", func() {
+		It("is never restarted", func() {
+			procCfg.Restart = ""
+			supervise()
+			Eventually(fakeWaiter.WaitCallCount).Should(Equal(1))
+
+			exit(supervisor.Exit{Status: 1})
+			Eventually(logger).Should(gbytes.Say("not-restarting"))
+			Consistently(fakeLifecycle.StartProcessCallCount).Should(Equal(1))
+		})
+	})
+})
+
+var _ = Describe("Exit", func() {
+	It("has failed if the process exited with a non-zero status or a signal", func() {
+		Expect(supervisor.Exit{}.Failed()).To(BeFalse())
+		Expect(supervisor.Exit{Status: 2}.Failed()).To(BeTrue())
+		Expect(supervisor.Exit{Signal: syscall.SIGSEGV}.Failed()).To(BeTrue())
+		Expect(supervisor.Exit{Unknown: true}.Failed()).To(BeTrue())
+	})
+})
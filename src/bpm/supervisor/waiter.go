@@ -0,0 +1,317 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"golang.org/x/sys/unix"
+
+	"bpm/cgroups"
+)
+
+// exitRetention is how long the exit status of a process which was reaped
+// before anybody waited for it is kept.
+const exitRetention = 1 * time.Minute
+
+// strayZombieAge is how long a child in the cgroup of the caller must have
+// been a zombie before it is reaped without anybody waiting for it. Such a
+// child was most likely started by os/exec, which waits for its children
+// itself as soon as they exit.
+const strayZombieAge = 1 * time.Minute
+
+// Reaper collects the exit status of the children of the caller and hands
+// them to the callers of Wait. bpm supervise makes itself a child subreaper
+// so that the init processes of the containers it starts, along with any
+// process orphaned inside them, become its children once runc has exited.
+type Reaper struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	waiters map[int]chan Exit
+	reaped  map[int]reapedExit
+	zombies map[int]time.Time
+}
+
+type reapedExit struct {
+	exit Exit
+	at   time.Time
+}
+
+func NewReaper(clock clock.Clock) *Reaper {
+	return &Reaper{
+		clock:   clock,
+		waiters: map[int]chan Exit{},
+		reaped:  map[int]reapedExit{},
+		zombies: map[int]time.Time{},
+	}
+}
+
+// Run reaps every child which has exited, whether or not anybody is waiting
+// for it, until stop is closed.
+func (r *Reaper) Run(stop <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, unix.SIGCHLD)
+	defer signal.Stop(signals)
+
+	ticker := r.clock.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reap()
+
+		select {
+		case <-stop:
+			return
+		case <-signals:
+		case <-ticker.C():
+		}
+	}
+}
+
+func (r *Reaper) Wait(pid int) (Exit, error) {
+	// The memory cgroup must be found while the process is still alive. It
+	// outlives the process until its container is removed.
+	memoryCgroup, err := cgroups.ProcessCgroup(pid, "memory")
+	if err != nil {
+		memoryCgroup, _ = cgroups.ProcessCgroup(pid, "")
+	}
+
+	exit := r.wait(pid)
+
+	if memoryCgroup != "" && (exit.Signal == unix.SIGKILL || exit.Unknown) {
+		kills, err := cgroups.OOMKills(memoryCgroup)
+		exit.OOMKilled = err == nil && kills > 0
+	}
+
+	return exit, nil
+}
+
+func (r *Reaper) wait(pid int) Exit {
+	exited := make(chan Exit, 1)
+
+	r.mu.Lock()
+	if reaped, ok := r.reaped[pid]; ok {
+		delete(r.reaped, pid)
+		r.mu.Unlock()
+		return reaped.exit
+	}
+	r.waiters[pid] = exited
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.waiters, pid)
+		r.mu.Unlock()
+	}()
+
+	for {
+		r.reap()
+
+		select {
+		case exit := <-exited:
+			return exit
+		case <-r.clock.After(PollInterval):
+		}
+
+		if exit, ok := r.gone(pid, exited); ok {
+			return exit
+		}
+	}
+}
+
+// gone reports whether a process which is not a child of the caller has
+// exited. A process which has exited but has not been reaped by its parent
+// yet is a zombie. Its exit status cannot be collected.
+func (r *Reaper) gone(pid int, exited chan Exit) (Exit, bool) {
+	// The lock is held so that a process which is reaped while it is
+	// checked on is not mistaken for one which went away on its own.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case exit := <-exited:
+		return exit, true
+	default:
+	}
+
+	state, ppid, err := processState(pid)
+	if os.IsNotExist(err) {
+		return Exit{Unknown: true}, true
+	}
+
+	if err == nil && state == 'Z' && ppid != os.Getpid() {
+		return Exit{Unknown: true}, true
+	}
+
+	return Exit{}, false
+}
+
+// reap collects the exit status of every child which has exited. A child
+// which nobody is waiting for is only reaped straight away if it was adopted
+// from a container, which means that it is in a different cgroup from the
+// caller. Any other child may have been started by os/exec, which would fail
+// to wait for it if it were reaped first, and so it is left until it has been
+// a zombie for strayZombieAge.
+func (r *Reaper) reap() {
+	pids := zombieChildren()
+	own := freezerCgroup(os.Getpid())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	zombies := map[int]time.Time{}
+
+	for _, pid := range pids {
+		exited, waited := r.waiters[pid]
+		if !waited && !adopted(pid, own) {
+			seen, ok := r.zombies[pid]
+			if !ok {
+				seen = now
+			}
+
+			if now.Sub(seen) < strayZombieAge {
+				zombies[pid] = seen
+				continue
+			}
+		}
+
+		var status unix.WaitStatus
+		if reaped, err := unix.Wait4(pid, &status, unix.WNOHANG, nil); err != nil || reaped != pid {
+			continue
+		}
+
+		exit := exitFromWaitStatus(status)
+		if waited {
+			exited <- exit
+		} else {
+			r.reaped[pid] = reapedExit{exit: exit, at: now}
+		}
+	}
+
+	r.zombies = zombies
+
+	for pid, reaped := range r.reaped {
+		if now.Sub(reaped.at) > exitRetention {
+			delete(r.reaped, pid)
+		}
+	}
+}
+
+// zombieChildren returns the pids of the children of the caller which have
+// exited and have not been reaped.
+func zombieChildren() []int {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		state, ppid, err := processState(pid)
+		if err != nil || state != 'Z' || ppid != self {
+			continue
+		}
+
+		pids = append(pids, pid)
+	}
+
+	return pids
+}
+
+// freezerCgroup returns the freezer cgroup of a process with cgroups v1 or
+// its only cgroup with cgroups v2. Every container has its own. It returns
+// an empty string if the cgroup cannot be found.
+func freezerCgroup(pid int) string {
+	if cgroup, err := cgroups.ProcessCgroup(pid, "freezer"); err == nil {
+		return cgroup
+	}
+
+	cgroup, _ := cgroups.ProcessCgroup(pid, "")
+	return cgroup
+}
+
+// adopted reports whether a child is in a different cgroup from the caller.
+// The children which the caller starts itself inherit its cgroup whereas the
+// processes adopted from a container are in the cgroup of the container. A
+// zombie stays in its freezer cgroup until it has been reaped, unlike its
+// memory cgroup.
+func adopted(pid int, own string) bool {
+	if own == "" {
+		return false
+	}
+
+	cgroup := freezerCgroup(pid)
+	return cgroup != "" && cgroup != own
+}
+
+// processState returns the state and parent of a process. It returns an
+// error which satisfies os.IsNotExist if the process does not exist.
+func processState(pid int) (byte, int, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return parseProcessState(string(data))
+}
+
+// parseProcessState reads the state and ppid fields of /proc/<pid>/stat. The
+// name of the process is skipped first because it can contain spaces and
+// parentheses.
+func parseProcessState(stat string) (byte, int, error) {
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, 0, errors.New("malformed process stat")
+	}
+
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 2 || len(fields[0]) != 1 {
+		return 0, 0, errors.New("malformed process stat")
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fields[0][0], ppid, nil
+}
+
+func exitFromWaitStatus(status unix.WaitStatus) Exit {
+	if status.Signaled() {
+		return Exit{Signal: status.Signal()}
+	}
+
+	return Exit{Status: status.ExitStatus()}
+}
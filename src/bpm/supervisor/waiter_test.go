@@ -0,0 +1,167 @@
+// Copyright (C) 2018-Present CloudFoundry.org Foundation, Inc. All rights reserved.
+//
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License”);
+// you may not use this file except in compliance with the License.
+//
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package supervisor_test
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bpm/supervisor"
+)
+
+var _ = Describe("Reaper", func() {
+	var waiter *supervisor.Reaper
+
+	BeforeEach(func() {
+		waiter = supervisor.NewReaper(clock.NewClock())
+	})
+
+	It("returns the exit status of a child process", func() {
+		cmd := exec.Command("sh", "-c", "exit 3")
+		Expect(cmd.Start()).To(Succeed())
+
+		exit, err := waiter.Wait(cmd.Process.Pid)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exit).To(Equal(supervisor.Exit{Status: 3}))
+	})
+
+	It("returns the signal which killed a child process", func() {
+		cmd := exec.Command("sleep", "60")
+		Expect(cmd.Start()).To(Succeed())
+		Expect(cmd.Process.Signal(syscall.SIGKILL)).To(Succeed())
+
+		exit, err := waiter.Wait(cmd.Process.Pid)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exit).To(Equal(supervisor.Exit{Signal: syscall.SIGKILL}))
+	})
+
+	It("waits for a process which is not a child to go away", func() {
+		out, err := exec.Command("sh", "-c", "sleep 0.2 >/dev/null 2>&1 & echo $!").Output()
+		Expect(err).NotTo(HaveOccurred())
+
+		pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		Expect(err).NotTo(HaveOccurred())
+
+		exit, err := waiter.Wait(pid)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exit).To(Equal(supervisor.Exit{Unknown: true}))
+	})
+
+	It("does not wait forever for a zombie which is not a child", func() {
+		cmd := exec.Command("sh", "-c", "sh -c 'exit 0' & echo $!; exec sleep 10")
+		stdout, err := cmd.StdoutPipe()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd.Start()).To(Succeed())
+		defer func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}()
+
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+
+		pid, err := strconv.Atoi(strings.TrimSpace(line))
+		Expect(err).NotTo(HaveOccurred())
+
+		done := make(chan supervisor.Exit)
+		go func() {
+			defer GinkgoRecover()
+			exit, err := waiter.Wait(pid)
+			Expect(err).NotTo(HaveOccurred())
+			done <- exit
+		}()
+
+		Eventually(done, 5*time.Second).Should(Receive(Equal(supervisor.Exit{Unknown: true})))
+	})
+
+	Context("when it is running", func() {
+		var stop chan struct{}
+
+		BeforeEach(func() {
+			stop = make(chan struct{})
+			go waiter.Run(stop)
+		})
+
+		AfterEach(func() {
+			close(stop)
+		})
+
+		It("does not reap the children which os/exec waits for", func() {
+			for i := 0; i < 5; i++ {
+				Expect(exec.Command("true").Run()).To(Succeed())
+			}
+		})
+
+		It("leaves a child in its own cgroup which nobody is waiting for alone for a while", func() {
+			cmd := exec.Command("sh", "-c", "exit 3")
+			Expect(cmd.Start()).To(Succeed())
+
+			Consistently(func() bool {
+				_, err := os.Stat("/proc/" + strconv.Itoa(cmd.Process.Pid))
+				return os.IsNotExist(err)
+			}, 2*time.Second).Should(BeFalse())
+
+			exit, err := waiter.Wait(cmd.Process.Pid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exit).To(Equal(supervisor.Exit{Status: 3}))
+		})
+	})
+
+	Context("when it is running for long enough", func() {
+		var (
+			fakeClock *fakeclock.FakeClock
+			stop      chan struct{}
+		)
+
+		BeforeEach(func() {
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			waiter = supervisor.NewReaper(fakeClock)
+			stop = make(chan struct{})
+			go waiter.Run(stop)
+		})
+
+		AfterEach(func() {
+			close(stop)
+		})
+
+		It("reaps a child which nobody is waiting for", func() {
+			cmd := exec.Command("sh", "-c", "exit 3")
+			Expect(cmd.Start()).To(Succeed())
+
+			Eventually(func() bool {
+				fakeClock.Increment(time.Minute)
+				_, err := os.Stat("/proc/" + strconv.Itoa(cmd.Process.Pid))
+				return os.IsNotExist(err)
+			}, 5*time.Second).Should(BeTrue())
+
+			By("handing its exit status to a later waiter")
+			exit, err := waiter.Wait(cmd.Process.Pid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exit).To(Equal(supervisor.Exit{Status: 3}))
+		})
+	})
+})